@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -104,6 +106,23 @@ func getDir(path string) string {
 	return ""
 }
 
+// lyricsModes 支持的歌词模式
+var lyricsModes = []string{"none", "embedded", "sidecar", "both"}
+
+// NetEaseQualities 网易云音质等级(由高到低)
+var NetEaseQualities = []string{"hires", "lossless", "exhigh", "standard"}
+
+// ✅ Validate 校验配置取值, 未知取值直接报错而不是静默回退
+func (c *Config) Validate() error {
+	if !slices.Contains(lyricsModes, c.Lyrics.Mode) {
+		return fmt.Errorf("未知歌词模式 lyrics.mode: %q(可选: %s)", c.Lyrics.Mode, strings.Join(lyricsModes, "/"))
+	}
+	if !slices.Contains(NetEaseQualities, c.NetEase.Quality) {
+		return fmt.Errorf("未知网易云音质 netease.quality: %q(可选: %s)", c.NetEase.Quality, strings.Join(NetEaseQualities, "/"))
+	}
+	return nil
+}
+
 func (c *Config) setDefaults() {
 	if c.WebConfig == nil {
 		c.WebConfig = &WebConfig{Enable: false, AppDomain: "localhost", Https: false, AppPort: 8080, GinMode: "debug"}
@@ -119,7 +138,23 @@ func (c *Config) setDefaults() {
 		}
 	}
 	if c.Tidy == nil {
-		c.Tidy = &TidyConfig{Mode: 1, DistDir: "data/dist"}
+		c.Tidy = &TidyConfig{Backend: "local", DistDir: "data/dist"}
+	}
+	if c.Tidy.Backend == "" {
+		// 兼容旧配置的整理模式
+		c.Tidy.Backend = "local"
+		if c.Tidy.Mode == 2 {
+			c.Tidy.Backend = "webdav"
+		}
+	}
+	if c.Tidy.MusicLayout == "" {
+		c.Tidy.MusicLayout = "{albumartist}/{year} - {album}/{disc:02}-{track:02} {title}.{ext}"
+	}
+	if c.Tidy.VideoLayout == "" {
+		c.Tidy.VideoLayout = "{platform}/{author}/{date} {title}.{ext}"
+	}
+	if c.Tidy.Collision == "" {
+		c.Tidy.Collision = "keep-higher-bitrate"
 	}
 	if c.WebDAV == nil {
 		c.WebDAV = &WebDAVConfig{
@@ -129,6 +164,12 @@ func (c *Config) setDefaults() {
 			WebDAVDir:  "",
 		}
 	}
+	if c.S3 == nil {
+		c.S3 = &S3Config{PathStyle: true, PartSize: 64}
+	}
+	if c.S3.PartSize < 5 {
+		c.S3.PartSize = 64
+	}
 	if c.Log == nil {
 		c.Log = &LogConfig{Mode: 1, Level: 2, File: "data/logs/run.log"}
 	}
@@ -146,4 +187,67 @@ func (c *Config) setDefaults() {
 			Enable: false,
 		}
 	}
+	if c.Job == nil {
+		c.Job = &JobConfig{DBFile: "data/jobs.db", Concurrency: 1, Platforms: make(map[string]int)}
+	}
+	if c.Job.DBFile == "" {
+		c.Job.DBFile = "data/jobs.db"
+	}
+	if c.Job.Concurrency <= 0 {
+		c.Job.Concurrency = 1
+	}
+	if c.YoutubeMusic == nil {
+		c.YoutubeMusic = &YoutubeMusicConfig{AudioFormat: "best", AudioQuality: "0"}
+	}
+	if c.YoutubeMusic.AudioFormat == "" {
+		c.YoutubeMusic.AudioFormat = "best"
+	}
+	if c.Video == nil {
+		c.Video = &VideoConfig{MaxHeight: 1080, Codec: "avc", Subtitles: true, SubLangs: "zh.*,en"}
+	}
+	if c.Video.SubLangs == "" {
+		c.Video.SubLangs = "zh.*,en"
+	}
+	if c.Douyin == nil {
+		c.Douyin = &DouyinConfig{MaxItems: 50, Interval: 1500}
+	}
+	if c.NetEase == nil {
+		c.NetEase = &NetEaseConfig{Quality: "lossless", ArtistScope: "top", Concurrency: 3, Retries: 2}
+	}
+	if c.NetEase.Quality == "" {
+		c.NetEase.Quality = "lossless"
+	}
+	if c.NetEase.Concurrency <= 0 {
+		c.NetEase.Concurrency = 3
+	}
+	if c.NetEase.ArtistScope == "" {
+		c.NetEase.ArtistScope = "top"
+	}
+	if c.AppleMusic == nil {
+		c.AppleMusic = &AppleMusicConfig{}
+	}
+	if len(c.AppleMusic.Codecs) == 0 {
+		c.AppleMusic.Codecs = []string{"aac"}
+	}
+	if c.AppleMusic.DownloadMode == "" {
+		c.AppleMusic.DownloadMode = "nm3u8dlre"
+	}
+	if len(c.AppleMusic.ArtistTypes) == 0 {
+		c.AppleMusic.ArtistTypes = []string{"album", "ep", "single"}
+	}
+	if c.AppleMusic.StationTracks <= 0 {
+		c.AppleMusic.StationTracks = 20
+	}
+	if c.Lyrics == nil {
+		c.Lyrics = &LyricsConfig{Mode: "embedded", TTML: true}
+	}
+	if c.Lyrics.Mode == "" {
+		c.Lyrics.Mode = "embedded"
+	}
+	if c.Library == nil {
+		c.Library = &LibraryConfig{Enable: true, DBFile: "data/library.db"}
+	}
+	if c.Library.DBFile == "" {
+		c.Library.DBFile = "data/library.db"
+	}
 }
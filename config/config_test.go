@@ -0,0 +1,45 @@
+package config
+
+import "testing"
+
+func TestValidateLyricsMode(t *testing.T) {
+	for _, mode := range []string{"none", "embedded", "sidecar", "both"} {
+		c := createDefaultConfig()
+		c.Lyrics.Mode = mode
+		if err := c.Validate(); err != nil {
+			t.Errorf("歌词模式 %q 应合法: %v", mode, err)
+		}
+	}
+	for _, mode := range []string{"lrc", "Embedded", "embed"} {
+		c := createDefaultConfig()
+		c.Lyrics.Mode = mode
+		if err := c.Validate(); err == nil {
+			t.Errorf("歌词模式 %q 应被拒绝", mode)
+		}
+	}
+	// 未配置时使用默认模式
+	if c := createDefaultConfig(); c.Lyrics.Mode != "embedded" || c.Validate() != nil {
+		t.Errorf("默认歌词模式错误: %q", c.Lyrics.Mode)
+	}
+}
+
+func TestValidateNetEaseQuality(t *testing.T) {
+	for _, q := range []string{"hires", "lossless", "exhigh", "standard"} {
+		c := createDefaultConfig()
+		c.NetEase.Quality = q
+		if err := c.Validate(); err != nil {
+			t.Errorf("网易云音质 %q 应合法: %v", q, err)
+		}
+	}
+	for _, q := range []string{"flac", "Lossless", "higher"} {
+		c := createDefaultConfig()
+		c.NetEase.Quality = q
+		if err := c.Validate(); err == nil {
+			t.Errorf("网易云音质 %q 应被拒绝", q)
+		}
+	}
+	// 未配置时使用默认音质
+	if c := createDefaultConfig(); c.NetEase.Quality != "lossless" || c.Validate() != nil {
+		t.Errorf("默认网易云音质错误: %q", c.NetEase.Quality)
+	}
+}
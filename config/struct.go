@@ -1,15 +1,24 @@
 package config
 
 type Config struct {
-	WebConfig        *WebConfig         `yaml:"web_config"`        // web配置
-	CookieCloud      *CookieCloudConfig `yaml:"cookie_cloud"`      // cookiecloud配置
-	Tidy             *TidyConfig        `yaml:"tidy"`              // 资源整理配置
-	WebDAV           *WebDAVConfig      `yaml:"webdav"`            // webdav配置
-	Log              *LogConfig         `yaml:"log"`               // 日志配置
-	Telegram         *TelegramConfig    `yaml:"telegram"`          // telegram配置
-	AI               *AIConfig          `yaml:"ai"`                // AI配置
-	AdditionalConfig *AdditionalConfig  `yaml:"additional_config"` // 附属配置
-	ProxyConfig      *ProxyConfig       `yaml:"proxy"`             // 代理配置
+	WebConfig        *WebConfig          `yaml:"web_config"`        // web配置
+	CookieCloud      *CookieCloudConfig  `yaml:"cookie_cloud"`      // cookiecloud配置
+	Tidy             *TidyConfig         `yaml:"tidy"`              // 资源整理配置
+	WebDAV           *WebDAVConfig       `yaml:"webdav"`            // webdav配置
+	S3               *S3Config           `yaml:"s3"`                // S3 兼容对象存储配置
+	Log              *LogConfig          `yaml:"log"`               // 日志配置
+	Telegram         *TelegramConfig     `yaml:"telegram"`          // telegram配置
+	AI               *AIConfig           `yaml:"ai"`                // AI配置
+	AdditionalConfig *AdditionalConfig   `yaml:"additional_config"` // 附属配置
+	ProxyConfig      *ProxyConfig        `yaml:"proxy"`             // 代理配置
+	Job              *JobConfig          `yaml:"job"`               // 任务队列配置
+	Library          *LibraryConfig      `yaml:"library"`           // 曲库索引配置
+	YoutubeMusic     *YoutubeMusicConfig `yaml:"youtube_music"`     // YouTube Music 下载配置
+	Video            *VideoConfig        `yaml:"video"`             // 视频下载配置(YouTube/B站)
+	Douyin           *DouyinConfig       `yaml:"douyin"`            // 抖音下载配置
+	NetEase          *NetEaseConfig      `yaml:"netease"`           // 网易云音乐下载配置
+	AppleMusic       *AppleMusicConfig   `yaml:"apple_music"`       // Apple Music 下载配置
+	Lyrics           *LyricsConfig       `yaml:"lyrics"`            // 歌词配置
 }
 
 type WebConfig struct {
@@ -30,8 +39,14 @@ type CookieCloudConfig struct {
 }
 
 type TidyConfig struct {
-	Mode    int    `yaml:"mode"`     // 资源整理模式: 1整理到DistDir, 2整理到webdav目录WebDAVDir
-	DistDir string `yaml:"dist_dir"` // 整理到的路径,仅在Mode为1时使用该目录
+	Backend string `yaml:"backend"`  // 存储后端: local整理到DistDir, webdav整理到webdav目录WebDAVDir, s3上传到S3兼容对象存储
+	Mode    int    `yaml:"mode"`     // 已废弃, 未配置backend时兼容: 1对应local, 2对应webdav
+	DistDir string `yaml:"dist_dir"` // 整理到的路径,仅在backend为local时使用该目录
+
+	MusicLayout string            `yaml:"music_layout"` // 音乐路径模板
+	VideoLayout string            `yaml:"video_layout"` // 视频路径模板
+	Layouts     map[string]string `yaml:"layouts"`      // 按平台覆盖路径模板, 键为平台名称(如 网易云音乐、抖音)
+	Collision   string            `yaml:"collision"`    // 重名处理: skip/overwrite/suffix/keep-higher-bitrate/keep-larger
 }
 
 type WebDAVConfig struct {
@@ -41,6 +56,17 @@ type WebDAVConfig struct {
 	WebDAVDir  string `yaml:"webdav_dir"`  // wevdav路径
 }
 
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint"`   // 服务地址, 如 http://minio:9000、https://s3.amazonaws.com
+	Region    string `yaml:"region"`     // 区域, 为空时自动获取
+	Bucket    string `yaml:"bucket"`     // 存储桶
+	Prefix    string `yaml:"prefix"`     // 对象前缀(目录)
+	AccessKey string `yaml:"access_key"` // Access Key
+	SecretKey string `yaml:"secret_key"` // Secret Key
+	PathStyle bool   `yaml:"path_style"` // 路径风格访问(MinIO 等自建服务通常需要开启)
+	PartSize  int    `yaml:"part_size"`  // 分片大小(MB, 最小5), 超过该大小的文件分片上传
+}
+
 type LogConfig struct {
 	Mode  int    `yaml:"mode"`  // 日志模式：1标准输出，2日志文件，3标准输出和日志文件
 	Level int    `yaml:"level"` // 日志等级，1=debug 2=info 3=warn 4=error 5=fatal
@@ -80,3 +106,52 @@ type ProxyConfig struct {
 	Pass   string `yaml:"pass"`   // 代理密码
 	Auth   bool   `yaml:"auth"`   // 是否需要认证
 }
+
+type JobConfig struct {
+	DBFile      string         `yaml:"db_file"`     // 任务队列持久化文件
+	Concurrency int            `yaml:"concurrency"` // 每个平台默认的并发任务数
+	Platforms   map[string]int `yaml:"platforms"`   // 按平台覆盖并发数, key为平台名称(如 AppleMusic、网易云音乐)
+}
+
+type YoutubeMusicConfig struct {
+	AudioFormat  string `yaml:"audio_format"`  // 音频格式: best(保留原始 opus/m4a 不转码), m4a, opus, mp3, flac
+	AudioQuality string `yaml:"audio_quality"` // 转码质量: 0(最好)-10 或码率如 320K, 仅转码时生效
+}
+
+type VideoConfig struct {
+	MaxHeight int    `yaml:"max_height"` // 最大分辨率高度, 如 1080; 0 表示不限制
+	Codec     string `yaml:"codec"`      // 优先视频编码: avc, hevc, av1, vp9; 留空表示不限制
+	Subtitles bool   `yaml:"subtitles"`  // 是否下载字幕
+	SubLangs  string `yaml:"sub_langs"`  // 字幕语言, 逗号分隔, 支持正则, 如 zh.*,en
+	Danmaku   bool   `yaml:"danmaku"`    // 是否下载B站弹幕(xml)
+}
+
+type DouyinConfig struct {
+	MaxItems int `yaml:"max_items"` // 主页/合集单次最多下载的作品数, 0 表示不限制
+	Interval int `yaml:"interval"`  // 翻页及作品下载间隔(毫秒), 避免触发风控
+}
+
+type NetEaseConfig struct {
+	Quality     string `yaml:"quality"`      // 优先音质: standard, exhigh, lossless, hires; 账号无权限时自动降级
+	ArtistScope string `yaml:"artist_scope"` // 歌手链接下载范围: top(热门50首), all(全部专辑)
+	MaxItems    int    `yaml:"max_items"`    // 歌手全部专辑/电台单次最多下载的歌曲数, 0 表示不限制
+	Concurrency int    `yaml:"concurrency"`  // 列表(歌单/专辑/歌手/电台)同时下载的歌曲数
+	Retries     int    `yaml:"retries"`      // 单首歌曲下载失败后的重试次数
+}
+
+type AppleMusicConfig struct {
+	Codecs        []string `yaml:"codecs"`         // 音频编码优先级: alac(无损), aac, atmos(杜比全景声), 也可直接填写 gamdl 编码名称
+	DownloadMode  string   `yaml:"download_mode"`  // 下载方式: nm3u8dlre, ytdlp
+	ArtistTypes   []string `yaml:"artist_types"`   // 歌手链接下载的发行类型: album(专辑), ep, single(单曲), compilation(合辑)
+	StationTracks int      `yaml:"station_tracks"` // 电台链接单次下载的歌曲数
+}
+
+type LyricsConfig struct {
+	Mode string `yaml:"mode"` // 歌词模式: none(不保存), embedded(嵌入标签), sidecar(同名 .lrc 文件), both(嵌入并保存 .lrc)
+	TTML bool   `yaml:"ttml"` // 保存 .lrc 时同时保留逐字 TTML 歌词(仅 Apple Music 提供)
+}
+
+type LibraryConfig struct {
+	Enable bool   `yaml:"enable"`  // 是否启用曲库索引(已入库的资源不再重复下载)
+	DBFile string `yaml:"db_file"` // 曲库索引文件
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+	bolt "go.etcd.io/bbolt"
+)
+
+// 曲库索引: 记录已入库的歌曲/视频,按 平台+资源ID 与 文件哈希 两种方式去重
+
+var (
+	libraryItemsBucket  = []byte("items")  // key: 平台:资源ID 或 sha256:哈希
+	libraryHashesBucket = []byte("hashes") // key: 文件哈希 -> items 中的 key
+)
+
+// ErrInLibrary 资源已在曲库中
+var ErrInLibrary = errors.New("资源已在曲库中")
+
+// LibraryEntry 曲库条目
+type LibraryEntry struct {
+	Platform  string    `json:"platform"`         // 来源平台
+	ID        string    `json:"id,omitempty"`     // 平台资源ID(歌曲ID/视频ID)
+	Hash      string    `json:"hash,omitempty"`   // 文件 SHA-256
+	Media     string    `json:"media"`            // music / video
+	Title     string    `json:"title"`            // 标题
+	Artist    string    `json:"artist,omitempty"` // 艺术家/作者
+	Album     string    `json:"album,omitempty"`  // 专辑
+	Tidy      string    `json:"tidy,omitempty"`   // 入库方式
+	Source    string    `json:"source,omitempty"` // 原始链接或文件路径
+	CreatedAt time.Time `json:"created_at"`       // 入库时间
+}
+
+type Library struct {
+	db *bolt.DB
+}
+
+var (
+	GlobalLibrary *Library
+)
+
+// InitLibrary 初始化全局曲库索引,未启用时 GlobalLibrary 为 nil,所有查询均视为未入库
+func InitLibrary(cfg *config.LibraryConfig) error {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+	if dir := filepath.Dir(cfg.DBFile); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("创建曲库索引目录失败: %w", err)
+		}
+	}
+	db, err := bolt.Open(cfg.DBFile, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return fmt.Errorf("打开曲库索引失败: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{libraryItemsBucket, libraryHashesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("初始化曲库索引失败: %w", err)
+	}
+	GlobalLibrary = &Library{db: db}
+	return nil
+}
+
+// -------------------- 查询 --------------------
+
+// Has 按 平台+资源ID 查询
+func (l *Library) Has(platform, id string) (*LibraryEntry, bool) {
+	if l == nil || id == "" {
+		return nil, false
+	}
+	return l.get([]byte(platform + ":" + id))
+}
+
+// HasHash 按文件哈希查询
+func (l *Library) HasHash(hash string) (*LibraryEntry, bool) {
+	if l == nil || hash == "" {
+		return nil, false
+	}
+	var key []byte
+	_ = l.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(libraryHashesBucket).Get([]byte(hash)); v != nil {
+			key = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	if key == nil {
+		return nil, false
+	}
+	return l.get(key)
+}
+
+// Contains 按 平台+资源ID 或 文件哈希 任一命中即视为已入库
+func (l *Library) Contains(platform, id, hash string) (*LibraryEntry, bool) {
+	if e, ok := l.Has(platform, id); ok {
+		return e, true
+	}
+	return l.HasHash(hash)
+}
+
+func (l *Library) get(key []byte) (*LibraryEntry, bool) {
+	var entry *LibraryEntry
+	_ = l.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(libraryItemsBucket).Get(key)
+		if data == nil {
+			return nil
+		}
+		entry = &LibraryEntry{}
+		return json.Unmarshal(data, entry)
+	})
+	return entry, entry != nil
+}
+
+// -------------------- 写入 --------------------
+
+// Add 记录已入库资源
+func (l *Library) Add(e *LibraryEntry) error {
+	if l == nil {
+		return nil
+	}
+	if e.ID == "" && e.Hash == "" {
+		return errors.New("资源ID与文件哈希均为空")
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	key := []byte("sha256:" + e.Hash)
+	if e.ID != "" {
+		key = []byte(e.Platform + ":" + e.ID)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(libraryItemsBucket).Put(key, data); err != nil {
+			return err
+		}
+		if e.Hash != "" {
+			return tx.Bucket(libraryHashesBucket).Put([]byte(e.Hash), key)
+		}
+		return nil
+	})
+}
+
+// Close 关闭曲库索引
+func (l *Library) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.db.Close()
+}
+
+// -------------------- 工具 --------------------
+
+// HashFile 计算文件 SHA-256
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LogLibraryHit 输出曲库命中日志
+func LogLibraryHit(tag string, e *LibraryEntry) {
+	utils.InfoWithFormat("[%s] ⏭️ 已在曲库中，跳过: %s (%s 入库)", tag, e.Title, e.CreatedAt.Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,129 @@
+package linkparser
+
+import (
+	"testing"
+
+	"github.com/nichuanfang/gymdl/processor"
+)
+
+// TestIdentify 链接 -> 处理器与资源(类型/ID/规范链接)
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		link     string
+		platform processor.LinkType
+		kind     processor.ResourceKind
+		id       string
+		url      string
+	}{
+		/* ---------------------- 网易云音乐 ---------------------- */
+		{"https://music.163.com/song?id=1901371647&userid=1", processor.LinkNetEase, processor.ResourceTrack, "1901371647", "https://music.163.com/song?id=1901371647"},
+		{"https://music.163.com/#/playlist?id=2829883282", processor.LinkNetEase, processor.ResourcePlaylist, "2829883282", "https://music.163.com/playlist?id=2829883282"},
+		{"https://y.music.163.com/m/album?id=34720827", processor.LinkNetEase, processor.ResourceAlbum, "34720827", "https://music.163.com/album?id=34720827"},
+		{"https://music.163.com/artist?id=6452", processor.LinkNetEase, processor.ResourceArtist, "6452", "https://music.163.com/artist?id=6452"},
+		{"https://music.163.com/djradio?id=794062371", processor.LinkNetEase, processor.ResourceRadio, "794062371", "https://music.163.com/djradio?id=794062371"},
+		{"https://music.163.com/program?id=2065184960", processor.LinkNetEase, processor.ResourceProgram, "2065184960", "https://music.163.com/program?id=2065184960"},
+
+		/* ---------------------- QQ音乐 ---------------------- */
+		{"https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV", processor.LinkQQMusic, processor.ResourceTrack, "0039MnYb0qxYhV", "https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV"},
+		{"https://i.y.qq.com/v8/playsong.html?songmid=0039MnYb0qxYhV&ADTAG=share", processor.LinkQQMusic, processor.ResourceTrack, "0039MnYb0qxYhV", "https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV"},
+		{"https://y.qq.com/n/ryqq/albumDetail/002eFUFm2XYZ7z", processor.LinkQQMusic, processor.ResourceAlbum, "002eFUFm2XYZ7z", "https://y.qq.com/n/ryqq/albumDetail/002eFUFm2XYZ7z"},
+		{"https://y.qq.com/n/ryqq/playlist/7256912512", processor.LinkQQMusic, processor.ResourcePlaylist, "7256912512", "https://y.qq.com/n/ryqq/playlist/7256912512"},
+		{"https://i.y.qq.com/n2/m/share/details/taoge.html?id=7256912512&hosteuin=x", processor.LinkQQMusic, processor.ResourcePlaylist, "7256912512", "https://y.qq.com/n/ryqq/playlist/7256912512"},
+
+		/* ---------------------- Apple Music ---------------------- */
+		{"https://music.apple.com/cn/album/%E5%8F%B6%E6%83%A0%E7%BE%8E/1440857781", processor.LinkAppleMusic, processor.ResourceAlbum, "1440857781", "https://music.apple.com/cn/album/1440857781"},
+		{"https://music.apple.com/cn/album/x/1440857781?i=1440857782", processor.LinkAppleMusic, processor.ResourceTrack, "1440857782", "https://music.apple.com/cn/song/1440857782"},
+		{"https://music.apple.com/us/song/blinding-lights/1488408568", processor.LinkAppleMusic, processor.ResourceTrack, "1488408568", "https://music.apple.com/us/song/1488408568"},
+		{"https://music.apple.com/us/playlist/todays-hits/pl.u-abc123", processor.LinkAppleMusic, processor.ResourcePlaylist, "pl.u-abc123", "https://music.apple.com/us/playlist/pl.u-abc123"},
+		{"https://music.apple.com/library/playlist/p.abc123", processor.LinkAppleMusic, processor.ResourcePlaylist, "p.abc123", "https://music.apple.com/library/playlist/p.abc123"},
+		{"https://music.apple.com/us/artist/the-weeknd/479756766", processor.LinkAppleMusic, processor.ResourceArtist, "479756766", "https://music.apple.com/us/artist/479756766"},
+		{"https://music.apple.com/us/station/the-weeknd-station/ra.479756766", processor.LinkAppleMusic, processor.ResourceRadio, "ra.479756766", "https://music.apple.com/us/station/ra.479756766"},
+		{"https://music.apple.com/us/music-video/blinding-lights/1499393612", processor.LinkAppleMusicVideo, processor.ResourceVideo, "1499393612", "https://music.apple.com/us/music-video/1499393612"},
+
+		/* ---------------------- Spotify ---------------------- */
+		{"https://open.spotify.com/intl-de/track/4uLU6hMCjMI75M1A2tKUQC?si=x", processor.LinkSpotify, processor.ResourceTrack, "4uLU6hMCjMI75M1A2tKUQC", "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC"},
+		{"https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M", processor.LinkSpotify, processor.ResourcePlaylist, "37i9dQZF1DXcBWIGoYBM5M", "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"},
+
+		/* ---------------------- SoundCloud ---------------------- */
+		{"https://soundcloud.com/artist/track-name?in=x", processor.LinkSoundcloud, processor.ResourceTrack, "artist/track-name", "https://soundcloud.com/artist/track-name"},
+		{"https://soundcloud.com/artist/sets/album-name", processor.LinkSoundcloud, processor.ResourcePlaylist, "artist/sets/album-name", "https://soundcloud.com/artist/sets/album-name"},
+		{"https://soundcloud.com/artist/likes", processor.LinkSoundcloud, processor.ResourcePlaylist, "artist/likes", "https://soundcloud.com/artist/likes"},
+
+		/* ---------------------- YouTube Music / YouTube ---------------------- */
+		{"https://music.youtube.com/watch?v=dQw4w9WgXcQ&list=RDAMVM", processor.LinkYoutubeMusic, processor.ResourceTrack, "dQw4w9WgXcQ", "https://music.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://music.youtube.com/playlist?list=OLAK5uy_abc", processor.LinkYoutubeMusic, processor.ResourceAlbum, "OLAK5uy_abc", "https://music.youtube.com/playlist?list=OLAK5uy_abc"},
+		{"https://music.youtube.com/playlist?list=PLabc", processor.LinkYoutubeMusic, processor.ResourcePlaylist, "PLabc", "https://music.youtube.com/playlist?list=PLabc"},
+		{"https://music.youtube.com/browse/MPREb_abc", processor.LinkYoutubeMusic, processor.ResourceAlbum, "MPREb_abc", "https://music.youtube.com/browse/MPREb_abc"},
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=10", processor.LinkYoutube, processor.ResourceVideo, "dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://youtu.be/dQw4w9WgXcQ?si=x", processor.LinkYoutube, processor.ResourceVideo, "dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://www.youtube.com/shorts/dQw4w9WgXcQ", processor.LinkYoutube, processor.ResourceVideo, "dQw4w9WgXcQ", "https://www.youtube.com/watch?v=dQw4w9WgXcQ"},
+		{"https://www.youtube.com/playlist?list=PLabc", processor.LinkYoutube, processor.ResourcePlaylist, "PLabc", "https://www.youtube.com/playlist?list=PLabc"},
+
+		/* ---------------------- B站 ---------------------- */
+		{"https://www.bilibili.com/video/BV1GJ411x7h7/?spm_id_from=x", processor.LinkBilibili, processor.ResourceVideo, "BV1GJ411x7h7", "https://www.bilibili.com/video/BV1GJ411x7h7"},
+		{"https://m.bilibili.com/video/BV1GJ411x7h7?p=3", processor.LinkBilibili, processor.ResourceVideo, "BV1GJ411x7h7_p3", "https://www.bilibili.com/video/BV1GJ411x7h7?p=3"},
+		{"https://www.bilibili.com/bangumi/play/ep123456", processor.LinkBilibili, processor.ResourcePlaylist, "bilibili.com/bangumi/play/ep123456", "https://bilibili.com/bangumi/play/ep123456"},
+
+		/* ---------------------- 抖音 / 小红书 ---------------------- */
+		{"https://www.douyin.com/video/7300000000000000000", processor.LinkDouyin, processor.ResourceVideo, "7300000000000000000", "https://www.douyin.com/video/7300000000000000000"},
+		{"https://www.iesdouyin.com/share/video/7300000000000000000/?region=CN", processor.LinkDouyin, processor.ResourceVideo, "7300000000000000000", "https://www.douyin.com/video/7300000000000000000"},
+		{"https://www.douyin.com/note/7300000000000000001", processor.LinkDouyin, processor.ResourceVideo, "7300000000000000001", "https://www.douyin.com/note/7300000000000000001"},
+		{"https://www.douyin.com/user/MS4wLjABAAAA-abc", processor.LinkDouyin, processor.ResourceUser, "MS4wLjABAAAA-abc", "https://www.douyin.com/user/MS4wLjABAAAA-abc"},
+		{"https://www.douyin.com/collection/7200000000000000000", processor.LinkDouyin, processor.ResourcePlaylist, "7200000000000000000", "https://www.douyin.com/collection/7200000000000000000"},
+		{"https://www.xiaohongshu.com/explore/64b0f0f0000000001203e0a1?xsec_token=x", processor.LinkXiaohongshu, processor.ResourceVideo, "64b0f0f0000000001203e0a1", "https://www.xiaohongshu.com/explore/64b0f0f0000000001203e0a1"},
+	}
+	for _, tt := range tests {
+		reg := processor.Lookup(tt.link)
+		if reg == nil {
+			t.Errorf("%s: 未匹配到处理器", tt.link)
+			continue
+		}
+		if reg.Name != tt.platform {
+			t.Errorf("%s: 处理器 = %s, 期望 %s", tt.link, reg.Name, tt.platform)
+			continue
+		}
+		res := reg.Identify(tt.link)
+		if res == nil {
+			t.Errorf("%s: 未识别到资源", tt.link)
+			continue
+		}
+		if res.Platform != tt.platform || res.Kind != tt.kind || res.ID != tt.id || res.URL != tt.url {
+			t.Errorf("%s:\n  得到 %s %s %s %s\n  期望 %s %s %s %s", tt.link,
+				res.Platform, res.Kind, res.ID, res.URL, tt.platform, tt.kind, tt.id, tt.url)
+		}
+	}
+}
+
+// TestIdentifyShortLinks 短链无法直接识别(识别时不发起网络请求), 由处理器下载时还原
+func TestIdentifyShortLinks(t *testing.T) {
+	links := map[string]processor.LinkType{
+		"https://163cn.tv/abc123":         processor.LinkNetEase,
+		"https://v.douyin.com/iRNBho6u/":  processor.LinkDouyin,
+		"https://b23.tv/BV1GJ411x7h7abc":  processor.LinkBilibili,
+		"http://xhslink.com/a/AbCdEf1234": processor.LinkXiaohongshu,
+	}
+	for link, platform := range links {
+		reg := processor.Lookup(link)
+		if reg == nil || reg.Name != platform {
+			t.Errorf("%s: 处理器 = %v, 期望 %s", link, reg, platform)
+			continue
+		}
+		if res := reg.Identify(link); res != nil {
+			t.Errorf("%s: 短链不应直接识别, 得到 %s", link, res)
+		}
+	}
+}
+
+// TestIdentifyUnsupported 不支持的链接没有对应的处理器
+func TestIdentifyUnsupported(t *testing.T) {
+	for _, link := range []string{
+		"https://example.com/song?id=1",
+		"https://music.163.com/discover",
+		"https://open.spotify.com/show/abc",
+		"https://www.bilibili.com/read/cv123",
+	} {
+		if reg := processor.Lookup(link); reg != nil {
+			t.Errorf("%s: 不应匹配处理器 %s", link, reg.Name)
+		}
+	}
+}
@@ -7,67 +7,89 @@ import (
 	"unicode"
 
 	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+
+	// 各平台处理器在 init 中注册到 processor 注册表
+	_ "github.com/nichuanfang/gymdl/processor/music"
+	_ "github.com/nichuanfang/gymdl/processor/video"
 )
 
 // 链接解析器
 
-// linkTypeMatcher 处理器匹配规则
-type linkTypeMatcher struct {
-	domains  []string // 快速判定域名
-	patterns []*regexp.Regexp
-	handler  processor.Processor
-}
-
 /* ---------------------- 变量区 ---------------------- */
 
-// 快速域名 -> matcher 索引映射表（加速匹配）
-var matcherMap = make(map[string]*linkTypeMatcher)
-
 // 通用 URL 提取
 var genericURLRegex = regexp.MustCompile(`https?://[^\s<>"'()]*[\w/#?=&-]`)
 
-/* ---------------------- 解析器初始化 ---------------------- */
+/* ---------------------- 结构体定义 ---------------------- */
 
-// 初始化
-func init() {
-	for i := range linkTypeMatchers {
-		l := &linkTypeMatchers[i]
-		for _, d := range l.domains {
-			matcherMap[d] = l
-		}
-	}
+// ParsedLink 从文本中解析出的链接
+type ParsedLink struct {
+	Link         string                  // 清洗后的链接
+	Original     string                  // 文本中的原始链接
+	Registration *processor.Registration // 对应处理器, 不支持的链接为 nil
+	Resource     *processor.Resource     // 资源标识(平台/类型/ID/规范链接), 不支持的链接为 nil
 }
 
-/* ---------------------- 核心方法 ---------------------- */
+// Supported 是否为支持的链接
+func (l ParsedLink) Supported() bool {
+	return l.Registration != nil
+}
 
-// ⚡ ParseLink 解析链接
-func ParseLink(text string) (string, processor.Processor) {
-	raw := genericURLRegex.FindString(text)
-	if raw == "" {
-		return "", nil
-	}
-	// 链接清洗
-	raw = cleanURLTrailingChars(raw)
-	u, err := url.Parse(raw)
-	if err != nil {
-		return "", nil
-	}
+/* ---------------------- 核心方法 ---------------------- */
 
-	host := strings.ToLower(u.Host)
-	handler, ok := quickMatch(host, u)
-	if ok {
-		return raw, handler
+// ⚡ ParseLink 解析文本中第一个支持的链接;
+// 处理器实例由调用方通过 Registration.Factory 按任务创建, 避免并发任务共享状态
+func ParseLink(text string) (ParsedLink, bool) {
+	for _, l := range ParseLinks(text) {
+		if l.Supported() {
+			return l, true
+		}
 	}
+	return ParsedLink{}, false
+}
 
-	// fallback: 正则穷举匹配
-	for i := range linkTypeMatchers {
-		for _, r := range linkTypeMatchers[i].patterns {
-			if r.MatchString(raw) {
-				return raw, linkTypeMatchers[i].handler
+// ParseLinks 提取文本中的全部链接并识别资源, 同一资源只保留第一次出现的链接,
+// 不支持的链接 Registration 为 nil
+func ParseLinks(text string) []ParsedLink {
+	raws := genericURLRegex.FindAllString(text, -1)
+	links := make([]ParsedLink, 0, len(raws))
+	seen := make(map[string]bool, len(raws))
+	for _, raw := range raws {
+		// 链接清洗
+		raw = cleanURLTrailingChars(raw)
+		if raw == "" || seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		if _, err := url.Parse(raw); err != nil {
+			continue
+		}
+		l := resolve(raw)
+		if l.Resource != nil {
+			if seen[l.Resource.Key()] {
+				utils.DebugWithFormat("[LinkParser] 重复资源已忽略: %s", raw)
+				continue
 			}
+			seen[l.Resource.Key()] = true
 		}
+		links = append(links, l)
 	}
-	return "", nil
+	return links
+}
+
+// resolve 匹配处理器并识别资源(不发起网络请求), 无法直接识别的链接(短链)由处理器下载时还原
+func resolve(raw string) ParsedLink {
+	l := ParsedLink{Link: raw, Original: raw}
+	// 按域名与匹配规则查找已注册的处理器
+	if l.Registration = processor.Lookup(raw); l.Registration == nil {
+		return l
+	}
+	if l.Resource = l.Registration.Identify(raw); l.Resource == nil {
+		l.Resource = &processor.Resource{Platform: l.Registration.Name, URL: l.Link}
+	}
+	utils.DebugWithFormat("[LinkParser] %s -> %s", raw, l.Resource)
+	return l
 }
 
 /* ---------------------- 辅助方法 ---------------------- */
@@ -91,16 +113,3 @@ func cleanURLTrailingChars(s string) string {
 	}
 	return string(runes[:end])
 }
-
-// quickMatch 先基于域名进行快速判断
-func quickMatch(host string, u *url.URL) (processor.Processor, bool) {
-	if p, ok := matcherMap[host]; ok {
-		// 再进行一次轻量正则或路径判断
-		for _, re := range p.patterns {
-			if re.MatchString(u.String()) {
-				return p.handler, true
-			}
-		}
-	}
-	return nil, false
-}
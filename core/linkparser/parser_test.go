@@ -0,0 +1,86 @@
+package linkparser
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+func TestMain(m *testing.M) {
+	_ = utils.InitLogger(&config.LogConfig{Mode: 1, Level: 4})
+	os.Exit(m.Run())
+}
+
+func TestParseLinks(t *testing.T) {
+	text := `来几首: https://music.163.com/song?id=1901371647&userid=1，
+还有 https://music.163.com/#/song?id=1901371647 (同一首)
+https://example.com/page.html
+https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc。
+https://music.163.com/song?id=1901371647&userid=1`
+
+	links := ParseLinks(text)
+	want := []struct {
+		link     string
+		platform processor.LinkType // 不支持的链接为空
+		key      string
+	}{
+		{"https://music.163.com/song?id=1901371647&userid=1", processor.LinkNetEase, "网易云音乐:track:1901371647"},
+		{"https://example.com/page.html", processor.LinkUnknown, ""},
+		{"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc", processor.LinkSpotify, "Spotify:track:4uLU6hMCjMI75M1A2tKUQC"},
+	}
+	if len(links) != len(want) {
+		for _, l := range links {
+			t.Logf("%s -> %v", l.Link, l.Resource)
+		}
+		t.Fatalf("解析到 %d 个链接, 期望 %d 个", len(links), len(want))
+	}
+	for i, w := range want {
+		l := links[i]
+		if l.Link != w.link {
+			t.Errorf("links[%d].Link = %q, 期望 %q", i, l.Link, w.link)
+		}
+		if w.platform == processor.LinkUnknown {
+			if l.Supported() || l.Resource != nil {
+				t.Errorf("links[%d] 应为不支持的链接: %+v", i, l)
+			}
+			continue
+		}
+		if !l.Supported() || l.Registration.Name != w.platform {
+			t.Errorf("links[%d] 平台错误: %+v", i, l.Registration)
+			continue
+		}
+		if l.Resource == nil || l.Resource.Key() != w.key {
+			t.Errorf("links[%d].Resource = %v, 期望 %s", i, l.Resource, w.key)
+		}
+	}
+}
+
+func TestParseLink(t *testing.T) {
+	l, ok := ParseLink("不支持 https://example.com/a 支持 https://open.spotify.com/album/1DFixLWuPkv3KT3TnV35m3")
+	if !ok {
+		t.Fatal("未解析到支持的链接")
+	}
+	if l.Registration.Name != processor.LinkSpotify || l.Resource.Kind != processor.ResourceAlbum {
+		t.Errorf("解析结果错误: %s", l.Resource)
+	}
+	if _, ok := ParseLink("没有链接"); ok {
+		t.Error("不含链接的文本不应解析成功")
+	}
+}
+
+func TestCleanURLTrailingChars(t *testing.T) {
+	tests := map[string]string{
+		"https://a.com/x).":     "https://a.com/x",
+		"https://a.com/x?id=1，": "https://a.com/x?id=1",
+		" https://a.com/x\"":    "https://a.com/x",
+		"https://a.com/歌曲":      "https://a.com/歌曲",
+	}
+	for in, want := range tests {
+		if got := cleanURLTrailingChars(in); got != want {
+			t.Errorf("cleanURLTrailingChars(%q) = %q, 期望 %q", in, got, want)
+		}
+	}
+}
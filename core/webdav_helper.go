@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -68,7 +69,7 @@ func (w *WebDAV) CheckConnection() bool {
 
 // -------------------- 文件操作 --------------------
 
-// Upload 上传文件到根目录
+// Upload 上传文件到 WebDAVDir 目录
 func (w *WebDAV) Upload(localPath string) error {
 	if localPath == "" {
 		return fmt.Errorf("localPath cannot be empty")
@@ -82,8 +83,11 @@ func (w *WebDAV) Upload(localPath string) error {
 
 	// 获取本地文件名
 	fileName := filepath.Base(localPath)
-	// 构造远程路径（根目录下）
-	remoteFullPath := "/" + fileName
+	// 构造远程路径（WebDAVDir 目录下）
+	remoteFullPath := w.makeRemotePath(fileName)
+	if err := w.ensureRemoteDir(path.Dir(remoteFullPath)); err != nil {
+		return fmt.Errorf("failed to ensure remote dir: %v", err)
+	}
 	logger.Info("💡start uploading file to webdav...")
 	if err := w.Client.WriteStream(remoteFullPath, file, 0644); err != nil {
 		logger.Warn(fmt.Sprintf("WebDAV upload failed for %s: %v", remoteFullPath, err))
@@ -1,62 +1,76 @@
 package bot
 
 import (
-	"errors"
 	"fmt"
-	"time"
+	"strconv"
+	"strings"
 
-	"github.com/nichuanfang/gymdl/internal/bot/dispatch"
+	"github.com/nichuanfang/gymdl/internal/jobs"
 	"github.com/nichuanfang/gymdl/utils"
 
 	"github.com/nichuanfang/gymdl/core/linkparser"
-	"github.com/nichuanfang/gymdl/processor/music"
-	"github.com/nichuanfang/gymdl/processor/video"
 	tb "gopkg.in/telebot.v4"
 )
 
-// HandleText 精简版交互逻辑
+// HandleText 识别链接并加入下载队列, 一条消息中包含多个链接时批量加入
 func HandleText(c tb.Context) error {
 	text := c.Text()
 	user := c.Sender()
 	b := c.Bot()
 
 	// 初始提示
-	msg, _ := b.Send(user, "🔍 正在识别链接...")
+	msg, err := b.Send(user, "🔍 正在识别链接...")
+	if err != nil {
+		return err
+	}
 
-	// 解析链接link:有效链接 linkType:链接类型
-	link, executor := linkparser.ParseLink(text)
-	if link == "" {
+	// 解析全部链接, 区分支持与不支持的链接
+	var (
+		links       []linkparser.ParsedLink
+		unsupported []string
+	)
+	for _, l := range linkparser.ParseLinks(text) {
+		if l.Supported() {
+			links = append(links, l)
+		} else {
+			unsupported = append(unsupported, l.Link)
+		}
+	}
+	if len(links) == 0 {
 		_, _ = b.Edit(msg, "❌ 暂不支持该类型的链接")
 		return nil
 	}
-	utils.InfoWithFormat("[Telegram] 解析成功: %s", link)
-
-	// 创建会话对象
-	session := &dispatch.Session{
-		Text:    text,
-		Context: c,
-		User:    user,
-		Bot:     b,
-		Msg:     msg,
-		Link:    link,
-		Start:   time.Now(),
-		Cfg:     app.cfg,
+	if len(links) > 1 {
+		return handleBatch(b, user, msg, links, unsupported)
 	}
-	var err error
-	switch expr := executor.(type) {
-	case music.Processor:
-		// 初始化音乐处理器
-		expr.Init(app.cfg)
-		err = session.HandleMusic(expr)
-	case video.Processor:
-		// 初始化视频处理器
-		expr.Init(app.cfg)
-		err = session.HandleVideo(expr)
-	default:
-		err = errors.New(fmt.Sprintf("未知处理器类型: %v", expr))
+
+	link := links[0]
+	utils.InfoWithFormat("[Telegram] 解析成功: %s", link.Resource)
+	if len(unsupported) > 0 {
+		_, _ = b.Send(user, "⚠️ 以下链接暂不支持，已忽略：\n"+strings.Join(unsupported, "\n"), tb.NoPreview)
 	}
-	if err != nil {
-		_ = c.Send(fmt.Sprintf("处理失败：%s", err.Error()))
+
+	// 加入下载队列,后续进度由任务事件推送
+	if _, err = enqueueLink(link, msg); err != nil {
+		_, _ = b.Edit(msg, fmt.Sprintf("❌ 加入下载队列失败：%s", err.Error()))
 	}
 	return nil
 }
+
+// enqueueLink 将链接加入下载队列, msg 为任务的进度消息
+func enqueueLink(link linkparser.ParsedLink, msg *tb.Message) (*jobs.Job, error) {
+	return jobs.GlobalQueue.Enqueue(&jobs.Job{
+		Kind:      jobs.KindLink,
+		Link:      link.Link,
+		Platform:  link.Registration.Name,
+		Resource:  link.Resource,
+		Source:    jobs.SourceTelegram,
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+	})
+}
+
+// jobMessage 任务对应的进度消息
+func jobMessage(job *jobs.Job) tb.Editable {
+	return &tb.StoredMessage{MessageID: strconv.Itoa(job.MessageID), ChatID: job.ChatID}
+}
@@ -0,0 +1,152 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/nichuanfang/gymdl/core/linkparser"
+	"github.com/nichuanfang/gymdl/internal/jobs"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+	tb "gopkg.in/telebot.v4"
+)
+
+// 一条消息中的多个链接: 每个链接单独一条进度消息, 另有一条汇总消息展示整体结果
+
+type batchItem struct {
+	platform processor.LinkType
+	link     string
+	jobID    uint64
+	state    jobs.State // 入队失败时为空
+	skipped  bool
+	err      string
+}
+
+type batch struct {
+	mu          sync.Mutex
+	bot         tb.API
+	msg         tb.Editable // 汇总消息
+	items       []*batchItem
+	unsupported []string
+}
+
+var (
+	batchesMu sync.Mutex
+	batches   = make(map[uint64]*batch) // 任务ID -> 所属批次
+)
+
+// handleBatch 批量加入下载队列, 初始消息改为汇总消息
+func handleBatch(b tb.API, user tb.Recipient, summary *tb.Message, links []linkparser.ParsedLink, unsupported []string) error {
+	bt := &batch{bot: b, msg: summary, unsupported: unsupported}
+	for i, l := range links {
+		item := &batchItem{platform: l.Registration.Name, link: l.Link}
+		bt.items = append(bt.items, item)
+
+		msg, err := b.Send(user, fmt.Sprintf("🔍 [%d/%d] 正在识别链接...", i+1, len(links)))
+		if err != nil {
+			item.err = err.Error()
+			continue
+		}
+		job, err := enqueueLink(l, msg)
+		if err != nil {
+			item.err = err.Error()
+			_, _ = b.Edit(msg, fmt.Sprintf("❌ 加入下载队列失败：%s", err.Error()))
+			continue
+		}
+		item.jobID = job.ID
+		item.state = job.State
+	}
+	utils.InfoWithFormat("[Telegram] 批量解析成功: %d 个链接, %d 个不支持", len(links), len(unsupported))
+
+	batchesMu.Lock()
+	for _, item := range bt.items {
+		if item.jobID != 0 {
+			batches[item.jobID] = bt
+		}
+	}
+	batchesMu.Unlock()
+
+	// 入队期间可能已有任务结束(如曲库已存在), 以队列中的最新状态为准
+	for _, item := range bt.items {
+		if item.jobID == 0 {
+			continue
+		}
+		if job, err := jobs.GlobalQueue.Get(item.jobID); err == nil && job.Finished() {
+			finishBatchItem(job)
+		}
+	}
+	bt.render()
+	return nil
+}
+
+// finishBatchItem 任务结束后更新所属批次的汇总消息
+func finishBatchItem(job *jobs.Job) {
+	batchesMu.Lock()
+	bt, ok := batches[job.ID]
+	delete(batches, job.ID)
+	batchesMu.Unlock()
+	if !ok {
+		return
+	}
+
+	bt.mu.Lock()
+	for _, item := range bt.items {
+		if item.jobID == job.ID {
+			item.state = job.State
+			item.skipped = job.Skipped
+			item.err = job.Error
+		}
+	}
+	bt.mu.Unlock()
+	bt.render()
+}
+
+// render 渲染汇总消息
+func (bt *batch) render() {
+	bt.mu.Lock()
+	defer bt.mu.Unlock()
+
+	var sb strings.Builder
+	done, skipped, failed, canceled, pending := 0, 0, 0, 0, 0
+	fmt.Fprintf(&sb, "📦 已识别 %d 个链接：\n", len(bt.items))
+	for i, item := range bt.items {
+		var status string
+		switch {
+		case item.jobID == 0:
+			status = "❌ 加入队列失败"
+			failed++
+		case item.state == jobs.StateDone && item.skipped:
+			status = "⏭️ 已在曲库中"
+			skipped++
+		case item.state == jobs.StateDone:
+			status = "✅ 已完成"
+			done++
+		case item.state == jobs.StateFailed:
+			status = "❌ 失败"
+			failed++
+		case item.state == jobs.StateCanceled:
+			status = "🚫 已取消"
+			canceled++
+		default:
+			status = "⏳ 处理中"
+			pending++
+		}
+		if item.jobID != 0 {
+			fmt.Fprintf(&sb, "%d. 【%s】任务 #%d %s\n", i+1, item.platform, item.jobID, status)
+		} else {
+			fmt.Fprintf(&sb, "%d. 【%s】%s：%s\n", i+1, item.platform, status, utils.TruncateString(item.err, 100))
+		}
+	}
+	if len(bt.unsupported) > 0 {
+		fmt.Fprintf(&sb, "\n⚠️ 暂不支持的链接（%d 个）：\n%s\n", len(bt.unsupported), strings.Join(bt.unsupported, "\n"))
+	}
+	if pending == 0 {
+		fmt.Fprintf(&sb, "\n🏁 全部结束：完成 %d，跳过 %d，失败 %d，取消 %d", done, skipped, failed, canceled)
+	} else {
+		fmt.Fprintf(&sb, "\n⏳ 进行中 %d / %d", pending, len(bt.items))
+	}
+	if _, err := bt.bot.Edit(bt.msg, sb.String(), tb.NoPreview); err != nil {
+		utils.DebugWithFormat("[Telegram] 更新汇总消息失败: %v", err)
+	}
+}
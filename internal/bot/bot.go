@@ -6,6 +6,7 @@ import (
 	"net/url"
 
 	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/internal/jobs"
 	"github.com/nichuanfang/gymdl/utils"
 	"go.uber.org/zap"
 	tb "gopkg.in/telebot.v4"
@@ -78,6 +79,8 @@ func NewBotApp(cfg *config.Config) (*BotApp, error) {
 	app.registerHandlers()
 	//初始化notifier
 	InitBotNotifier(bot, cfg.Telegram.ChatID)
+	//订阅任务事件
+	jobs.GlobalQueue.Subscribe(app.onJobEvent)
 	return app, nil
 }
 
@@ -1,8 +1,12 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/nichuanfang/gymdl/internal/jobs"
 	"go.uber.org/zap"
 	tb "gopkg.in/telebot.v4"
 )
@@ -53,6 +57,7 @@ func SetCommands(c tb.Context) error {
 	commands := []tb.Command{
 		{Text: "start", Description: "启动 Bot 👋"},
 		{Text: "help", Description: "获取帮助 📜"},
+		{Text: "cancel", Description: "取消下载任务 🚫"},
 	}
 
 	if err := c.Bot().SetCommands(commands); err != nil {
@@ -68,3 +73,32 @@ func SetCommands(c tb.Context) error {
 
 	return nil
 }
+
+// CancelCommand 响应 /cancel <任务ID> 命令，取消排队中或下载中的任务
+func CancelCommand(c tb.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send("用法: /cancel <任务ID>")
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return c.Send("⚠️ 任务ID无效")
+	}
+
+	// 只能取消本会话创建的任务
+	if job, err := jobs.GlobalQueue.Get(id); err == nil && job.ChatID != c.Chat().ID {
+		return c.Send(fmt.Sprintf("⚠️ 任务 #%d 不存在", id))
+	}
+	job, err := jobs.GlobalQueue.Cancel(id)
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return c.Send(fmt.Sprintf("⚠️ 任务 #%d 不存在", id))
+	case err != nil:
+		return c.Send(fmt.Sprintf("⚠️ 任务 #%d 取消失败: %v", id, err))
+	}
+
+	if job.State == jobs.StateCanceled {
+		return c.Send(fmt.Sprintf("🚫 任务 #%d 已取消", id))
+	}
+	return c.Send(fmt.Sprintf("⏳ 任务 #%d 正在取消...", id))
+}
@@ -8,13 +8,11 @@ import (
 )
 
 type Session struct {
-	Text             string         // 用户发送的消息
-	Context          tb.Context     // tg上下文
-	User             *tb.User       // 用户
 	Bot              tb.API         // 机器人
-	Msg              *tb.Message    // 初始化消息对象
-	Link             string         // 有效链接
-	Start            time.Time      // 开始处理时间
+	Msg              tb.Editable    // 任务进度消息
 	Cfg              *config.Config // 配置文件
 	lastProgressTime *time.Time     // 上次发送进度条的时间（使用指针可以检测是否为nil）
 }
+
+// maxFeedbackSongs 入库反馈中最多展示的歌曲数
+const maxFeedbackSongs = 20
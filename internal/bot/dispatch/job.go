@@ -0,0 +1,114 @@
+package dispatch
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nichuanfang/gymdl/internal/jobs"
+	"github.com/nichuanfang/gymdl/utils"
+	tb "gopkg.in/telebot.v4"
+)
+
+// ---------------------------
+// 📥 任务事件渲染
+// ---------------------------
+
+// HandleJob 根据任务事件更新 Telegram 进度消息
+func (s *Session) HandleJob(e jobs.Event) {
+	bot := s.Bot
+	msg := s.Msg
+	job := e.Job
+
+	switch job.State {
+	case jobs.StateQueued:
+		_, _ = bot.Edit(msg, fmt.Sprintf("✅ 已识别【**%s**】链接\n\n⏳ %s（任务 #%d，发送 /cancel %d 可取消）", job.Platform, e.Message, job.ID, job.ID), tb.ModeMarkdown)
+	case jobs.StateDownloading:
+		if e.Message == "" {
+			_, _ = bot.Edit(msg, fmt.Sprintf("✅ 已识别【**%s**】链接\n\n🎵 下载中,请稍候...", job.Platform), tb.ModeMarkdown)
+			return
+		}
+		if e.Progress != nil {
+			s.ReportProgress(progressText(job, e.Progress))
+			return
+		}
+		s.ReportProgress(fmt.Sprintf("✅ 已识别【**%s**】链接\n\n🎵 %s", job.Platform, e.Message))
+	case jobs.StateTidying:
+		_, _ = bot.Edit(msg, fmt.Sprintf("✅ 已识别【**%s**】链接\n\n🎵 %s", job.Platform, e.Message), tb.ModeMarkdown)
+	case jobs.StateDone:
+		if job.Skipped {
+			_, _ = bot.Edit(msg, fmt.Sprintf("⏭️ 任务 #%d：资源已在曲库中，已跳过下载", job.ID))
+			return
+		}
+		if job.Media == jobs.MediaVideo {
+			s.sendVideoFeedback(job.Videos)
+		} else {
+			s.sendMusicFeedback(job.Songs, job.Failures)
+		}
+	case jobs.StateFailed:
+		_, _ = bot.Edit(msg, fmt.Sprintf("❌ 任务 #%d 处理失败：\n```\n%s\n```", job.ID, utils.TruncateString(job.Error, 400)), tb.ModeMarkdown)
+	case jobs.StateCanceled:
+		_, _ = bot.Edit(msg, fmt.Sprintf("🚫 任务 #%d 已取消", job.ID))
+	}
+}
+
+// progressText 渲染结构化进度: 条目序号、进度条、字节数、速度与剩余时间
+func progressText(job *jobs.Job, p *utils.Progress) string {
+	icon := "🎵"
+	if job.Media == jobs.MediaVideo {
+		icon = "📺"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "✅ 已识别【**%s**】链接\n\n", job.Platform)
+	if p.Total > 1 && p.Current > 0 {
+		fmt.Fprintf(&sb, "📦 第 %d/%d 项\n", p.Current, p.Total)
+	}
+	if p.Message != "" {
+		fmt.Fprintf(&sb, "%s %s\n", icon, p.Message)
+	}
+	if p.Item != "" {
+		fmt.Fprintf(&sb, "📄 %s\n", p.Item)
+	}
+	if f := p.Fraction(); f >= 0 {
+		sb.WriteString(progressBar(f) + "\n")
+	}
+	if p.TotalBytes > 0 {
+		fmt.Fprintf(&sb, "💾 %s / %s\n", utils.FormatBytes(p.Bytes), utils.FormatBytes(p.TotalBytes))
+	}
+	if p.Speed > 0 {
+		fmt.Fprintf(&sb, "⚡ %s", utils.FormatSpeed(p.Speed))
+		if p.ETA > 0 {
+			fmt.Fprintf(&sb, "  ⏱ 剩余 %s", utils.FormatETA(p.ETA))
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// progressBar 10 格进度条与百分比
+func progressBar(f float64) string {
+	const cells = 10
+	filled := int(f*cells + 0.5)
+	return fmt.Sprintf("%s%s %.1f%%", strings.Repeat("▓", filled), strings.Repeat("░", cells-filled), f*100)
+}
+
+// ReportProgress 发送进度条，限制发送频率为1秒一次
+func (s *Session) ReportProgress(progress string) {
+	// 检查距离上次发送进度条的时间间隔
+	currentTime := time.Now()
+	// 如果是第一次发送或者时间间隔大于等于1秒，则发送进度条
+	if s.lastProgressTime == nil || currentTime.Sub(*s.lastProgressTime) >= 1*time.Second {
+		utils.DebugWithFormat("[Telegram] 发送进度条: %s", progress)
+		s._sendProgress(progress)
+		// 更新上次发送时间，创建新的时间实例
+		s.lastProgressTime = &currentTime
+	} else {
+		utils.DebugWithFormat("[Telegram] 进度条发送频率限制，距离上次发送间隔: %v", currentTime.Sub(*s.lastProgressTime))
+	}
+}
+
+func (s *Session) _sendProgress(progress string) {
+	bot := s.Bot
+	msg := s.Msg
+	_, _ = bot.Edit(msg, progress, tb.ModeMarkdown)
+}
@@ -9,56 +9,14 @@ import (
 	tb "gopkg.in/telebot.v4"
 )
 
-// HandleMusic
 // ---------------------------
-// 🎵 音乐处理逻辑
+// 🎵 音乐入库反馈
 // ---------------------------
-func (s *Session) HandleMusic(p music.Processor) error {
-	bot := s.Bot
-	msg := s.Msg
-
-	_, _ = bot.Edit(msg, fmt.Sprintf("✅ 已识别【**%s**】链接\n\n🎵 下载中,请稍候...", p.Name()), tb.ModeMarkdown)
-
-	// 下载阶段
-	utils.InfoWithFormat("[Telegram] 下载中...")
-	err := p.DownloadMusic(s.Link, func(progress string) {
-		bot.Edit(msg, fmt.Sprintf("✅ 已识别【**%s**】链接\n\n🎵 %s", p.Name(), progress), tb.ModeMarkdown)
-	})
-	if err != nil {
-		utils.ErrorWithFormat("[Telegram] 下载失败: %v", err)
-		_, _ = bot.Edit(msg, fmt.Sprintf("❌ 下载失败：\n```\n%s\n```", utils.TruncateString(err.Error(), 400)), tb.ModeMarkdown)
-		return nil
-	}
-
-	// 文件整理 & 处理
-	utils.InfoWithFormat("[Telegram] 下载成功，整理中...")
-	bot.Edit(msg, fmt.Sprintf("✅ 已识别【**%s**】链接\n\n🎵 %s", p.Name(), "整理中..."), tb.ModeMarkdown)
-	if err = p.BeforeTidy(); err != nil {
-		utils.ErrorWithFormat("[Telegram] 文件处理失败: %v", err)
-		_, _ = bot.Edit(msg, fmt.Sprintf("⚠️ 文件处理阶段出错：\n```\n%s\n```", utils.TruncateString(err.Error(), 400)), tb.ModeMarkdown)
-		return nil
-	}
-
-	// 入库
-	utils.InfoWithFormat("[Telegram] 整理成功，开始入库...")
-	_, _ = bot.Edit(msg, fmt.Sprintf("✅ 已识别 **%s** 链接\n\n🎵 开始入库...", p.Name()), tb.ModeMarkdown)
-	if err := p.TidyMusic(); err != nil {
-		utils.ErrorWithFormat("[Telegram] 文件入库失败: %v", err)
-		_, _ = bot.Edit(msg, fmt.Sprintf("⚠️ 文件入库失败：\n```\n%s\n```", utils.TruncateString(err.Error(), 400)), tb.ModeMarkdown)
-		return nil
-	}
 
-	// 成功反馈
-	s.sendMusicFeedback(p)
-	utils.InfoWithFormat("[Telegram] 入库成功!")
-	return nil
-}
-
-func (s *Session) sendMusicFeedback(p music.Processor) {
+func (s *Session) sendMusicFeedback(songs []*music.SongInfo, failures []string) {
 	bot := s.Bot
 	msg := s.Msg
 
-	songs := p.Songs()
 	count := len(songs)
 
 	if count == 0 {
@@ -90,13 +48,18 @@ func (s *Session) sendMusicFeedback(p music.Processor) {
 			strings.ToUpper(song.Tidy),
 		)
 
-		_, _ = bot.Edit(msg, successMsg, tb.ModeMarkdown)
+		_, _ = bot.Edit(msg, successMsg+failureNote(failures), tb.ModeMarkdown)
 		return
 	}
 
 	// 🎶 多曲反馈
 	var listBuilder strings.Builder
-	for i, s := range songs {
+	// 列表过长时只展示前几首, 避免超出 Telegram 消息长度限制
+	shown := songs
+	if count > maxFeedbackSongs {
+		shown = songs[:maxFeedbackSongs]
+	}
+	for i, s := range shown {
 		fileSizeMB := float64(s.MusicSize) / 1024.0 / 1024.0
 		listBuilder.WriteString(fmt.Sprintf(
 			"🎵 《%s》\n🎤 艺术家：%s\n💿 专辑：%s\n📊 码率：%s kbps | 大小：%.2f MB",
@@ -108,12 +71,15 @@ func (s *Session) sendMusicFeedback(p music.Processor) {
 		))
 
 		// 如果不是最后一首，添加长横线分隔
-		if i < count-1 {
+		if i < len(shown)-1 {
 			listBuilder.WriteString("\n──────────────────\n")
 		} else {
 			listBuilder.WriteString("\n")
 		}
 	}
+	if count > len(shown) {
+		listBuilder.WriteString(fmt.Sprintf("…… 以及另外 %d 首\n", count-len(shown)))
+	}
 
 	successMsg := fmt.Sprintf(
 		`🎉 *入库成功！*
@@ -125,5 +91,13 @@ func (s *Session) sendMusicFeedback(p music.Processor) {
 ☁️ *入库方式:* %s
 `, count, listBuilder.String(), strings.ToUpper(songs[0].FileExt), strings.ToUpper(songs[0].Tidy))
 
-	_, _ = bot.Edit(msg, successMsg, tb.ModeMarkdown)
+	_, _ = bot.Edit(msg, successMsg+failureNote(failures), tb.ModeMarkdown)
+}
+
+// failureNote 列表下载部分失败时附加的失败说明
+func failureNote(failures []string) string {
+	if len(failures) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n\n⚠️ *%d* 首下载失败：\n```\n%s\n```", len(failures), utils.TruncateString(strings.Join(failures, "\n"), 800))
 }
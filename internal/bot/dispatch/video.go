@@ -3,7 +3,6 @@ package dispatch
 import (
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/nichuanfang/gymdl/processor"
 
@@ -13,60 +12,13 @@ import (
 )
 
 // ---------------------------
-// 📺 视频处理逻辑
+// 📺 视频入库反馈
 // ---------------------------
 
-// ReportProgress 实现video.ProgressReporter接口，限制发送频率为2秒一次
-func (s *Session) ReportProgress(progress string) {
-	// 检查距离上次发送进度条的时间间隔
-	currentTime := time.Now()
-	// 如果是第一次发送或者时间间隔大于等于2秒，则发送进度条
-	if s.lastProgressTime == nil || currentTime.Sub(*s.lastProgressTime) >= 1*time.Second {
-		utils.DebugWithFormat("[Telegram] 发送进度条: %s", progress)
-		s._sendVideoProgress(progress)
-		// 更新上次发送时间，创建新的时间实例
-		s.lastProgressTime = &currentTime
-	} else {
-		utils.DebugWithFormat("[Telegram] 进度条发送频率限制，距离上次发送间隔: %v", currentTime.Sub(*s.lastProgressTime))
-	}
-}
-
-func (s *Session) HandleVideo(p video.Processor) error {
-	bot := s.Bot
-	msg := s.Msg
-
-	_, _ = bot.Edit(msg, fmt.Sprintf("✅ 已识别【**%s**】链接\n\n🎵 开始分析资源,请稍候...", p.Name()), tb.ModeMarkdown)
-
-	// 下载阶段
-	utils.InfoWithFormat("[Telegram] 正在解析下载资源,请稍候...")
-	err := p.Download(s.Link, s)
-	if err != nil {
-		utils.ErrorWithFormat("[Telegram] 下载失败: %v", err)
-		_, _ = bot.Edit(msg, fmt.Sprintf("❌ 下载失败：\n```\n%s\n```", utils.TruncateString(err.Error(), 400)), tb.ModeMarkdown)
-		return nil
-	}
-	// 文件整理 & 处理
-	utils.InfoWithFormat("[Telegram] 下载成功，整理中...")
-	if err := p.Tidy(); err != nil {
-		utils.ErrorWithFormat("[Telegram] 文件整理失败: %v", err)
-		_, _ = bot.Edit(msg, fmt.Sprintf("⚠️ 文件整理失败：\n```\n%s\n```", utils.TruncateString(err.Error(), 400)), tb.ModeMarkdown)
-		return nil
-	}
-	utils.InfoWithFormat("[Telegram] 整理成功，开始入库...")
-	if s.Cfg.Tidy.Mode == 2 {
-		_, _ = bot.Edit(msg, fmt.Sprintf("✅ 已识别 **%s** 链接\n\n🎵 开始入库...", p.Name()), tb.ModeMarkdown)
-	}
-	// 成功反馈
-	s.sendVideoFeedback(p)
-	utils.InfoWithFormat("[Telegram] 入库成功!")
-	return nil
-}
-
-func (s *Session) sendVideoFeedback(p video.Processor) {
+func (s *Session) sendVideoFeedback(videos []*video.VideoInfo) {
 	bot := s.Bot
 	msg := s.Msg
 
-	videos := p.Videos()
 	count := len(videos)
 
 	if count == 0 {
@@ -174,9 +126,3 @@ func (s *Session) sendVideoFeedback(p video.Processor) {
 
 	_, _ = bot.Edit(msg, successMsg, tb.ModeMarkdown)
 }
-
-func (s *Session) _sendVideoProgress(progress string) {
-	bot := s.Bot
-	msg := s.Msg
-	_, _ = bot.Edit(msg, progress, tb.ModeMarkdown)
-}
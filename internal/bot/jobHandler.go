@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"sync"
+
+	"github.com/nichuanfang/gymdl/internal/bot/dispatch"
+	"github.com/nichuanfang/gymdl/internal/jobs"
+)
+
+// 任务事件 -> Telegram 消息
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = make(map[uint64]*dispatch.Session)
+)
+
+// onJobEvent 将来自 Telegram 的任务事件渲染到对应的进度消息
+func (app *BotApp) onJobEvent(e jobs.Event) {
+	job := e.Job
+	if job.Source != jobs.SourceTelegram || job.MessageID == 0 {
+		return
+	}
+
+	sessionsMu.Lock()
+	session, ok := sessions[job.ID]
+	if !ok {
+		session = &dispatch.Session{
+			Bot: app.bot,
+			Msg: jobMessage(job),
+			Cfg: app.cfg,
+		}
+		sessions[job.ID] = session
+	}
+	if job.Finished() {
+		delete(sessions, job.ID)
+	}
+	sessionsMu.Unlock()
+
+	session.HandleJob(e)
+	if job.Finished() {
+		finishBatchItem(job)
+	}
+}
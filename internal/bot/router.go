@@ -14,6 +14,9 @@ func (app *BotApp) registerHandlers() {
 	//帮助信息
 	app.bot.Handle("/help", HelpCommand)
 
+	//取消下载任务
+	app.bot.Handle("/cancel", CancelCommand)
+
 	//指令注册器
 	app.bot.Handle("/setCommands", SetCommands)
 
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nichuanfang/gymdl/core/linkparser"
+	"github.com/nichuanfang/gymdl/internal/gin/response"
+	"github.com/nichuanfang/gymdl/internal/jobs"
+)
+
+// 下载任务处理器
+
+type createJobRequest struct {
+	Link string `json:"link" binding:"required"` // 资源链接
+}
+
+type createJobsRequest struct {
+	Text  string   `json:"text"`  // 包含多个链接的文本
+	Links []string `json:"links"` // 链接列表
+}
+
+// batchFailure 加入队列失败的链接
+type batchFailure struct {
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
+
+// batchResult 批量提交结果
+type batchResult struct {
+	Jobs        []*jobs.Job    `json:"jobs"`                  // 已加入队列的任务
+	Unsupported []string       `json:"unsupported,omitempty"` // 不支持的链接
+	Failed      []batchFailure `json:"failed,omitempty"`      // 加入队列失败的链接
+}
+
+// CreateJob 提交下载任务
+func CreateJob(c *gin.Context) {
+	var req createJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, http.StatusBadRequest, "参数错误", err.Error())
+		return
+	}
+	link, ok := linkparser.ParseLink(req.Link)
+	if !ok {
+		response.Fail(c, http.StatusBadRequest, "暂不支持该类型的链接")
+		return
+	}
+	job, err := jobs.GlobalQueue.Enqueue(newLinkJob(link))
+	if errors.Is(err, jobs.ErrJobDuplicate) {
+		response.Fail(c, http.StatusConflict, "任务已存在", err.Error())
+		return
+	}
+	if err != nil {
+		response.Fail(c, http.StatusInternalServerError, "加入下载队列失败", err.Error())
+		return
+	}
+	response.Success(c, job)
+}
+
+// CreateJobs 批量提交下载任务, 从文本或链接列表中提取全部链接
+func CreateJobs(c *gin.Context) {
+	var req createJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.Fail(c, http.StatusBadRequest, "参数错误", err.Error())
+		return
+	}
+	links := linkparser.ParseLinks(req.Text + "\n" + strings.Join(req.Links, "\n"))
+	if len(links) == 0 {
+		response.Fail(c, http.StatusBadRequest, "未找到链接")
+		return
+	}
+	result := &batchResult{Jobs: make([]*jobs.Job, 0, len(links))}
+	for _, l := range links {
+		if !l.Supported() {
+			result.Unsupported = append(result.Unsupported, l.Link)
+			continue
+		}
+		job, err := jobs.GlobalQueue.Enqueue(newLinkJob(l))
+		if err != nil {
+			result.Failed = append(result.Failed, batchFailure{Link: l.Link, Error: err.Error()})
+			continue
+		}
+		result.Jobs = append(result.Jobs, job)
+	}
+	if len(result.Jobs) == 0 && len(result.Failed) == 0 {
+		response.Fail(c, http.StatusBadRequest, "暂不支持该类型的链接", result.Unsupported...)
+		return
+	}
+	response.Success(c, result)
+}
+
+// newLinkJob 链接下载任务
+func newLinkJob(l linkparser.ParsedLink) *jobs.Job {
+	return &jobs.Job{
+		Kind:     jobs.KindLink,
+		Link:     l.Link,
+		Platform: l.Registration.Name,
+		Resource: l.Resource,
+		Source:   jobs.SourceWeb,
+	}
+}
+
+// ListJobs 任务列表
+func ListJobs(c *gin.Context) {
+	list, err := jobs.GlobalQueue.List()
+	if err != nil {
+		response.Fail(c, http.StatusInternalServerError, "获取任务列表失败", err.Error())
+		return
+	}
+	response.Success(c, list)
+}
+
+// GetJob 任务详情
+func GetJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, http.StatusBadRequest, "任务ID无效")
+		return
+	}
+	job, err := jobs.GlobalQueue.Get(id)
+	if errors.Is(err, jobs.ErrJobNotFound) {
+		response.Fail(c, http.StatusNotFound, "任务不存在")
+		return
+	}
+	if err != nil {
+		response.Fail(c, http.StatusInternalServerError, "获取任务失败", err.Error())
+		return
+	}
+	response.Success(c, job)
+}
+
+// CancelJob 取消任务
+func CancelJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		response.Fail(c, http.StatusBadRequest, "任务ID无效")
+		return
+	}
+	job, err := jobs.GlobalQueue.Cancel(id)
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		response.Fail(c, http.StatusNotFound, "任务不存在")
+		return
+	case errors.Is(err, jobs.ErrJobFinished), errors.Is(err, jobs.ErrJobTidying):
+		response.Fail(c, http.StatusConflict, "任务无法取消", err.Error())
+		return
+	case err != nil:
+		response.Fail(c, http.StatusInternalServerError, "取消任务失败", err.Error())
+		return
+	}
+	response.Success(c, job)
+}
@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nichuanfang/gymdl/internal/gin/controller"
+)
+
+func RegisterJobRoutes(rg *gin.RouterGroup) {
+	group := rg.Group("/jobs")
+	group.POST("", controller.CreateJob)
+	group.POST("/batch", controller.CreateJobs)
+	group.GET("", controller.ListJobs)
+	group.GET("/:id", controller.GetJob)
+	group.DELETE("/:id", controller.CancelJob)
+}
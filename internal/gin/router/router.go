@@ -32,5 +32,7 @@ func SetupRouter(c *config.Config) *gin.Engine {
 	RegisterTextRoutes(apiGroup)
 	// 注册指令处理器路由
 	RegisterCommandRoutes(apiGroup)
+	// 注册下载任务路由
+	RegisterJobRoutes(apiGroup)
 	return engine
 }
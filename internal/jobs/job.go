@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/processor/music"
+	"github.com/nichuanfang/gymdl/processor/video"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+/* ---------------------- 常量 ---------------------- */
+
+// State 任务状态
+type State string
+
+const (
+	StateQueued      State = "queued"      // 排队中
+	StateDownloading State = "downloading" // 下载中
+	StateTidying     State = "tidying"     // 整理中
+	StateDone        State = "done"        // 已完成
+	StateFailed      State = "failed"      // 失败
+	StateCanceled    State = "canceled"    // 已取消
+)
+
+// Kind 任务类型
+type Kind string
+
+const (
+	KindLink Kind = "link" // 链接下载任务(音乐/视频)
+	KindFile Kind = "file" // 本地文件整理任务(目录监控)
+)
+
+// Source 任务来源
+type Source string
+
+const (
+	SourceTelegram Source = "telegram"
+	SourceWeb      Source = "web"
+	SourceMonitor  Source = "monitor"
+)
+
+// Media 任务产出的资源类型
+type Media string
+
+const (
+	MediaMusic Media = "music"
+	MediaVideo Media = "video"
+)
+
+/* ---------------------- 结构体定义 ---------------------- */
+
+// Job 下载任务
+type Job struct {
+	ID       uint64              `json:"id"`
+	Kind     Kind                `json:"kind"`
+	Link     string              `json:"link"`               // 链接(KindLink) 或 文件路径(KindFile)
+	Platform processor.LinkType  `json:"platform"`           // 所属平台,决定任务进入哪个并发通道
+	Resource *processor.Resource `json:"resource,omitempty"` // 链接对应的资源标识
+	Source   Source              `json:"source"`
+	State    State               `json:"state"`
+	Media    Media               `json:"media,omitempty"`
+	Progress string              `json:"progress,omitempty"` // 最近一次进度描述
+	// ProgressDetail 最近一次结构化进度(执行中的任务), 供 Web 端渲染进度条
+	ProgressDetail *utils.Progress    `json:"progress_detail,omitempty"`
+	Error          string             `json:"error,omitempty"`
+	Skipped        bool               `json:"skipped,omitempty"`    // 资源已在曲库中,未重复下载
+	ChatID         int64              `json:"chat_id,omitempty"`    // Telegram 会话ID(来源为telegram时)
+	MessageID      int                `json:"message_id,omitempty"` // Telegram 进度消息ID(来源为telegram时)
+	Songs          []*music.SongInfo  `json:"songs,omitempty"`
+	Failures       []string           `json:"failures,omitempty"` // 列表下载中失败的歌曲及原因
+	Videos         []*video.VideoInfo `json:"videos,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+	return j.State == StateDone || j.State == StateFailed || j.State == StateCanceled
+}
+
+// lane 任务所属的并发通道
+func (j *Job) lane() string {
+	if j.Kind == KindFile {
+		return string(SourceMonitor)
+	}
+	return string(j.Platform)
+}
+
+// Target 任务目标的日志描述,优先使用资源标识
+func (j *Job) Target() string {
+	if j.Resource != nil {
+		return j.Resource.String()
+	}
+	return j.Link
+}
+
+// clone 拷贝任务快照,避免监听者与worker并发读写
+func (j *Job) clone() *Job {
+	c := *j
+	return &c
+}
@@ -0,0 +1,429 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// 持久化下载任务队列
+
+/* ---------------------- 结构体定义 ---------------------- */
+
+// Event 任务事件(状态变化/进度更新)
+type Event struct {
+	Job      *Job            // 任务快照
+	Message  string          // 进度描述
+	Progress *utils.Progress // 结构化进度(仅进度更新事件)
+}
+
+// Listener 任务事件监听者
+type Listener func(e Event)
+
+// Handler 任务处理器
+type Handler func(ctx context.Context, t *Task) error
+
+// lane 平台并发通道
+type lane struct {
+	mu      sync.Mutex
+	pending []uint64
+	notify  chan struct{}
+}
+
+type Queue struct {
+	cfg       *config.Config
+	store     *Store
+	mu        sync.Mutex
+	lanes     map[string]*lane
+	running   map[uint64]*Job               // 已分发(排队或执行中)的任务
+	cancels   map[uint64]context.CancelFunc // 执行中任务的取消函数
+	listeners []Listener
+	ctx       context.Context
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+var (
+	// GlobalQueue 全局任务队列
+	GlobalQueue *Queue
+
+	handlersMu sync.RWMutex
+	handlers   = map[Kind]Handler{
+		KindLink: handleLink,
+	}
+)
+
+/* ---------------------- 初始化 ---------------------- */
+
+// InitQueue 初始化全局任务队列
+func InitQueue(cfg *config.Config) error {
+	q, err := newQueue(cfg)
+	if err != nil {
+		return err
+	}
+	GlobalQueue = q
+	return nil
+}
+
+// newQueue 打开任务数据库并创建队列
+func newQueue(cfg *config.Config) (*Queue, error) {
+	store, err := OpenStore(cfg.Job.DBFile)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Queue{
+		cfg:     cfg,
+		store:   store,
+		lanes:   make(map[string]*lane),
+		running: make(map[uint64]*Job),
+		cancels: make(map[uint64]context.CancelFunc),
+		ctx:     ctx,
+		cancel:  cancel,
+	}, nil
+}
+
+// RegisterHandler 注册任务处理器
+func RegisterHandler(kind Kind, h Handler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[kind] = h
+}
+
+/* ---------------------- 核心方法 ---------------------- */
+
+// Start 恢复上次未完成的任务
+func (q *Queue) Start() {
+	jobs, err := q.store.List()
+	if err != nil {
+		utils.ErrorWithFormat("[Job] 读取任务列表失败: %v", err)
+		return
+	}
+	restored := 0
+	for _, job := range jobs {
+		if job.Finished() || q.dispatched(job.ID) {
+			continue
+		}
+		if job.State != StateQueued {
+			job.State = StateQueued
+			job.Progress = "服务重启，任务已恢复"
+			job.UpdatedAt = time.Now()
+			if err := q.store.Save(job); err != nil {
+				utils.WarnWithFormat("[Job] 任务 #%d 恢复失败: %v", job.ID, err)
+				continue
+			}
+		}
+		q.emit(Event{Job: job.clone(), Message: job.Progress})
+		if q.dispatch(job) {
+			restored++
+		}
+	}
+	if restored > 0 {
+		utils.InfoWithFormat("[Job] 已恢复 %d 个未完成任务", restored)
+	}
+}
+
+// Stop 停止队列,未完成的任务会在下次启动时恢复
+func (q *Queue) Stop() {
+	q.cancel()
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		utils.Warning("仍有任务在执行，将在下次启动时恢复")
+	}
+	if err := q.store.Close(); err != nil {
+		utils.WarnWithFormat("[Job] 关闭任务数据库失败: %v", err)
+	}
+}
+
+// Enqueue 新建任务并加入队列
+func (q *Queue) Enqueue(job *Job) (*Job, error) {
+	if dup := q.findActive(job.Resource); dup != nil {
+		return nil, fmt.Errorf("%w: 任务 #%d", ErrJobDuplicate, dup.ID)
+	}
+	now := time.Now()
+	job.State = StateQueued
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	if err := q.store.Save(job); err != nil {
+		return nil, fmt.Errorf("保存任务失败: %w", err)
+	}
+	utils.InfoWithFormat("[Job] 任务 #%d 已加入队列: %s", job.ID, job.Target())
+	snapshot := job.clone()
+	q.emit(Event{Job: snapshot, Message: "已加入下载队列"})
+	q.dispatch(job)
+	return snapshot, nil
+}
+
+// Cancel 取消任务: 排队中的任务直接移出队列,执行中的任务中止下载
+func (q *Queue) Cancel(id uint64) (*Job, error) {
+	q.mu.Lock()
+	job, ok := q.running[id]
+	if !ok {
+		q.mu.Unlock()
+		job, err := q.store.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %s", ErrJobFinished, job.State)
+	}
+	if job.State == StateTidying {
+		q.mu.Unlock()
+		return nil, ErrJobTidying
+	}
+	if cancel, ok := q.cancels[id]; ok {
+		// 执行中,由worker负责收尾
+		snapshot := job.clone()
+		q.mu.Unlock()
+		cancel()
+		utils.InfoWithFormat("[Job] 任务 #%d 正在取消...", id)
+		return snapshot, nil
+	}
+	// 尚未开始执行,从通道中移除
+	delete(q.running, id)
+	l := q.lanes[job.lane()]
+	job.State = StateCanceled
+	job.Progress = ""
+	job.UpdatedAt = time.Now()
+	snapshot := job.clone()
+	q.mu.Unlock()
+
+	if l != nil {
+		l.mu.Lock()
+		for i, pid := range l.pending {
+			if pid == id {
+				l.pending = append(l.pending[:i], l.pending[i+1:]...)
+				break
+			}
+		}
+		l.mu.Unlock()
+	}
+	if err := q.store.Save(snapshot); err != nil {
+		utils.WarnWithFormat("[Job] 任务 #%d 状态保存失败: %v", id, err)
+	}
+	utils.InfoWithFormat("[Job] 任务 #%d 已取消", id)
+	q.emit(Event{Job: snapshot})
+	return snapshot, nil
+}
+
+// Subscribe 订阅任务事件
+func (q *Queue) Subscribe(l Listener) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.listeners = append(q.listeners, l)
+}
+
+// Get 获取任务,执行中的任务返回内存中的最新状态
+func (q *Queue) Get(id uint64) (*Job, error) {
+	q.mu.Lock()
+	if job, ok := q.running[id]; ok {
+		snapshot := job.clone()
+		q.mu.Unlock()
+		return snapshot, nil
+	}
+	q.mu.Unlock()
+	return q.store.Get(id)
+}
+
+// List 列出所有任务
+func (q *Queue) List() ([]*Job, error) {
+	jobs, err := q.store.List()
+	if err != nil {
+		return nil, err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, job := range jobs {
+		if r, ok := q.running[job.ID]; ok {
+			jobs[i] = r.clone()
+		}
+	}
+	return jobs, nil
+}
+
+/* ---------------------- 调度 ---------------------- */
+
+// dispatch 将任务放入所属平台的通道,已分发的任务不会重复入队
+func (q *Queue) dispatch(job *Job) bool {
+	q.mu.Lock()
+	if _, ok := q.running[job.ID]; ok {
+		q.mu.Unlock()
+		return false
+	}
+	q.running[job.ID] = job
+	l := q.laneOf(job.lane())
+	q.mu.Unlock()
+
+	l.mu.Lock()
+	l.pending = append(l.pending, job.ID)
+	l.mu.Unlock()
+	select {
+	case l.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// findActive 查找相同资源且尚未结束的任务
+func (q *Queue) findActive(res *processor.Resource) *Job {
+	if res == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.running {
+		if job.Resource != nil && job.Resource.Key() == res.Key() {
+			return job.clone()
+		}
+	}
+	return nil
+}
+
+// dispatched 任务是否已分发
+func (q *Queue) dispatched(id uint64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.running[id]
+	return ok
+}
+
+// laneOf 获取平台通道,不存在时按配置的并发数启动worker(需持有q.mu)
+func (q *Queue) laneOf(key string) *lane {
+	if l, ok := q.lanes[key]; ok {
+		return l
+	}
+	l := &lane{notify: make(chan struct{}, 1)}
+	q.lanes[key] = l
+	n := q.concurrency(key)
+	for i := 0; i < n; i++ {
+		q.wg.Add(1)
+		go q.worker(l)
+	}
+	utils.DebugWithFormat("[Job] 已创建任务通道: %s (并发数 %d)", key, n)
+	return l
+}
+
+// concurrency 平台并发数
+func (q *Queue) concurrency(key string) int {
+	if n, ok := q.cfg.Job.Platforms[key]; ok && n > 0 {
+		return n
+	}
+	return q.cfg.Job.Concurrency
+}
+
+// worker 从通道中依次取出任务执行
+func (q *Queue) worker(l *lane) {
+	defer q.wg.Done()
+	for {
+		l.mu.Lock()
+		if len(l.pending) > 0 {
+			id := l.pending[0]
+			l.pending = l.pending[1:]
+			more := len(l.pending) > 0
+			l.mu.Unlock()
+			if more {
+				// 唤醒其他空闲worker
+				select {
+				case l.notify <- struct{}{}:
+				default:
+				}
+			}
+			q.run(id)
+			continue
+		}
+		l.mu.Unlock()
+
+		select {
+		case <-l.notify:
+		case <-q.ctx.Done():
+			return
+		}
+	}
+}
+
+// run 执行单个任务
+func (q *Queue) run(id uint64) {
+	if q.ctx.Err() != nil {
+		// 队列已停止,保持排队状态等待下次启动
+		return
+	}
+	q.mu.Lock()
+	job := q.running[id]
+	if job == nil {
+		// 已在排队时被取消
+		q.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(q.ctx)
+	q.cancels[id] = cancel
+	q.mu.Unlock()
+	defer func() {
+		cancel()
+		q.mu.Lock()
+		delete(q.cancels, id)
+		delete(q.running, id)
+		q.mu.Unlock()
+	}()
+
+	handlersMu.RLock()
+	h := handlers[job.Kind]
+	handlersMu.RUnlock()
+
+	t := &Task{Job: job, Cfg: q.cfg, q: q}
+	var err error
+	if h == nil {
+		err = fmt.Errorf("未注册的任务类型: %s", job.Kind)
+	} else {
+		t.SetState(StateDownloading, "")
+		err = q.safeRun(ctx, h, t)
+	}
+
+	switch {
+	case err != nil && q.ctx.Err() != nil:
+		// 服务停止导致中断,保留当前状态,下次启动时恢复
+		utils.WarnWithFormat("[Job] 任务 #%d 因服务停止中断", job.ID)
+	case err != nil && ctx.Err() != nil:
+		utils.InfoWithFormat("[Job] 任务 #%d 已取消", job.ID)
+		t.SetState(StateCanceled, "")
+	case err != nil:
+		utils.ErrorWithFormat("[Job] 任务 #%d 执行失败: %v", job.ID, err)
+		q.mu.Lock()
+		job.Error = err.Error()
+		q.mu.Unlock()
+		t.SetState(StateFailed, "")
+	default:
+		utils.InfoWithFormat("[Job] 任务 #%d 执行完成", job.ID)
+		t.SetState(StateDone, "")
+	}
+}
+
+// safeRun 执行处理器,捕获处理器内部的 panic
+func (q *Queue) safeRun(ctx context.Context, h Handler, t *Task) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = errors.New(fmt.Sprint("处理器异常: ", rec))
+		}
+	}()
+	return h(ctx, t)
+}
+
+// emit 通知所有监听者
+func (q *Queue) emit(e Event) {
+	q.mu.Lock()
+	listeners := append([]Listener(nil), q.listeners...)
+	q.mu.Unlock()
+	for _, l := range listeners {
+		l(e)
+	}
+}
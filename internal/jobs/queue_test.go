@@ -0,0 +1,378 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+func TestMain(m *testing.M) {
+	_ = utils.InitLogger(&config.LogConfig{Mode: 1, Level: 4})
+	RegisterHandler(kindTest, handleTest)
+	os.Exit(m.Run())
+}
+
+/* ---------------------- 测试处理器 ---------------------- */
+
+// kindTest 测试任务: Link 决定处理结果
+const kindTest Kind = "test"
+
+var (
+	startedMu sync.Mutex
+	started   = make(map[uint64]chan struct{}) // 任务开始执行时关闭
+	handled   = make(map[uint64]int)           // 任务执行次数
+)
+
+func handleTest(ctx context.Context, t *Task) error {
+	startedMu.Lock()
+	handled[t.Job.ID]++
+	if ch, ok := started[t.Job.ID]; ok {
+		close(ch)
+		delete(started, t.Job.ID)
+	}
+	startedMu.Unlock()
+
+	switch t.Job.Link {
+	case "fail":
+		return errors.New("下载失败")
+	case "panic":
+		panic("处理器崩溃")
+	case "block":
+		// 阻塞直到取消或服务停止
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return nil
+}
+
+// waitStarted 等待任务开始执行
+func waitStarted(t *testing.T, ch chan struct{}) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("任务未开始执行")
+	}
+}
+
+func handledCount(id uint64) int {
+	startedMu.Lock()
+	defer startedMu.Unlock()
+	return handled[id]
+}
+
+/* ---------------------- 辅助方法 ---------------------- */
+
+// newTestQueue 使用临时目录中的任务数据库创建队列, 任务ID从新数据库重新分配, 清空执行记录
+func newTestQueue(t *testing.T, dbFile string) *Queue {
+	t.Helper()
+	startedMu.Lock()
+	clear(started)
+	clear(handled)
+	startedMu.Unlock()
+	q, err := newQueue(&config.Config{Job: &config.JobConfig{DBFile: dbFile, Concurrency: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+// enqueue 新建测试任务, 返回任务与开始执行时关闭的通道
+func enqueue(t *testing.T, q *Queue, link string, res *processor.Resource) (*Job, chan struct{}) {
+	t.Helper()
+	job := &Job{Kind: kindTest, Link: link, Platform: "test", Resource: res}
+	// 先分配ID再登记, 避免任务在登记前已开始执行
+	if err := q.store.Save(job); err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan struct{})
+	startedMu.Lock()
+	started[job.ID] = ch
+	startedMu.Unlock()
+	snapshot, err := q.Enqueue(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snapshot, ch
+}
+
+// waitState 等待任务进入指定状态
+func waitState(t *testing.T, q *Queue, id uint64, state State) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := q.Get(id)
+		// 结束状态还需等待任务移出执行列表
+		if err == nil && job.State == state && !q.dispatched(id) == job.Finished() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("任务 #%d 状态 = %v, 期望 %s", id, job, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+/* ---------------------- 持久化 ---------------------- */
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "jobs.db")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := []*Job{
+		{Kind: KindLink, Link: "a", State: StateDone},
+		{Kind: KindLink, Link: "b", State: StateQueued},
+		{Kind: KindFile, Link: "c", State: StateDownloading},
+	}
+	for i, job := range jobs {
+		if err := s.Save(job); err != nil {
+			t.Fatal(err)
+		}
+		if job.ID != uint64(i+1) {
+			t.Errorf("任务ID = %d, 期望 %d", job.ID, i+1)
+		}
+	}
+	// 已有ID的任务覆盖保存
+	jobs[1].State = StateFailed
+	if err := s.Save(jobs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 关闭后保存被忽略
+	if err := s.Save(&Job{Link: "d"}); err != nil {
+		t.Errorf("关闭后 Save() = %v, 期望忽略", err)
+	}
+
+	// 重新打开后数据仍在
+	s, err = OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		link  string
+		state State
+	}{{"a", StateDone}, {"b", StateFailed}, {"c", StateDownloading}}
+	if len(list) != len(want) {
+		t.Fatalf("List() 返回 %d 个任务, 期望 %d 个", len(list), len(want))
+	}
+	for i, w := range want {
+		if list[i].ID != uint64(i+1) || list[i].Link != w.link || list[i].State != w.state {
+			t.Errorf("List()[%d] = #%d %s %s, 期望 #%d %s %s", i, list[i].ID, list[i].Link, list[i].State, i+1, w.link, w.state)
+		}
+	}
+	if _, err := s.Get(99); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Get(99) = %v, 期望 ErrJobNotFound", err)
+	}
+}
+
+/* ---------------------- 执行结果 ---------------------- */
+
+func TestQueueRunStates(t *testing.T) {
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer q.Stop()
+
+	tests := []struct {
+		link  string
+		state State
+		err   string
+	}{
+		{"ok", StateDone, ""},
+		{"fail", StateFailed, "下载失败"},
+		{"panic", StateFailed, "处理器异常: 处理器崩溃"},
+	}
+	for _, tt := range tests {
+		job, _ := enqueue(t, q, tt.link, nil)
+		got := waitState(t, q, job.ID, tt.state)
+		if got.Error != tt.err {
+			t.Errorf("%s: Error = %q, 期望 %q", tt.link, got.Error, tt.err)
+		}
+		// 结束状态已持久化
+		if saved, err := q.store.Get(job.ID); err != nil || saved.State != tt.state {
+			t.Errorf("%s: 持久化状态 = %v, %v", tt.link, saved, err)
+		}
+	}
+
+	// 未注册的任务类型
+	job, err := q.Enqueue(&Job{Kind: "unknown", Platform: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := waitState(t, q, job.ID, StateFailed); got.Error == "" {
+		t.Error("未注册的任务类型应记录错误")
+	}
+}
+
+/* ---------------------- 取消 ---------------------- */
+
+func TestQueueCancel(t *testing.T) {
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer q.Stop()
+
+	// 并发数为 1: 第一个任务执行中, 第二个任务排队
+	running, runningStarted := enqueue(t, q, "block", nil)
+	waitStarted(t, runningStarted)
+	queued, _ := enqueue(t, q, "ok", nil)
+
+	// 排队中的任务直接取消, 不会再执行
+	snapshot, err := q.Cancel(queued.ID)
+	if err != nil || snapshot.State != StateCanceled {
+		t.Fatalf("取消排队任务: %v, %v", snapshot, err)
+	}
+	// 执行中的任务中止下载
+	if _, err := q.Cancel(running.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, q, running.ID, StateCanceled)
+	waitState(t, q, queued.ID, StateCanceled)
+	if n := handledCount(queued.ID); n != 0 {
+		t.Errorf("已取消的排队任务执行了 %d 次", n)
+	}
+
+	// 后续任务正常执行
+	next, _ := enqueue(t, q, "ok", nil)
+	waitState(t, q, next.ID, StateDone)
+
+	tests := []struct {
+		id  uint64
+		err error
+	}{
+		{next.ID, ErrJobFinished},
+		{running.ID, ErrJobFinished},
+		{999, ErrJobNotFound},
+	}
+	for _, tt := range tests {
+		if _, err := q.Cancel(tt.id); !errors.Is(err, tt.err) {
+			t.Errorf("Cancel(%d) = %v, 期望 %v", tt.id, err, tt.err)
+		}
+	}
+}
+
+/* ---------------------- 去重 ---------------------- */
+
+func TestQueueDuplicate(t *testing.T) {
+	q := newTestQueue(t, filepath.Join(t.TempDir(), "jobs.db"))
+	defer q.Stop()
+
+	res := &processor.Resource{Platform: processor.LinkNetEase, Kind: processor.ResourceTrack, ID: "1"}
+	first, firstStarted := enqueue(t, q, "block", res)
+	waitStarted(t, firstStarted)
+
+	tests := []struct {
+		res *processor.Resource
+		dup bool
+	}{
+		// 同一资源的不同链接
+		{&processor.Resource{Platform: processor.LinkNetEase, Kind: processor.ResourceTrack, ID: "1", URL: "https://163cn.tv/x"}, true},
+		{&processor.Resource{Platform: processor.LinkNetEase, Kind: processor.ResourceAlbum, ID: "1"}, false},
+		{&processor.Resource{Platform: processor.LinkQQMusic, Kind: processor.ResourceTrack, ID: "1"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		_, err := q.Enqueue(&Job{Kind: kindTest, Link: "ok", Platform: "other", Resource: tt.res})
+		if got := errors.Is(err, ErrJobDuplicate); got != tt.dup {
+			t.Errorf("Enqueue(%v) = %v, 期望重复 = %v", tt.res, err, tt.dup)
+		}
+	}
+
+	// 结束后可以再次下载
+	if _, err := q.Cancel(first.ID); err != nil {
+		t.Fatal(err)
+	}
+	waitState(t, q, first.ID, StateCanceled)
+	again, _ := enqueue(t, q, "ok", res)
+	waitState(t, q, again.ID, StateDone)
+}
+
+/* ---------------------- 恢复 ---------------------- */
+
+func TestQueueResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+
+	// 服务停止时中断的任务保持原状态
+	q := newTestQueue(t, path)
+	interrupted, started := enqueue(t, q, "block", nil)
+	waitStarted(t, started)
+	q.Stop()
+
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := s.Get(interrupted.ID)
+	if err != nil || saved.State != StateDownloading {
+		t.Fatalf("中断的任务状态 = %v, %v, 期望 %s", saved, err, StateDownloading)
+	}
+	// 上次遗留的其他任务
+	jobs := []*Job{
+		{Kind: kindTest, Link: "ok", Platform: "test", State: StateQueued},
+		{Kind: kindTest, Link: "ok", Platform: "test", State: StateTidying},
+		{Kind: kindTest, Link: "ok", Platform: "test", State: StateDone},
+		{Kind: kindTest, Link: "ok", Platform: "test", State: StateFailed},
+		{Kind: kindTest, Link: "ok", Platform: "test", State: StateCanceled},
+	}
+	for _, job := range jobs {
+		if err := s.Save(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.Close()
+
+	// 阻塞任务恢复后改为正常完成
+	saved.Link = "ok"
+	s, _ = OpenStore(path)
+	_ = s.Save(saved)
+	_ = s.Close()
+
+	q = newTestQueue(t, path)
+	defer q.Stop()
+	q.Start()
+
+	tests := []struct {
+		id      uint64
+		state   State
+		resumed bool
+	}{
+		{interrupted.ID, StateDone, true},
+		{jobs[0].ID, StateDone, true},
+		{jobs[1].ID, StateDone, true},
+		{jobs[2].ID, StateDone, false},
+		{jobs[3].ID, StateFailed, false},
+		{jobs[4].ID, StateCanceled, false},
+	}
+	for _, tt := range tests {
+		waitState(t, q, tt.id, tt.state)
+	}
+	for _, tt := range tests {
+		want := 0
+		if tt.resumed {
+			want = 1
+		}
+		if n := handledCount(tt.id); n != want {
+			t.Errorf("任务 #%d 执行了 %d 次, 期望 %d 次", tt.id, n, want)
+		}
+	}
+
+	// 重复调用 Start 不会重复分发
+	q.Start()
+	time.Sleep(50 * time.Millisecond)
+	if n := handledCount(jobs[0].ID); n != 1 {
+		t.Errorf("任务 #%d 执行了 %d 次, 期望 1 次", jobs[0].ID, n)
+	}
+}
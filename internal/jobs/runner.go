@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nichuanfang/gymdl/core"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/processor/music"
+	"github.com/nichuanfang/gymdl/processor/video"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// 链接下载任务执行逻辑
+
+// handleLink 解析链接并交给对应的音乐/视频处理器
+func handleLink(ctx context.Context, t *Task) error {
+	// 按入队时识别的平台获取处理器,短链由处理器下载时还原
+	link := t.Job.Link
+	reg := processor.Get(t.Job.Platform)
+	if reg == nil {
+		reg = processor.Lookup(link)
+	}
+	if reg == nil {
+		return errors.New("暂不支持该类型的链接")
+	}
+
+	// 每个任务使用独立的处理器实例
+	switch p := reg.Factory().(type) {
+	case music.Processor:
+		p.Init(t.Cfg)
+		return runMusic(ctx, t, link, p)
+	case video.Processor:
+		p.Init(t.Cfg)
+		return runVideo(ctx, t, link, p)
+	default:
+		return fmt.Errorf("未知处理器类型: %v", p)
+	}
+}
+
+// runMusic 🎵 音乐处理流程: 下载 -> 整理前处理 -> 入库
+func runMusic(ctx context.Context, t *Task, link string, p music.Processor) error {
+	t.Update(func(job *Job) { job.Media = MediaMusic })
+
+	utils.InfoWithFormat("[Job] 任务 #%d 下载中...", t.Job.ID)
+	if err := p.DownloadMusic(ctx, link, t.Report); err != nil {
+		if errors.Is(err, core.ErrInLibrary) {
+			return skip(t)
+		}
+		return fmt.Errorf("下载失败: %w", err)
+	}
+
+	utils.InfoWithFormat("[Job] 任务 #%d 下载成功，整理中...", t.Job.ID)
+	t.SetState(StateTidying, "整理中...")
+	// 加密格式先解密
+	if p.NeedRemoveDRM() {
+		t.Report(utils.Progress{Phase: utils.PhaseDecrypt, Message: "解密中..."})
+		if err := p.DRMRemove(); err != nil {
+			return fmt.Errorf("解密失败: %w", err)
+		}
+	}
+	if err := p.BeforeTidy(); err != nil {
+		return fmt.Errorf("文件处理阶段出错: %w", err)
+	}
+
+	t.Report(utils.Progress{Phase: utils.PhaseTidy, Message: "开始入库..."})
+	if err := p.TidyMusic(ctx); err != nil {
+		if errors.Is(err, core.ErrInLibrary) {
+			return skip(t)
+		}
+		return fmt.Errorf("文件入库失败: %w", err)
+	}
+
+	songs := p.Songs()
+	for _, song := range songs {
+		record(t, &core.LibraryEntry{
+			Platform: string(p.Name()),
+			ID:       song.SongID,
+			Hash:     song.Hash,
+			Media:    string(MediaMusic),
+			Title:    song.SongName,
+			Artist:   song.SongArtists,
+			Album:    song.SongAlbum,
+			Tidy:     song.Tidy,
+			Source:   source(t),
+		})
+	}
+	var failures []string
+	if pp, ok := p.(music.PartialProcessor); ok {
+		failures = pp.Failures()
+	}
+	t.Update(func(job *Job) {
+		job.Songs = songs
+		job.Failures = failures
+	})
+	return nil
+}
+
+// runVideo 📺 视频处理流程: 下载 -> 整理入库
+func runVideo(ctx context.Context, t *Task, link string, p video.Processor) error {
+	t.Update(func(job *Job) { job.Media = MediaVideo })
+
+	utils.InfoWithFormat("[Job] 任务 #%d 正在解析下载资源...", t.Job.ID)
+	if err := p.Download(ctx, link, t); err != nil {
+		if errors.Is(err, core.ErrInLibrary) {
+			return skip(t)
+		}
+		return fmt.Errorf("下载失败: %w", err)
+	}
+
+	utils.InfoWithFormat("[Job] 任务 #%d 下载成功，整理中...", t.Job.ID)
+	t.SetState(StateTidying, "整理中...")
+	err := p.Tidy(ctx)
+	if errors.Is(err, core.ErrInLibrary) {
+		return skip(t)
+	}
+
+	// 只记录全部文件已整理的视频, 部分失败时其余视频仍然入库
+	videos := p.Videos()
+	for _, v := range videos {
+		if !v.Stored {
+			continue
+		}
+		record(t, &core.LibraryEntry{
+			Platform: string(p.Name()),
+			ID:       v.VideoID,
+			Hash:     v.Hash,
+			Media:    string(MediaVideo),
+			Title:    v.Title,
+			Artist:   v.Author,
+			Tidy:     v.Tidy,
+			Source:   source(t),
+		})
+	}
+	t.Update(func(job *Job) { job.Videos = videos })
+	if err != nil {
+		return fmt.Errorf("文件整理失败: %w", err)
+	}
+	return nil
+}
+
+// source 入库记录的来源链接,优先使用规范链接
+func source(t *Task) string {
+	if t.Job.Resource != nil && t.Job.Resource.URL != "" {
+		return t.Job.Resource.URL
+	}
+	return t.Job.Link
+}
+
+// skip 资源已在曲库中,任务直接完成
+func skip(t *Task) error {
+	utils.InfoWithFormat("[Job] 任务 #%d 资源已在曲库中，跳过下载", t.Job.ID)
+	t.Update(func(job *Job) { job.Skipped = true })
+	return nil
+}
+
+// record 将入库结果写入曲库索引
+func record(t *Task, e *core.LibraryEntry) {
+	if err := core.GlobalLibrary.Add(e); err != nil {
+		utils.WarnWithFormat("[Job] 任务 #%d 写入曲库索引失败: %v", t.Job.ID, err)
+	}
+}
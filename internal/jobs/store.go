@@ -0,0 +1,126 @@
+package jobs
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 任务持久化(BoltDB)
+
+var jobsBucket = []byte("jobs")
+
+var (
+	// ErrJobNotFound 任务不存在
+	ErrJobNotFound = errors.New("任务不存在")
+	// ErrJobFinished 任务已结束
+	ErrJobFinished = errors.New("任务已结束")
+	// ErrJobTidying 任务正在入库,无法取消
+	ErrJobTidying = errors.New("任务正在入库,无法取消")
+	// ErrJobDuplicate 相同资源的任务尚未结束
+	ErrJobDuplicate = errors.New("相同资源的任务正在进行中")
+)
+
+type Store struct {
+	db     *bolt.DB
+	mu     sync.RWMutex
+	closed bool // 已关闭, 停止服务后仍在退出的任务不再写入
+}
+
+// OpenStore 打开任务数据库,文件不存在时自动创建
+func OpenStore(path string) (*Store, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建任务数据库目录失败: %w", err)
+		}
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("打开任务数据库失败: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(jobsBucket)
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("初始化任务数据库失败: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Save 保存任务,新任务自动分配自增ID; 数据库已关闭时忽略,任务保持上次保存的状态,下次启动时恢复
+func (s *Store) Save(job *Job) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(jobsBucket)
+		if job.ID == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			job.ID = id
+		}
+		data, err := json.Marshal(job)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(job.ID), data)
+	})
+}
+
+// Get 获取任务
+func (s *Store) Get(id uint64) (*Job, error) {
+	var job *Job
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(jobsBucket).Get(itob(id))
+		if data == nil {
+			return ErrJobNotFound
+		}
+		job = &Job{}
+		return json.Unmarshal(data, job)
+	})
+	return job, err
+}
+
+// List 按ID顺序列出所有任务
+func (s *Store) List() ([]*Job, error) {
+	jobs := make([]*Job, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobsBucket).ForEach(func(k, v []byte) error {
+			job := &Job{}
+			if err := json.Unmarshal(v, job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	return jobs, err
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return s.db.Close()
+}
+
+// itob 任务ID转为大端字节序,保证遍历顺序与ID一致
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
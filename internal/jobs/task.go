@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// Task 任务执行上下文,供处理器更新状态与进度
+type Task struct {
+	Job *Job
+	Cfg *config.Config
+	q   *Queue
+}
+
+// SetState 切换任务状态并持久化
+func (t *Task) SetState(state State, message string) {
+	t.q.mu.Lock()
+	t.Job.State = state
+	if message != "" {
+		t.Job.Progress = message
+	}
+	// 结构化进度只描述执行中的任务
+	t.Job.ProgressDetail = nil
+	t.Job.UpdatedAt = time.Now()
+	snapshot := t.Job.clone()
+	t.q.mu.Unlock()
+
+	if err := t.q.store.Save(snapshot); err != nil {
+		utils.WarnWithFormat("[Job] 任务 #%d 状态保存失败: %v", snapshot.ID, err)
+	}
+	t.q.emit(Event{Job: snapshot, Message: message})
+}
+
+// Report 更新任务进度(仅通知监听者,不持久化), 同时保留文本描述供旧接口与日志使用
+func (t *Task) Report(progress utils.Progress) {
+	t.q.mu.Lock()
+	progress.JobID = t.Job.ID
+	text := progress.String()
+	t.Job.Progress = text
+	t.Job.ProgressDetail = &progress
+	t.Job.UpdatedAt = time.Now()
+	snapshot := t.Job.clone()
+	t.q.mu.Unlock()
+
+	utils.DebugWithFormat("[Job] 任务 #%d %s", snapshot.ID, text)
+	t.q.emit(Event{Job: snapshot, Message: text, Progress: &progress})
+}
+
+// ReportProgress 实现 video.ProgressReporter 接口
+func (t *Task) ReportProgress(progress utils.Progress) {
+	t.Report(progress)
+}
+
+// Update 修改任务字段(如结果列表),在下一次状态切换时持久化
+func (t *Task) Update(fn func(job *Job)) {
+	t.q.mu.Lock()
+	defer t.q.mu.Unlock()
+	fn(t.Job)
+}
@@ -1,21 +1,72 @@
 package monitor
 
 import (
+	"context"
 	"errors"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 
 	"github.com/nichuanfang/gymdl/config"
 	"github.com/nichuanfang/gymdl/core"
+	"github.com/nichuanfang/gymdl/internal/jobs"
 	"github.com/nichuanfang/gymdl/processor"
 	"github.com/nichuanfang/gymdl/processor/music"
+	"github.com/nichuanfang/gymdl/storage"
 	"github.com/nichuanfang/gymdl/utils"
 )
 
 var umTempDir = filepath.Join("data", "temp", "um")
 
+func init() {
+	jobs.RegisterHandler(jobs.KindFile, handleFileJob)
+}
+
+// handleFileJob 目录监控任务: 解密 -> 整理 -> 入库通知
+func handleFileJob(ctx context.Context, t *jobs.Task) error {
+	path := t.Job.Link
+	// 按源文件哈希去重,已整理过的文件不再处理
+	hash, err := core.HashFile(path)
+	if err != nil {
+		utils.WarnWithFormat("[Um] 计算文件哈希失败 %s: %v", path, err)
+	}
+	if e, ok := core.GlobalLibrary.HasHash(hash); ok {
+		core.LogLibraryHit("Um", e)
+		t.Update(func(job *jobs.Job) {
+			job.Media = jobs.MediaMusic
+			job.Skipped = true
+		})
+		return nil
+	}
+
+	songInfo, err := HandleEvent(path, t.Cfg)
+	if err != nil {
+		return err
+	}
+	songInfo.Hash = hash
+	if hash != "" {
+		err = core.GlobalLibrary.Add(&core.LibraryEntry{
+			Platform: string(jobs.SourceMonitor),
+			Hash:     hash,
+			Media:    string(jobs.MediaMusic),
+			Title:    songInfo.SongName,
+			Artist:   songInfo.SongArtists,
+			Album:    songInfo.SongAlbum,
+			Tidy:     songInfo.Tidy,
+			Source:   path,
+		})
+		if err != nil {
+			utils.WarnWithFormat("[Um] 写入曲库索引失败: %v", err)
+		}
+	}
+	t.Update(func(job *jobs.Job) {
+		job.Media = jobs.MediaMusic
+		job.Songs = []*music.SongInfo{songInfo}
+	})
+	SendTelegram(songInfo)
+	return nil
+}
+
 // HandleEvent 处理文件新增
 func HandleEvent(path string, cfg *config.Config) (*music.SongInfo, error) {
 	utils.InfoWithFormat("[Um] 开始处理文件: %s", filepath.Base(path))
@@ -26,9 +77,9 @@ func HandleEvent(path string, cfg *config.Config) (*music.SongInfo, error) {
 	var output []byte
 	var err error
 	//判断文件后缀 如果是非解密文件 跳过
-	if utils.Contains(EncryptedExts(), filepath.Ext(path)) {
+	if utils.Contains(processor.UmEncryptedExts(), filepath.Ext(path)) {
 		//调用um工具解密
-		cmd := BuildUmCmd(path, tempDir)
+		cmd := processor.BuildUmCmd(path, tempDir)
 		output, err = cmd.CombinedOutput()
 		utils.InfoWithFormat(string(output))
 		if err != nil {
@@ -59,26 +110,6 @@ func HandleEvent(path string, cfg *config.Config) (*music.SongInfo, error) {
 	return songInfo, nil
 }
 
-// BuildUmCmd 构建一个执行 um 命令的 *exec.Cmd
-func BuildUmCmd(inputFile, outputDir string) *exec.Cmd {
-	// um 命令参数列表
-	args := []string{
-		"--update-metadata",
-		"--overwrite",   // 覆盖输出文件
-		"-o", outputDir, // 输出目录
-		"-i", inputFile, // 输入文件
-	}
-	return exec.Command("um", args...)
-}
-
-// EncryptedExts 加密后缀
-func EncryptedExts() []string {
-	return []string{".ncm", ".qmc3", ".qmcflac", ".mflac", ".mgg",
-		".mflac0", ".mgg1", ".mggl", ".mgalaxy", ".mflach", ".xm",
-		".kwm", ".mflac", ".kgm", ".vpr", ".kgg", ".x2m", ".x3m",
-		".xm", ".mg3d", ".qta"}
-}
-
 // findTrack 识别目录下的文件
 func findTrack(dir string) string {
 	var track string
@@ -114,18 +145,8 @@ func tidy(path string, cfg *config.Config) (*music.SongInfo, error) {
 		return nil, err
 	}
 	utils.InfoWithFormat("[Um] 开始整理文件: %s", path)
-	switch cfg.Tidy.Mode {
-	case 1:
-		songInfo.Tidy = "LOCAL"
-		err = tidyToLocal(path, cfg)
-	case 2:
-		songInfo.Tidy = "WEBDAV"
-		err = tidyToWebDAV(path, core.GlobalWebDAV, cfg)
-	default:
-		utils.WarnWithFormat("[Um] 未知的整理模式: %s", path)
-		return nil, errors.New("未知的整理模式")
-	}
-	if err != nil {
+	songInfo.Tidy = storage.TidyType(cfg)
+	if _, err = music.TidySong(context.Background(), "Um", string(jobs.SourceMonitor), songInfo, path, cfg); err != nil {
 		return nil, err
 	}
 	return songInfo, nil
@@ -11,6 +11,7 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/nichuanfang/gymdl/config"
 	"github.com/nichuanfang/gymdl/internal/bot"
+	"github.com/nichuanfang/gymdl/internal/jobs"
 	"github.com/nichuanfang/gymdl/processor/music"
 	"github.com/nichuanfang/gymdl/utils"
 )
@@ -200,11 +201,14 @@ func (wm *WatchManager) StartWorkerPool(workerCount int) {
 				if event.Op&(fsnotify.Create|fsnotify.Write) != 0 && !info.IsDir() {
 					if isFileStable(event.Name, 1*time.Second, 2) {
 						utils.DebugWithFormat("[Monitor] Worker %d: Music file ready: %s", id, event.Name)
-						songInfo, eventErr := HandleEvent(event.Name, wm.cfg)
+						_, eventErr := jobs.GlobalQueue.Enqueue(&jobs.Job{
+							Kind:   jobs.KindFile,
+							Link:   event.Name,
+							Source: jobs.SourceMonitor,
+						})
 						if eventErr != nil {
-							continue
+							utils.ErrorWithFormat("[Monitor] 加入任务队列失败: %s (%v)", event.Name, eventErr)
 						}
-						SendTelegram(songInfo)
 					} else {
 						utils.DebugWithFormat("[Monitor] Worker %d: File not stable yet: %s", id, event.Name)
 					}
@@ -19,7 +19,9 @@ import (
 	"github.com/nichuanfang/gymdl/internal/bot"
 	"github.com/nichuanfang/gymdl/internal/cron"
 	"github.com/nichuanfang/gymdl/internal/gin/router"
+	"github.com/nichuanfang/gymdl/internal/jobs"
 	"github.com/nichuanfang/gymdl/internal/monitor"
+	"github.com/nichuanfang/gymdl/storage"
 	"github.com/nichuanfang/gymdl/utils"
 	"go.uber.org/zap"
 )
@@ -54,14 +56,18 @@ func printBanner() {
 	fmt.Println(green + banner + reset)
 }
 
-// 初始化 WebDAV 服务
-func initWebDAV(c *config.WebDAVConfig) {
-	core.InitWebDAV(c)
-	if core.GlobalWebDAV.CheckConnection() {
-		utils.ServiceIsOn("WebDAV 服务已加载")
-	} else {
-		utils.Warning("WebDAV 服务不可用，请检查配置或网络连接")
+// 初始化存储后端
+func initStorage(c *config.Config) error {
+	if err := storage.Init(c); err != nil {
+		return err
+	}
+	name := storage.GlobalBackend.Name()
+	if checker, ok := storage.GlobalBackend.(storage.Checker); ok && !checker.CheckConnection() {
+		utils.Warningf("存储后端 %s 不可用，请检查配置或网络连接", name)
+		return nil
 	}
+	utils.ServiceIsOnf("存储后端 %s 已加载", name)
+	return nil
 }
 
 // 初始化 CookieCloud 服务
@@ -94,6 +100,15 @@ func initCron(ctx context.Context, c *config.Config) {
 	utils.Stop("定时任务调度器已关闭")
 }
 
+// 启动任务队列
+func initJobs(ctx context.Context, c *config.Config) {
+	jobs.GlobalQueue.Start()
+	utils.Success("任务队列已启动")
+	<-ctx.Done()
+	jobs.GlobalQueue.Stop()
+	utils.Stop("任务队列已关闭")
+}
+
 // 启动目录监控
 func initMonitor(ctx context.Context, c *config.Config) {
 	wm := monitor.NewWatchManager(c)
@@ -184,6 +199,10 @@ func main() {
 
 	// 加载配置文件
 	c := config.LoadConfig(configFile)
+	if err := c.Validate(); err != nil {
+		fmt.Println("❌ 配置校验失败：", err)
+		return
+	}
 
 	// 初始化日志模块
 	if err := utils.InitLogger(c.Log); err != nil {
@@ -201,15 +220,31 @@ func main() {
 		initAI(c.AI)
 	}
 
-	if c.Tidy.Mode == 2 {
-		initWebDAV(c.WebDAV)
+	if err := initStorage(c); err != nil {
+		utils.Logger().Error("存储后端初始化失败", zap.Error(err))
+		return
+	}
+
+	// 初始化曲库索引(下载前去重)
+	if err := core.InitLibrary(c.Library); err != nil {
+		utils.Logger().Error("曲库索引初始化失败", zap.Error(err))
+		return
+	}
+	defer func() { _ = core.GlobalLibrary.Close() }()
+
+	// 初始化任务队列(bot、web、目录监控共用)
+	if err := jobs.InitQueue(c); err != nil {
+		utils.Logger().Error("任务队列初始化失败", zap.Error(err))
+		return
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	wg := &sync.WaitGroup{}
 
 	// 用 map 映射模块启动逻辑，更优雅地管理协程
-	services := map[string]func(context.Context, *config.Config){}
+	services := map[string]func(context.Context, *config.Config){
+		"jobs": initJobs,
+	}
 
 	// 是否启用定时任务
 	if c.AdditionalConfig.EnableCron {
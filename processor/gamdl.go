@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// gamdl 通用参数与输出解析: 逐行解析日志, 得到曲目级进度与失败原因
+
+/* ---------------------- 常量 ---------------------- */
+
+var (
+	// gamdlLogRe 日志行, 如 [INFO     12:00:00] (Track 3/20 from URL 1/1) Downloading "Title"
+	gamdlLogRe = regexp.MustCompile(`^\[(DEBUG|INFO|WARNING|ERROR|CRITICAL)\b[^\]]*\]\s*(.*)$`)
+	// gamdlTrackRe 曲目位置
+	gamdlTrackRe = regexp.MustCompile(`Track (\d+)/(\d+)`)
+	// gamdlTitleRe 曲目标题
+	gamdlTitleRe = regexp.MustCompile(`Downloading "(.+)"`)
+)
+
+// GamdlCommand 构建 gamdl 命令: 登录 cookie、下载方式及输出模板, 文件写入 tempDir;
+// 歌手/电台展开为多个链接时不同专辑可能有同名曲目, 曲目按专辑分目录并以音轨号命名, 无专辑的(音乐视频)直接以标题命名
+// args 为各资源类型的编码/画质参数及链接
+func GamdlCommand(ctx context.Context, cfg *config.Config, tempDir string, args ...string) *exec.Cmd {
+	cookiePath := filepath.Join(cfg.CookieCloud.CookieFilePath, cfg.CookieCloud.CookieFile)
+	// https://github.com/glomatico/gamdl/commit/fdab6481ea246c2cf3415565c39da62a3b9dbd52 部分options改动
+	rootDir := filepath.Dir(tempDir)
+	baseDir := filepath.Base(tempDir)
+	base := []string{
+		"--cookies-path", cookiePath,
+		"--download-mode", cfg.AppleMusic.DownloadMode,
+		"--output-path", rootDir,
+		"--temp-path", rootDir,
+		"--album-folder-template", baseDir + "/{album_artist}/{album}",
+		"--compilation-folder-template", baseDir + "/Compilations/{album}",
+		"--no-album-folder-template", baseDir,
+		"--single-disc-file-template", "{track:02d} {title}",
+		"--multi-disc-file-template", "{disc}-{track:02d} {title}",
+		"--no-album-file-template", "{title}",
+	}
+	return Command(ctx, "gamdl", append(base, args...)...)
+}
+
+/* ---------------------- 结构体定义 ---------------------- */
+
+// GamdlTracker gamdl 输出解析器
+type GamdlTracker struct {
+	Index    int      // 当前曲目序号
+	Count    int      // 曲目总数
+	Title    string   // 当前曲目标题
+	Started  int      // 已开始下载的曲目数
+	Failures []string // 失败曲目及原因
+
+	inError  bool // 正在读取错误堆栈, 最后一行为异常信息
+	report   utils.ProgressFunc
+	interval time.Duration
+	last     time.Time
+}
+
+// NewGamdlTracker 创建解析器, report 为进度回调(限频 1 秒, 失败信息不限频)
+func NewGamdlTracker(report utils.ProgressFunc) *GamdlTracker {
+	return &GamdlTracker{report: report, interval: time.Second}
+}
+
+/* ---------------------- 解析 ---------------------- */
+
+// Feed 解析一行 gamdl 输出
+func (t *GamdlTracker) Feed(line string) {
+	utils.DebugWithFormat("[AppleMusic] %s", line)
+	m := gamdlLogRe.FindStringSubmatch(line)
+	if m == nil {
+		// 错误堆栈: 以最后一行(异常信息)作为失败原因
+		if t.inError && len(t.Failures) > 0 {
+			t.Failures[len(t.Failures)-1] = fmt.Sprintf("%s: %s", t.current(), line)
+		}
+		return
+	}
+	t.inError = false
+	level, msg := m[1], m[2]
+	if tm := gamdlTrackRe.FindStringSubmatch(msg); tm != nil {
+		t.Index, _ = strconv.Atoi(tm[1])
+		t.Count, _ = strconv.Atoi(tm[2])
+	}
+
+	switch level {
+	case "INFO":
+		if tm := gamdlTitleRe.FindStringSubmatch(msg); tm != nil {
+			t.Title = tm[1]
+			t.Started++
+			t.emit(t.event("正在下载"), false)
+		}
+	case "WARNING":
+		// 已存在的文件不算失败
+		if strings.Contains(msg, "already exists") {
+			return
+		}
+		if strings.Contains(strings.ToLower(msg), "skipping") {
+			t.fail(gamdlReason(msg))
+		}
+	case "ERROR", "CRITICAL":
+		t.fail(gamdlReason(msg))
+		t.inError = true
+	}
+}
+
+// Succeeded 下载成功的曲目数
+func (t *GamdlTracker) Succeeded() int {
+	return max(t.Started-len(t.Failures), 0)
+}
+
+// fail 记录当前曲目失败
+func (t *GamdlTracker) fail(reason string) {
+	failure := fmt.Sprintf("%s: %s", t.current(), reason)
+	t.Failures = append(t.Failures, failure)
+	utils.WarnWithFormat("[AppleMusic] ⚠️ %s", failure)
+	t.emit(t.event(fmt.Sprintf("下载失败(%s)", reason)), true)
+}
+
+// current 当前曲目描述, 如 3/20: Title
+func (t *GamdlTracker) current() string {
+	if t.Count > 0 {
+		return fmt.Sprintf("%d/%d: %s", t.Index, t.Count, t.Title)
+	}
+	return t.Title
+}
+
+// event 当前曲目的进度事件
+func (t *GamdlTracker) event(message string) utils.Progress {
+	return utils.Progress{
+		Phase:   utils.PhaseDownload,
+		Item:    t.Title,
+		Current: t.Index,
+		Total:   t.Count,
+		Message: message,
+	}
+}
+
+// emit 回调进度, force 为 true 时不限频
+func (t *GamdlTracker) emit(e utils.Progress, force bool) {
+	if t.report == nil {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(t.last) < t.interval {
+		return
+	}
+	t.last = now
+	t.report(e)
+}
+
+// gamdlReason 去掉日志中的曲目位置前缀, 如 (Track 3/20 from URL 1/1)
+func gamdlReason(msg string) string {
+	if strings.HasPrefix(msg, "(") {
+		if i := strings.Index(msg, ")"); i >= 0 {
+			msg = msg[i+1:]
+		}
+	}
+	return strings.TrimSpace(msg)
+}
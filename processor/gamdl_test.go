@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+func TestMain(m *testing.M) {
+	_ = utils.InitLogger(&config.LogConfig{Mode: 1, Level: 4})
+	os.Exit(m.Run())
+}
+
+// gamdlOutput 专辑下载的 gamdl 输出: 第 2 首已存在, 第 3 首跳过, 第 4 首报错并打印堆栈
+const gamdlOutput = `[INFO     12:00:00] Starting Gamdl
+[INFO     12:00:01] (URL 1/1) Checking "https://music.apple.com/us/album/x/1440857781"
+[INFO     12:00:02] (Track 1/4 from URL 1/1) Downloading "Song A"
+[INFO     12:00:05] (Track 2/4 from URL 1/1) Downloading "Song B"
+[WARNING  12:00:05] (Track 2/4 from URL 1/1) File already exists at "/tmp/x/Song B.m4a", skipping
+[INFO     12:00:06] (Track 3/4 from URL 1/1) Downloading "Song C"
+[WARNING  12:00:06] (Track 3/4 from URL 1/1) Song is not streamable or downloadable, skipping
+[INFO     12:00:07] (Track 4/4 from URL 1/1) Downloading "Song D"
+[ERROR    12:00:08] (Track 4/4 from URL 1/1) Failed to download "Song D"
+Traceback (most recent call last):
+  File "gamdl/cli.py", line 500, in main
+KeyError: 'assets'
+[INFO     12:00:09] Done (1 error(s))`
+
+func TestGamdlTrackerFeed(t *testing.T) {
+	var events []utils.Progress
+	tracker := NewGamdlTracker(func(p utils.Progress) { events = append(events, p) })
+	tracker.interval = 0
+	for _, line := range strings.Split(gamdlOutput, "\n") {
+		tracker.Feed(line)
+	}
+
+	if tracker.Index != 4 || tracker.Count != 4 || tracker.Title != "Song D" {
+		t.Errorf("当前曲目 = %d/%d %q, 期望 4/4 \"Song D\"", tracker.Index, tracker.Count, tracker.Title)
+	}
+	if tracker.Started != 4 {
+		t.Errorf("Started = %d, 期望 4", tracker.Started)
+	}
+	wantFailures := []string{
+		"3/4: Song C: Song is not streamable or downloadable, skipping",
+		"4/4: Song D: KeyError: 'assets'",
+	}
+	if !reflect.DeepEqual(tracker.Failures, wantFailures) {
+		t.Errorf("Failures = %q, 期望 %q", tracker.Failures, wantFailures)
+	}
+	if got := tracker.Succeeded(); got != 2 {
+		t.Errorf("Succeeded() = %d, 期望 2", got)
+	}
+
+	// 4 次开始下载 + 2 次失败
+	if len(events) != 6 {
+		t.Fatalf("进度事件 %d 个, 期望 6 个", len(events))
+	}
+	first := events[0]
+	if first.Phase != utils.PhaseDownload || first.Item != "Song A" || first.Current != 1 || first.Total != 4 {
+		t.Errorf("首个进度事件错误: %+v", first)
+	}
+	if last := events[len(events)-1]; !strings.Contains(last.Message, "下载失败") || last.Item != "Song D" {
+		t.Errorf("失败进度事件错误: %+v", last)
+	}
+}
+
+func TestGamdlTrackerThrottle(t *testing.T) {
+	var events int
+	tracker := NewGamdlTracker(func(utils.Progress) { events++ })
+	tracker.Feed(`[INFO     12:00:00] (Track 1/2 from URL 1/1) Downloading "Song A"`)
+	tracker.Feed(`[INFO     12:00:00] (Track 2/2 from URL 1/1) Downloading "Song B"`)
+	// 失败信息不限频
+	tracker.Feed(`[CRITICAL 12:00:00] (Track 2/2 from URL 1/1) Unexpected error`)
+	if events != 2 {
+		t.Errorf("进度事件 %d 个, 期望 2 个(第二首被限频)", events)
+	}
+	if tracker.Failures[0] != "2/2: Song B: Unexpected error" {
+		t.Errorf("Failures = %q", tracker.Failures)
+	}
+}
+
+func TestGamdlTrackerWithoutTrackCount(t *testing.T) {
+	tracker := NewGamdlTracker(nil)
+	tracker.Feed(`[INFO     12:00:00] Downloading "Single"`)
+	tracker.Feed(`[ERROR    12:00:01] Failed to get lyrics`)
+	if tracker.Failures[0] != "Single: Failed to get lyrics" || tracker.Succeeded() != 0 {
+		t.Errorf("Failures = %q, Succeeded = %d", tracker.Failures, tracker.Succeeded())
+	}
+}
+
+func TestGamdlReason(t *testing.T) {
+	tests := map[string]string{
+		"(Track 3/20 from URL 1/1) Song is not streamable": "Song is not streamable",
+		"Plain reason ": "Plain reason",
+		"(unterminated": "(unterminated",
+	}
+	for in, want := range tests {
+		if got := gamdlReason(in); got != want {
+			t.Errorf("gamdlReason(%q) = %q, 期望 %q", in, got, want)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package music
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
@@ -13,20 +15,92 @@ import (
 	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
 	"github.com/nichuanfang/gymdl/utils"
+	"go.senan.xyz/taglib"
 )
 
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkAppleMusic,
+		[]string{
+			"music.apple.com",
+		},
+		[]*regexp.Regexp{
+			// 公共播放列表（pl.u- 开头）
+			regexp.MustCompile(`^https?://music\.apple\.com/[a-z]{2}/playlist/[A-Za-z0-9._%\-]+/pl\.u-[A-Za-z0-9]+(?:\?.*)?$`),
+
+			// 资料库播放列表（p. 开头）
+			regexp.MustCompile(`^https?://music\.apple\.com/library/playlist/p\.[A-Za-z0-9]+(?:\?.*)?$`),
+
+			// 单曲（song）
+			regexp.MustCompile(`^https?://music\.apple\.com/[a-z]{2}/song/[A-Za-z0-9%._\-]+/\d+(?:\?.*)?$`),
+
+			// 专辑（album）
+			regexp.MustCompile(`^https?://music\.apple\.com/[a-z]{2}/album/[A-Za-z0-9%._\-]+/\d+(?:\?.*)?$`),
+
+			// 歌手（artist）
+			regexp.MustCompile(`^https?://music\.apple\.com/[a-z]{2}/artist/(?:[A-Za-z0-9%._\-]+/)?\d+(?:\?.*)?$`),
+
+			// 电台（station, ra. 开头）
+			regexp.MustCompile(`^https?://music\.apple\.com/[a-z]{2}/station/(?:[A-Za-z0-9%._\-]+/)?ra\.[A-Za-z0-9.\-]+(?:\?.*)?$`),
+		},
+		func() processor.Processor { return &AppleMusicProcessor{} },
+	).WithIdentifier(identifyAppleMusic)
+}
+
+// appleMusicLinkRe 地区、资源类型与ID
+var appleMusicLinkRe = regexp.MustCompile(`music\.apple\.com/(?:([a-z]{2})/)?(song|album|playlist|library/playlist|artist|station)/(?:[^/?]+/)?([^/?]+)`)
+
+// gamdlSongCodecs 配置中的编码名称 -> gamdl 编码名称, 未列出的名称原样传给 gamdl
+var gamdlSongCodecs = map[string]string{
+	"alac":  "alac",
+	"aac":   "aac-legacy",
+	"atmos": "atmos",
+}
+
+// identifyAppleMusic 识别 Apple Music 链接, 专辑链接带 ?i= 时为专辑中的单曲
+func identifyAppleMusic(link string) *processor.Resource {
+	m := appleMusicLinkRe.FindStringSubmatch(link)
+	if m == nil {
+		return nil
+	}
+	region, kind, id := m[1], m[2], m[3]
+	switch kind {
+	case "library/playlist":
+		return processor.NewResource(processor.ResourcePlaylist, id, "https://music.apple.com/library/playlist/%s", id)
+	case "playlist":
+		return processor.NewResource(processor.ResourcePlaylist, id, "https://music.apple.com/%s/playlist/%s", region, id)
+	case "album":
+		if songID := processor.QueryParam(link, "i"); songID != "" {
+			return processor.NewResource(processor.ResourceTrack, songID, "https://music.apple.com/%s/song/%s", region, songID)
+		}
+		return processor.NewResource(processor.ResourceAlbum, id, "https://music.apple.com/%s/album/%s", region, id)
+	case "artist":
+		return processor.NewResource(processor.ResourceArtist, id, "https://music.apple.com/%s/artist/%s", region, id)
+	case "station":
+		return processor.NewResource(processor.ResourceRadio, id, "https://music.apple.com/%s/station/%s", region, id)
+	default:
+		return processor.NewResource(processor.ResourceTrack, id, "https://music.apple.com/%s/song/%s", region, id)
+	}
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type AppleMusicProcessor struct {
-	cfg     *config.Config
-	tempDir string
-	songs   []*SongInfo
+	cfg      *config.Config
+	client   *http.Client
+	tempDir  string
+	songs    []*SongInfo
+	tracks   []amResource // 待下载的曲目(下载前展开, 用于对应歌曲ID)
+	failures []string     // 下载失败的曲目及原因(如所在地区不可用)
 }
 
 // Init  初始化
 func (am *AppleMusicProcessor) Init(cfg *config.Config) {
 	am.songs = make([]*SongInfo, 0)
+	am.tracks = nil
 	am.cfg = cfg
+	am.client = &http.Client{Timeout: 30 * time.Second}
 	am.tempDir = processor.BuildOutputDir(AppleMusicTempDir)
 }
 
@@ -40,14 +114,36 @@ func (am *AppleMusicProcessor) Songs() []*SongInfo {
 	return am.songs
 }
 
+func (am *AppleMusicProcessor) Failures() []string {
+	return am.failures
+}
+
 /* ------------------------ 下载逻辑 ------------------------ */
 
-func (am *AppleMusicProcessor) DownloadMusic(url string, callback func(string)) error {
+func (am *AppleMusicProcessor) DownloadMusic(ctx context.Context, url string, report utils.ProgressFunc) error {
 	start := time.Now()
 
 	utils.InfoWithFormat("[AppleMusic] 🎵 开始下载: %s", url)
 
-	cmd := am.DownloadCommand(url)
+	// 歌手/电台先展开为专辑/歌曲链接
+	urls, err := am.resolveLinks(ctx, url, report)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		utils.ErrorWithFormat("[AppleMusic] ❌ 解析链接失败: %v", err)
+		return err
+	}
+	// 下载前查询曲库, 跳过已入库的曲目
+	urls, err = am.skipLibraryTracks(ctx, urls, report)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+
+	cmd := am.downloadCommand(ctx, urls)
 	utils.DebugWithFormat("[AppleMusic] 执行命令: %s", strings.Join(cmd.Args, " "))
 
 	// 创建临时目录
@@ -56,44 +152,33 @@ func (am *AppleMusicProcessor) DownloadMusic(url string, callback func(string))
 		return err
 	}
 
-	// 执行下载
-	output, err := cmd.CombinedOutput()
-	logOut := strings.TrimSpace(string(output))
+	// 执行下载, 逐行解析曲目进度
+	tracker := processor.NewGamdlTracker(report)
+	logOut, err := processor.RunCommand(cmd, tracker.Feed)
 	if err != nil {
 		_ = processor.RemoveTempDir(am.tempDir)
+		if ctx.Err() != nil {
+			utils.InfoWithFormat("[AppleMusic] 🚫 下载已取消: %s", url)
+			return ctx.Err()
+		}
 		utils.ErrorWithFormat("[AppleMusic] ❌ 下载失败: %v\n输出:\n%s", err, logOut)
 		return fmt.Errorf("gamdl 下载失败: %w", err)
 	}
-
-	// 输出调试信息，仅当有日志内容时
-	if logOut != "" {
-		utils.DebugWithFormat("[AppleMusic] 下载输出:\n%s", logOut)
+	am.failures = tracker.Failures
+	if tracker.Started > 0 && tracker.Succeeded() == 0 {
+		_ = processor.RemoveTempDir(am.tempDir)
+		utils.ErrorWithFormat("[AppleMusic] ❌ 全部曲目下载失败\n输出:\n%s", logOut)
+		return fmt.Errorf("%d 首曲目全部下载失败:\n%s", len(am.failures), strings.Join(am.failures, "\n"))
 	}
 
-	utils.InfoWithFormat("[AppleMusic] ✅ 下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond))
-	callback(fmt.Sprintf("下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond)))
+	summary := fmt.Sprintf("下载完成: 成功 %d 首 / 失败 %d 首（耗时 %v）", tracker.Succeeded(), len(am.failures), time.Since(start).Truncate(time.Millisecond))
+	utils.InfoWithFormat("[AppleMusic] ✅ %s", summary)
+	report.Emit(utils.Progress{Phase: utils.PhaseDone, Total: tracker.Started, Message: summary})
 	return nil
 }
 
-func (am *AppleMusicProcessor) DownloadCommand(url string) *exec.Cmd {
-	cookiePath := filepath.Join(am.cfg.CookieCloud.CookieFilePath, am.cfg.CookieCloud.CookieFile)
-	// https://github.com/glomatico/gamdl/commit/fdab6481ea246c2cf3415565c39da62a3b9dbd52 部分options改动
-	rootDir := filepath.Dir(am.tempDir)
-	baseDir := filepath.Base(am.tempDir)
-	args := []string{
-		"--cookies-path", cookiePath,
-		"--download-mode", "nm3u8dlre",
-		"--output-path", rootDir,
-		"--temp-path", rootDir,
-		"--album-folder-template", baseDir,
-		"--compilation-folder-template", baseDir,
-		"--no-album-folder-template", baseDir,
-		"--single-disc-file-template", "{title}",
-		"--multi-disc-file-template", "{title}",
-		"--no-synced-lyrics",
-		url,
-	}
-	return exec.Command("gamdl", args...)
+func (am *AppleMusicProcessor) DownloadCommand(ctx context.Context, url string) *exec.Cmd {
+	return am.downloadCommand(ctx, []string{url})
 }
 
 func (am *AppleMusicProcessor) BeforeTidy() error {
@@ -101,8 +186,10 @@ func (am *AppleMusicProcessor) BeforeTidy() error {
 	if err != nil {
 		return err
 	}
-	// 更新元信息列表
-	am.songs = songs
+	am.applyLyrics(songs)
+	am.matchSongIDs(songs)
+	// 更新元信息列表(跳过曲库中已存在的歌曲)
+	am.songs = SkipLibraryDuplicates(am.Name(), songs)
 	return nil
 }
 
@@ -114,24 +201,12 @@ func (am *AppleMusicProcessor) DRMRemove() error {
 	return nil
 }
 
-func (am *AppleMusicProcessor) TidyMusic() error {
-	files, err := os.ReadDir(am.tempDir)
-	if err != nil {
-		return fmt.Errorf("读取临时目录失败: %w", err)
-	}
-	if len(files) == 0 {
-		utils.WarnWithFormat("[AppleMusic] ⚠️ 未找到待整理的音乐文件")
-		return errors.New("未找到待整理的音乐文件")
-	}
-
-	switch am.cfg.Tidy.Mode {
-	case 1:
-		return am.tidyToLocal(files)
-	case 2:
-		return am.tidyToWebDAV(files, core.GlobalWebDAV)
-	default:
-		return fmt.Errorf("未知整理模式: %d", am.cfg.Tidy.Mode)
+func (am *AppleMusicProcessor) TidyMusic(ctx context.Context) error {
+	if len(am.songs) == 0 {
+		_ = processor.RemoveTempDir(am.tempDir)
+		return core.ErrInLibrary
 	}
+	return TidyMusicDir(ctx, "AppleMusic", am, am.tempDir, am.cfg)
 }
 
 func (am *AppleMusicProcessor) EncryptedExts() []string {
@@ -144,63 +219,164 @@ func (am *AppleMusicProcessor) DecryptedExts() []string {
 
 /* ------------------------ 拓展方法 ------------------------ */
 
-// 整理到本地
-func (am *AppleMusicProcessor) tidyToLocal(files []os.DirEntry) error {
-	dstDir := am.cfg.Tidy.DistDir
-	if dstDir == "" {
-		_ = processor.RemoveTempDir(am.tempDir)
-		return errors.New("未配置输出目录")
+// resolveLinks 待下载的链接: 歌手展开为符合配置类型的全部发行, 电台展开为接下来播放的歌曲
+func (am *AppleMusicProcessor) resolveLinks(ctx context.Context, link string, report utils.ProgressFunc) ([]string, error) {
+	res := identifyAppleMusic(link)
+	if res == nil {
+		return []string{link}, nil
 	}
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		_ = processor.RemoveTempDir(am.tempDir)
-		return fmt.Errorf("创建输出目录失败: %w", err)
+	storefront := amStorefront(link)
+	switch res.Kind {
+	case processor.ResourceArtist:
+		urls, err := am.fetchArtistAlbums(ctx, storefront, res.ID)
+		if err != nil {
+			return nil, err
+		}
+		report.Message(utils.PhaseResolve, "歌手共 %d 个发行(专辑/EP/单曲)待下载", len(urls))
+		return urls, nil
+	case processor.ResourceRadio:
+		urls, err := am.fetchStationSongs(ctx, storefront, res.ID)
+		if err != nil {
+			return nil, err
+		}
+		report.Message(utils.PhaseResolve, "电台共 %d 首歌曲待下载", len(urls))
+		return urls, nil
+	default:
+		return []string{link}, nil
 	}
+}
 
-	for _, f := range files {
-		if !utils.FilterMusicFile(f, am.EncryptedExts(), am.DecryptedExts()) {
-			utils.DebugWithFormat("[AppleMusic] 跳过非音乐文件: %s", f.Name())
+// skipLibraryTracks 下载前查询曲库: 单曲按链接中的ID查询, 专辑/歌单展开为曲目后逐首查询;
+// 部分曲目已入库时改为逐首下载其余曲目, 全部已入库时返回 core.ErrInLibrary
+func (am *AppleMusicProcessor) skipLibraryTracks(ctx context.Context, urls []string, report utils.ProgressFunc) ([]string, error) {
+	if core.GlobalLibrary == nil {
+		return urls, nil
+	}
+	platform := string(am.Name())
+	planned := make([]string, 0, len(urls))
+	songIDs := make(map[string][]string) // 地区 -> 待下载的单曲ID
+	skipped := 0
+	for _, link := range urls {
+		res := identifyAppleMusic(link)
+		if res != nil && res.Kind == processor.ResourceTrack {
+			if e, ok := core.GlobalLibrary.Has(platform, res.ID); ok {
+				core.LogLibraryHit("AppleMusic", e)
+				skipped++
+				continue
+			}
+			storefront := amStorefront(link)
+			songIDs[storefront] = append(songIDs[storefront], res.ID)
+			planned = append(planned, link)
 			continue
 		}
-		src := filepath.Join(am.tempDir, f.Name())
-		dst := filepath.Join(dstDir, utils.SanitizeFileName(f.Name()))
-		err := processor.ToLocal(src, dst)
+
+		tracks, err := am.fetchTracks(ctx, link)
 		if err != nil {
-			return err
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// 无法展开时整体下载, 下载后按文件哈希去重
+			utils.WarnWithFormat("[AppleMusic] ⚠️ 获取曲目列表失败, 下载后再查询曲库: %v", err)
+			planned = append(planned, link)
+			continue
+		}
+		missing := make([]amResource, 0, len(tracks))
+		for _, t := range tracks {
+			if e, ok := core.GlobalLibrary.Has(platform, t.ID); ok {
+				core.LogLibraryHit("AppleMusic", e)
+				continue
+			}
+			missing = append(missing, t)
+		}
+		am.tracks = append(am.tracks, missing...)
+		skipped += len(tracks) - len(missing)
+		switch {
+		case tracks == nil, len(missing) == len(tracks):
+			planned = append(planned, link)
+		default:
+			for _, t := range missing {
+				planned = append(planned, fmt.Sprintf("%s/%s/song/%s", amWebURL, amStorefront(link), t.ID))
+			}
 		}
-		utils.InfoWithFormat("[AppleMusic] 📦 已整理: %s", dst)
 	}
-	// 清除临时目录
-	err := processor.RemoveTempDir(am.tempDir)
-	if err != nil {
-		return err
+
+	// 单曲查询曲名与序号, 下载后对应歌曲ID
+	for storefront, ids := range songIDs {
+		songs, err := am.fetchSongs(ctx, storefront, ids)
+		if err != nil {
+			utils.WarnWithFormat("[AppleMusic] ⚠️ 获取歌曲信息失败: %v", err)
+			continue
+		}
+		am.tracks = append(am.tracks, songs...)
 	}
-	return nil
+
+	if len(planned) == 0 {
+		utils.InfoWithFormat("[AppleMusic] ⏭️ %d 首曲目均已在曲库中", skipped)
+		report.Message(utils.PhaseDone, "已在曲库中，跳过下载")
+		return nil, core.ErrInLibrary
+	}
+	if skipped > 0 {
+		utils.InfoWithFormat("[AppleMusic] ⏭️ 曲库中已有 %d 首, 仅下载其余曲目", skipped)
+		report.Message(utils.PhaseResolve, "曲库中已有 %d 首，跳过", skipped)
+	}
+	return planned, nil
 }
 
-// 整理到webdav
-func (am *AppleMusicProcessor) tidyToWebDAV(files []os.DirEntry, webdav *core.WebDAV) error {
-	if webdav == nil {
-		_ = processor.RemoveTempDir(am.tempDir)
-		return errors.New("WebDAV 未初始化")
+// matchSongIDs 按曲名与碟片/音轨号将下载的歌曲对应到曲目ID(曲库按ID去重)
+func (am *AppleMusicProcessor) matchSongIDs(songs []*SongInfo) {
+	for _, song := range songs {
+		candidates := make([]amResource, 0, 1)
+		for _, t := range am.tracks {
+			if strings.EqualFold(strings.TrimSpace(t.Attributes.Name), strings.TrimSpace(song.SongName)) {
+				candidates = append(candidates, t)
+			}
+		}
+		for _, t := range candidates {
+			if len(candidates) == 1 || (t.Attributes.DiscNumber == song.DiscNumber && t.Attributes.TrackNumber == song.TrackNumber) {
+				song.SongID = t.ID
+				break
+			}
+		}
 	}
+}
 
-	for _, f := range files {
-		if !utils.FilterMusicFile(f, am.EncryptedExts(), am.DecryptedExts()) {
-			utils.DebugWithFormat("[AppleMusic] 跳过非音乐文件: %s", f.Name())
-			continue
+// applyLyrics 将 gamdl 保存的 TTML 歌词转换为 LRC, 按歌词模式嵌入标签或保存为附属文件
+func (am *AppleMusicProcessor) applyLyrics(songs []*SongInfo) {
+	for _, song := range songs {
+		ttmlPath := LyricSidecarPath(song.MusicPath, ".ttml")
+		if data, err := os.ReadFile(ttmlPath); err == nil {
+			if lrc, err := TTMLToLRC(data); err != nil {
+				utils.WarnWithFormat("[AppleMusic] ⚠️ 转换歌词失败 %s: %v", filepath.Base(ttmlPath), err)
+			} else if lrc != "" {
+				song.Lyric = lrc
+			}
+			if !SidecarLyrics(am.cfg) || !am.cfg.Lyrics.TTML {
+				_ = os.Remove(ttmlPath)
+			}
+		}
+		ApplyLyrics(am.cfg, song, song.MusicPath)
+		if err := taglib.WriteTags(song.MusicPath, map[string][]string{taglib.Lyrics: {song.Lyric}}, 0); err != nil {
+			utils.WarnWithFormat("[AppleMusic] ⚠️ 写入歌词失败 %s: %v", filepath.Base(song.MusicPath), err)
 		}
+	}
+}
 
-		filePath := filepath.Join(am.tempDir, f.Name())
-		if err := webdav.Upload(filePath); err != nil {
-			utils.WarnWithFormat("[AppleMusic] ☁️ 上传失败 %s: %v", f.Name(), err)
-			continue
+// downloadCommand 构建 gamdl 命令, 按配置的编码优先级下载歌曲
+func (am *AppleMusicProcessor) downloadCommand(ctx context.Context, urls []string) *exec.Cmd {
+	codecs := make([]string, 0, len(am.cfg.AppleMusic.Codecs))
+	for _, c := range am.cfg.AppleMusic.Codecs {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if name, ok := gamdlSongCodecs[c]; ok {
+			c = name
 		}
-		utils.InfoWithFormat("[AppleMusic] ☁️ 已上传: %s", f.Name())
+		codecs = append(codecs, c)
 	}
-	// 清除临时目录
-	err := processor.RemoveTempDir(am.tempDir)
-	if err != nil {
-		return err
+	args := []string{"--song-codec-priority", strings.Join(codecs, ",")}
+	// 同步歌词统一下载为 TTML(逐字歌词可用时保留逐字时间), 整理前再转换为 LRC
+	if am.cfg.Lyrics.Mode == LyricsNone {
+		args = append(args, "--no-synced-lyrics")
+	} else {
+		args = append(args, "--synced-lyrics-format", "ttml")
 	}
-	return nil
+	return processor.GamdlCommand(ctx, am.cfg, am.tempDir, append(args, urls...)...)
 }
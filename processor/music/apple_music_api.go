@@ -0,0 +1,317 @@
+package music
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// Apple Music Web API(amp-api): 展开歌手全部发行与电台曲目、查询专辑/歌单曲目, 再交给 gamdl 下载
+
+/* ---------------------- 常量 ---------------------- */
+
+const (
+	amWebURL = "https://music.apple.com"
+	amAPIURL = "https://amp-api.music.apple.com"
+	// amTokenTTL 开发者令牌缓存时长(令牌本身有效期数月, 定期刷新以防网页版更换)
+	amTokenTTL = 12 * time.Hour
+	// amDefaultStorefront 链接中没有地区时使用的商店
+	amDefaultStorefront = "us"
+)
+
+var (
+	// amScriptRe 网页版主脚本
+	amScriptRe = regexp.MustCompile(`/assets/index[^"'\s]*\.js`)
+	// amJWTRe 主脚本中内置的开发者令牌
+	amJWTRe = regexp.MustCompile(`eyJh[\w-]+\.[\w-]+\.[\w-]+`)
+
+	amTokenMu sync.Mutex
+	amToken   string
+	amTokenAt time.Time
+)
+
+// amAlbumTypes 配置中的发行类型
+var amAlbumTypes = []string{"album", "ep", "single", "compilation"}
+
+// amSongsChunkSize 按ID批量查询歌曲时每次请求的数量
+const amSongsChunkSize = 100
+
+/* ---------------------- 结构体定义 ---------------------- */
+
+// amResource 接口返回的资源(专辑/歌曲/电台)
+type amResource struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Name          string `json:"name"`
+		URL           string `json:"url"`
+		IsSingle      bool   `json:"isSingle"`
+		IsCompilation bool   `json:"isCompilation"`
+		IsLive        bool   `json:"isLive"`
+		TrackNumber   int    `json:"trackNumber"`
+		DiscNumber    int    `json:"discNumber"`
+	} `json:"attributes"`
+}
+
+// amResponse 接口分页响应
+type amResponse struct {
+	Data []amResource `json:"data"`
+	Next string       `json:"next"`
+}
+
+/* ---------------------- 接口调用 ---------------------- */
+
+// developerToken 获取网页版内置的开发者令牌(进程内缓存)
+func (am *AppleMusicProcessor) developerToken(ctx context.Context) (string, error) {
+	amTokenMu.Lock()
+	defer amTokenMu.Unlock()
+	if amToken != "" && time.Since(amTokenAt) < amTokenTTL {
+		return amToken, nil
+	}
+	home, err := am.fetchText(ctx, amWebURL)
+	if err != nil {
+		return "", fmt.Errorf("获取 Apple Music 网页失败: %w", err)
+	}
+	script := amScriptRe.FindString(home)
+	if script == "" {
+		return "", errors.New("未找到 Apple Music 网页脚本")
+	}
+	js, err := am.fetchText(ctx, amWebURL+script)
+	if err != nil {
+		return "", fmt.Errorf("获取 Apple Music 网页脚本失败: %w", err)
+	}
+	token := amJWTRe.FindString(js)
+	if token == "" {
+		return "", errors.New("未找到 Apple Music 开发者令牌")
+	}
+	amToken, amTokenAt = token, time.Now()
+	return token, nil
+}
+
+// fetchText 获取网页文本
+func (am *AppleMusicProcessor) fetchText(ctx context.Context, link string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+// api 调用 amp-api, path 为 /v1 开头的路径; 需要用户身份的接口附带 cookie 中的 media-user-token
+func (am *AppleMusicProcessor) api(ctx context.Context, method, path string, out any) error {
+	token, err := am.developerToken(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, amAPIURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Origin", amWebURL)
+	req.Header.Set("Referer", amWebURL+"/")
+	cookiePath := filepath.Join(am.cfg.CookieCloud.CookieFilePath, am.cfg.CookieCloud.CookieFile)
+	if mut := utils.GetCookieValue(cookiePath, "music.apple.com", "media-user-token"); mut != "" {
+		req.Header.Set("Media-User-Token", mut)
+	}
+
+	resp, err := am.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Apple Music API请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Apple Music API返回错误: %s HTTP %d", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析Apple Music API响应失败: %w", err)
+	}
+	return nil
+}
+
+/* ---------------------- 歌手 ---------------------- */
+
+// fetchArtistAlbums 歌手的全部发行中符合配置类型的专辑链接
+func (am *AppleMusicProcessor) fetchArtistAlbums(ctx context.Context, storefront, artistID string) ([]string, error) {
+	types := make([]string, 0, len(am.cfg.AppleMusic.ArtistTypes))
+	for _, t := range am.cfg.AppleMusic.ArtistTypes {
+		t = strings.ToLower(t)
+		if !utils.Contains(amAlbumTypes, t) {
+			utils.WarnWithFormat("[AppleMusic] ⚠️ 未知发行类型: %s", t)
+			continue
+		}
+		types = append(types, t)
+	}
+
+	urls := make([]string, 0)
+	counts := make(map[string]int)
+	path := fmt.Sprintf("/v1/catalog/%s/artists/%s/albums?limit=100", storefront, artistID)
+	for path != "" {
+		var page amResponse
+		if err := am.api(ctx, http.MethodGet, path, &page); err != nil {
+			return nil, err
+		}
+		for _, album := range page.Data {
+			t := amAlbumType(album)
+			if !utils.Contains(types, t) || album.Attributes.URL == "" {
+				continue
+			}
+			counts[t]++
+			urls = append(urls, album.Attributes.URL)
+		}
+		path = page.Next
+	}
+	utils.InfoWithFormat("[AppleMusic] 歌手 %s: 专辑 %d / EP %d / 单曲 %d / 合辑 %d", artistID,
+		counts["album"], counts["ep"], counts["single"], counts["compilation"])
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("歌手没有符合条件的发行(类型: %s)", strings.Join(types, ", "))
+	}
+	return urls, nil
+}
+
+// amAlbumType 发行类型: 单曲与 EP 在接口中都标记为 isSingle, 按名称后缀区分
+func amAlbumType(album amResource) string {
+	name := album.Attributes.Name
+	switch {
+	case album.Attributes.IsCompilation:
+		return "compilation"
+	case strings.HasSuffix(name, " - EP"):
+		return "ep"
+	case strings.HasSuffix(name, " - Single"), album.Attributes.IsSingle:
+		return "single"
+	default:
+		return "album"
+	}
+}
+
+/* ---------------------- 电台 ---------------------- */
+
+// fetchStationSongs 电台接下来播放的歌曲链接(需要登录), 直播电台无法下载
+func (am *AppleMusicProcessor) fetchStationSongs(ctx context.Context, storefront, stationID string) ([]string, error) {
+	var station amResponse
+	if err := am.api(ctx, http.MethodGet, fmt.Sprintf("/v1/catalog/%s/stations/%s", storefront, stationID), &station); err != nil {
+		return nil, err
+	}
+	if len(station.Data) == 0 {
+		return nil, fmt.Errorf("电台不存在: %s", stationID)
+	}
+	if station.Data[0].Attributes.IsLive {
+		return nil, fmt.Errorf("%s 为直播电台, 不支持下载", station.Data[0].Attributes.Name)
+	}
+
+	limit := am.cfg.AppleMusic.StationTracks
+	seen := make(map[string]bool)
+	urls := make([]string, 0, limit)
+	// 每次请求返回若干首, 连续返回重复歌曲时停止
+	for len(urls) < limit {
+		var next amResponse
+		path := "/v1/me/stations/next-tracks/" + url.PathEscape(stationID)
+		if err := am.api(ctx, http.MethodPost, path, &next); err != nil {
+			if len(urls) > 0 {
+				utils.WarnWithFormat("[AppleMusic] ⚠️ 获取电台曲目中断: %v", err)
+				break
+			}
+			return nil, fmt.Errorf("获取电台曲目失败(需要 Apple Music 订阅账号登录): %w", err)
+		}
+		added := 0
+		for _, song := range next.Data {
+			if song.Type != "songs" || song.Attributes.URL == "" || seen[song.ID] {
+				continue
+			}
+			seen[song.ID] = true
+			urls = append(urls, song.Attributes.URL)
+			added++
+			if len(urls) >= limit {
+				break
+			}
+		}
+		if added == 0 {
+			break
+		}
+	}
+	if len(urls) == 0 {
+		return nil, errors.New("电台没有可下载的歌曲")
+	}
+	return urls, nil
+}
+
+/* ---------------------- 曲目 ---------------------- */
+
+// fetchTracks 专辑/歌单链接对应的曲目(下载前查询曲库), 资料库歌单等无法展开的链接返回 nil
+func (am *AppleMusicProcessor) fetchTracks(ctx context.Context, link string) ([]amResource, error) {
+	res := identifyAppleMusic(link)
+	if res == nil {
+		return nil, nil
+	}
+	storefront := amStorefront(link)
+	switch {
+	case res.Kind == processor.ResourceAlbum:
+		return am.fetchPages(ctx, fmt.Sprintf("/v1/catalog/%s/albums/%s/tracks?limit=300", storefront, res.ID))
+	case res.Kind == processor.ResourcePlaylist && !strings.HasPrefix(res.ID, "p."):
+		return am.fetchPages(ctx, fmt.Sprintf("/v1/catalog/%s/playlists/%s/tracks?limit=100", storefront, res.ID))
+	}
+	return nil, nil
+}
+
+// fetchSongs 按ID批量查询歌曲
+func (am *AppleMusicProcessor) fetchSongs(ctx context.Context, storefront string, ids []string) ([]amResource, error) {
+	songs := make([]amResource, 0, len(ids))
+	for i := 0; i < len(ids); i += amSongsChunkSize {
+		chunk := ids[i:min(i+amSongsChunkSize, len(ids))]
+		var page amResponse
+		path := fmt.Sprintf("/v1/catalog/%s/songs?ids=%s", storefront, strings.Join(chunk, ","))
+		if err := am.api(ctx, http.MethodGet, path, &page); err != nil {
+			return nil, err
+		}
+		songs = append(songs, page.Data...)
+	}
+	return songs, nil
+}
+
+// fetchPages 获取分页接口的全部歌曲(忽略音乐视频)
+func (am *AppleMusicProcessor) fetchPages(ctx context.Context, path string) ([]amResource, error) {
+	songs := make([]amResource, 0)
+	for path != "" {
+		var page amResponse
+		if err := am.api(ctx, http.MethodGet, path, &page); err != nil {
+			return nil, err
+		}
+		for _, song := range page.Data {
+			if song.Type == "songs" {
+				songs = append(songs, song)
+			}
+		}
+		path = page.Next
+	}
+	return songs, nil
+}
+
+// amStorefront 链接中的地区, 没有地区时使用默认商店
+func amStorefront(link string) string {
+	if m := appleMusicLinkRe.FindStringSubmatch(link); m != nil && m[1] != "" {
+		return m[1]
+	}
+	return amDefaultStorefront
+}
@@ -0,0 +1,79 @@
+package music
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/storage"
+)
+
+// TestAppleMusicTidyAlbumFolders gamdl 按专辑分目录保存, 不同专辑的同名曲目都能整理
+func TestAppleMusicTidyAlbumFolders(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dist := t.TempDir()
+	backend := storage.GlobalBackend
+	storage.GlobalBackend = &storage.Local{Root: dist}
+	t.Cleanup(func() { storage.GlobalBackend = backend })
+
+	am := &AppleMusicProcessor{}
+	am.Init(&config.Config{
+		CookieCloud: &config.CookieCloudConfig{},
+		Tidy:        &config.TidyConfig{MusicLayout: "{albumartist}/{album}/{title}.{ext}", Collision: storage.CollisionSuffix},
+	})
+	// 与 GamdlCommand 的输出模板一致: <tempDir>/<专辑艺术家>/<专辑>/<音轨号> <标题>
+	for _, song := range []*SongInfo{
+		{SongName: "Intro", SongAlbumArtist: "A", SongAlbum: "First"},
+		{SongName: "Intro", SongAlbumArtist: "A", SongAlbum: "Second"},
+	} {
+		song.MusicPath = filepath.Join(am.tempDir, song.SongAlbumArtist, song.SongAlbum, "01 Intro.m4a")
+		if err := os.MkdirAll(filepath.Dir(song.MusicPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(song.MusicPath, []byte(song.SongAlbum), 0644); err != nil {
+			t.Fatal(err)
+		}
+		am.songs = append(am.songs, song)
+	}
+
+	if err := am.TidyMusic(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, album := range []string{"First", "Second"} {
+		data, err := os.ReadFile(filepath.Join(dist, "A", album, "Intro.m4a"))
+		if err != nil || string(data) != album {
+			t.Errorf("专辑 %s 的曲目未整理: %q, %v", album, data, err)
+		}
+	}
+	if _, err := os.Stat(am.tempDir); !os.IsNotExist(err) {
+		t.Errorf("临时目录未清除: %s", am.tempDir)
+	}
+}
+
+// TestGamdlCommandTemplates 曲目按专辑分目录, 文件名带音轨号
+func TestGamdlCommandTemplates(t *testing.T) {
+	cfg := &config.Config{
+		CookieCloud: &config.CookieCloudConfig{CookieFilePath: "data", CookieFile: "cookies.txt"},
+		AppleMusic:  &config.AppleMusicConfig{DownloadMode: "ytdlp"},
+	}
+	cmd := processor.GamdlCommand(context.Background(), cfg, filepath.Join("temp", "job"), "https://music.apple.com/us/artist/x/1")
+	args := make(map[string]string)
+	for i := 1; i+1 < len(cmd.Args); i += 2 {
+		args[cmd.Args[i]] = cmd.Args[i+1]
+	}
+	want := map[string]string{
+		"--output-path":               "temp",
+		"--album-folder-template":     "job/{album_artist}/{album}",
+		"--single-disc-file-template": "{track:02d} {title}",
+		"--multi-disc-file-template":  "{disc}-{track:02d} {title}",
+		"--no-album-folder-template":  "job",
+	}
+	for k, v := range want {
+		if args[k] != v {
+			t.Errorf("%s = %q, 期望 %q", k, args[k], v)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package music
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// 同步歌词: 按歌词模式嵌入标签或保存为与音乐文件同名的 .lrc/.ttml 附属文件
+
+/* ---------------------- 常量 ---------------------- */
+
+// 歌词模式
+const (
+	LyricsNone     = "none"     // 不保存歌词
+	LyricsEmbedded = "embedded" // 嵌入音乐文件标签
+	LyricsSidecar  = "sidecar"  // 保存为同名 .lrc 文件
+	LyricsBoth     = "both"     // 嵌入标签并保存 .lrc 文件
+)
+
+// PureMusicLyric 没有歌词时嵌入的占位歌词
+const PureMusicLyric = "[00:00:00]此歌曲为没有填词的纯音乐，请您欣赏"
+
+// LyricSidecarExts 歌词附属文件后缀, 整理时随音乐文件一起移动
+var LyricSidecarExts = []string{".lrc", ".ttml"}
+
+// lrcTimeRe LRC 时间标签, 如 [01:23.45]
+var lrcTimeRe = regexp.MustCompile(`(?m)^\[\d+:\d+(?:[.:]\d+)?\]`)
+
+/* ---------------------- 歌词处理 ---------------------- */
+
+// EmbedLyrics 是否将歌词嵌入标签
+func EmbedLyrics(cfg *config.Config) bool {
+	return cfg.Lyrics.Mode == LyricsEmbedded || cfg.Lyrics.Mode == LyricsBoth
+}
+
+// SidecarLyrics 是否保存歌词附属文件
+func SidecarLyrics(cfg *config.Config) bool {
+	return cfg.Lyrics.Mode == LyricsSidecar || cfg.Lyrics.Mode == LyricsBoth
+}
+
+// ApplyLyrics 按歌词模式处理歌词: 同步歌词写入与 musicPath 同名的 .lrc 文件,
+// 不嵌入时清空 song.Lyric(随后写入标签时移除歌词标签)
+func ApplyLyrics(cfg *config.Config, song *SongInfo, musicPath string) {
+	if SidecarLyrics(cfg) && IsTimedLyric(song.Lyric) && song.Lyric != PureMusicLyric {
+		path := LyricSidecarPath(musicPath, ".lrc")
+		if err := os.WriteFile(path, []byte(song.Lyric), 0644); err != nil {
+			utils.WarnWithFormat("[Lyrics] ⚠️ 保存歌词文件失败 %s: %v", path, err)
+		}
+	}
+	if !EmbedLyrics(cfg) {
+		song.Lyric = ""
+	}
+}
+
+// IsTimedLyric 是否为带时间标签的同步歌词
+func IsTimedLyric(lyric string) bool {
+	return lrcTimeRe.MatchString(lyric)
+}
+
+// LyricSidecarPath 与音乐文件同名的歌词文件路径
+func LyricSidecarPath(musicPath, ext string) string {
+	return strings.TrimSuffix(musicPath, filepath.Ext(musicPath)) + ext
+}
+
+// IsLyricSidecar 是否为歌词附属文件
+func IsLyricSidecar(f os.DirEntry) bool {
+	return !f.IsDir() && utils.Contains(LyricSidecarExts, strings.ToLower(filepath.Ext(f.Name())))
+}
+
+// removeLyricSidecars 删除音乐文件对应的歌词附属文件
+func removeLyricSidecars(musicPath string) {
+	for _, ext := range LyricSidecarExts {
+		_ = os.Remove(LyricSidecarPath(musicPath, ext))
+	}
+}
+
+/* ---------------------- TTML ---------------------- */
+
+// TTMLToLRC 将 TTML 歌词(逐行或逐字)转换为逐行 LRC, 每个 <p> 为一行
+func TTMLToLRC(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		sb     strings.Builder
+		line   strings.Builder
+		begin  string
+		inLine bool
+	)
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("解析 TTML 歌词失败: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "p" {
+				continue
+			}
+			inLine = true
+			line.Reset()
+			begin = ""
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "begin" {
+					begin = attr.Value
+				}
+			}
+		case xml.CharData:
+			if inLine {
+				line.Write(t)
+			}
+		case xml.EndElement:
+			if t.Name.Local != "p" || !inLine {
+				continue
+			}
+			inLine = false
+			sec, err := parseTTMLTime(begin)
+			if err != nil {
+				continue
+			}
+			text := strings.Join(strings.Fields(line.String()), " ")
+			cs := int(sec*100 + 0.5) // 百分之一秒
+			fmt.Fprintf(&sb, "[%02d:%02d.%02d]%s\n", cs/6000, cs%6000/100, cs%100, text)
+		}
+	}
+	return sb.String(), nil
+}
+
+// parseTTMLTime 解析 TTML 时间, 如 83.5s、1:23.500、00:01:23.500
+func parseTTMLTime(s string) (float64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "s")
+	if s == "" {
+		return 0, errors.New("时间为空")
+	}
+	var sec float64
+	for _, part := range strings.Split(s, ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return 0, err
+		}
+		sec = sec*60 + v
+	}
+	return sec, nil
+}
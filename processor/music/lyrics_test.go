@@ -0,0 +1,60 @@
+package music
+
+import (
+	"os"
+	"testing"
+)
+
+func TestTTMLToLRC(t *testing.T) {
+	data, err := os.ReadFile("testdata/lyrics.ttml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := TTMLToLRC(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 逐行与逐字 <p> 都输出为一行, 缺少 begin 的行忽略
+	want := "[00:12.50]Is this the real life?\n" +
+		"[00:15.80]Is this just fantasy?\n" +
+		"[01:05.01]Caught in a landslide\n"
+	if got != want {
+		t.Errorf("TTMLToLRC() =\n%s\n期望\n%s", got, want)
+	}
+	if !IsTimedLyric(got) {
+		t.Error("转换结果应为同步歌词")
+	}
+
+	if _, err := TTMLToLRC([]byte(`<tt><body><p begin="1s">未闭合`)); err == nil {
+		t.Error("格式错误的 TTML 应返回错误")
+	}
+}
+
+func TestParseTTMLTime(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"83.5s", 83.5, true},        // 偏移时间
+		{"12s", 12, true},            // 偏移时间(整数)
+		{"7.25", 7.25, true},         // 无单位
+		{"1:23.500", 83.5, true},     // 分:秒
+		{"00:01:23.500", 83.5, true}, // 时:分:秒
+		{"1:00:00.000", 3600, true},  // 超过一小时
+		{" 0:05.1 ", 5.1, true},      // 首尾空白
+		{"", 0, false},               // 空
+		{"abc", 0, false},            // 非数字
+		{"1:xx", 0, false},           // 非法分段
+	}
+	for _, tt := range tests {
+		got, err := parseTTMLTime(tt.in)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseTTMLTime(%q) err = %v, 期望成功 = %v", tt.in, err, tt.ok)
+			continue
+		}
+		if tt.ok && (got-tt.want > 1e-9 || tt.want-got > 1e-9) {
+			t.Errorf("parseTTMLTime(%q) = %v, 期望 %v", tt.in, got, tt.want)
+		}
+	}
+}
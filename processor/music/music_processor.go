@@ -1,6 +1,9 @@
 package music
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,7 +11,10 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/storage"
 	"github.com/nichuanfang/gymdl/utils"
 	"go.senan.xyz/taglib"
 )
@@ -18,27 +24,34 @@ type Processor interface {
 	processor.Processor
 	// 歌曲元信息列表
 	Songs() []*SongInfo
-	// 下载音乐
-	DownloadMusic(url string, callback func(string)) error
-	// 构建下载命令
-	DownloadCommand(url string) *exec.Cmd
+	// 下载音乐(ctx 取消时中止下载并清理临时目录, 支持续传的列表保留已完成的部分)
+	DownloadMusic(ctx context.Context, url string, report utils.ProgressFunc) error
+	// 构建下载命令(ctx 取消时终止外部进程)
+	DownloadCommand(ctx context.Context, url string) *exec.Cmd
 	// 音乐整理之前的处理(如读取,嵌入元数据,刮削等)
 	BeforeTidy() error
 	// 是否需要移除DRM
 	NeedRemoveDRM() bool
 	// 移除DRM
 	DRMRemove() error
-	// 音乐整理
-	TidyMusic() error
+	// 音乐整理(ctx 取消时中止上传)
+	TidyMusic(ctx context.Context) error
 	// 加密后缀
 	EncryptedExts() []string
 	// 非加密后缀
 	DecryptedExts() []string
 }
 
+// PartialProcessor 列表下载允许部分歌曲失败的处理器
+type PartialProcessor interface {
+	// Failures 下载失败的歌曲及原因
+	Failures() []string
+}
+
 /* ---------------------- 音乐结构体定义 ---------------------- */
 // SongInfo 音乐信息
 type SongInfo struct {
+	SongID          string // 平台歌曲ID
 	SongName        string // 音乐名称
 	SongArtists     string // 艺术家
 	SongAlbum       string // 专辑
@@ -46,14 +59,19 @@ type SongInfo struct {
 	FileExt         string // 格式
 	MusicSize       int64  // 音乐大小
 	Bitrate         string // 码率
+	Quality         string // 音质等级(网易云: standard/exhigh/lossless/hires)
 	Duration        int    // 时长
 	Url             string //下载地址
 	MusicPath       string //音乐文件路径
 	PicUrl          string // 封面图url
-	Lyric           string // 歌词
+	Lyric           string // 歌词(同步歌词为 LRC 格式, 按歌词模式嵌入标签或保存为 .lrc)
 	Year            int    // 年份
+	TrackNumber     int    // 音轨号(专辑内序号/电台节目期数)
+	DiscNumber      int    // 碟片号
 	Genre           string //流派
+	Comment         string // 备注(如曲目简介)
 	Tidy            string // 入库方式(默认/webdav)
+	Hash            string // 文件哈希(曲库去重)
 }
 
 type imageResult struct {
@@ -85,21 +103,42 @@ var SpotifyTempDir = filepath.Join(BaseTempDir, "Spotify")
 
 /* ---------------------- 音乐下载相关业务函数 ---------------------- */
 
+// tempFile 临时目录中的文件
+type tempFile struct {
+	path  string
+	entry os.DirEntry
+}
+
+// readTempDir 递归列出临时目录中的文件(gamdl 按专辑分目录保存)
+func readTempDir(tempDir string) ([]tempFile, error) {
+	files := make([]tempFile, 0)
+	err := filepath.WalkDir(tempDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			files = append(files, tempFile{path: path, entry: d})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取临时目录失败: %w", err)
+	}
+	return files, nil
+}
+
 // 读取音乐目录 返回元信息列表
 func ReadMusicDir(tempDir string, tidyType string, p Processor) ([]*SongInfo, error) {
-	files, err := os.ReadDir(tempDir)
+	files, err := readTempDir(tempDir)
 	if err != nil {
-		return nil, fmt.Errorf("读取临时目录失败: %w", err)
+		return nil, err
 	}
 	songs := make([]*SongInfo, 0, len(files))
-	for _, f := range files {
-		//目录跳过
-		if f.IsDir() {
-			continue
-		}
+	for _, tf := range files {
+		f := tf.entry
 		ext := strings.ToLower(filepath.Ext(f.Name()))
 		if utils.Contains(p.DecryptedExts(), ext) {
-			fullPath := filepath.Join(tempDir, f.Name())
+			fullPath := tf.path
 			song, err := ReadTags(fullPath)
 			//嵌入默认标签
 			FillDefaultTags(fullPath, song)
@@ -107,12 +146,121 @@ func ReadMusicDir(tempDir string, tidyType string, p Processor) ([]*SongInfo, er
 				return nil, fmt.Errorf("处理文件 %s 失败: %w", f.Name(), err)
 			}
 			song.Tidy = tidyType
+			song.MusicPath = fullPath
 			songs = append(songs, song)
 		}
 	}
 	return songs, nil
 }
 
+// SkipLibraryDuplicates 过滤曲库中已存在的歌曲(按平台ID或文件哈希),并删除对应的临时文件
+func SkipLibraryDuplicates(platform processor.LinkType, songs []*SongInfo) []*SongInfo {
+	kept := make([]*SongInfo, 0, len(songs))
+	for _, song := range songs {
+		if song.Hash == "" && song.MusicPath != "" {
+			hash, err := core.HashFile(song.MusicPath)
+			if err != nil {
+				utils.WarnWithFormat("[Library] 计算文件哈希失败 %s: %v", song.MusicPath, err)
+			}
+			song.Hash = hash
+		}
+		if e, ok := core.GlobalLibrary.Contains(string(platform), song.SongID, song.Hash); ok {
+			core.LogLibraryHit(string(platform), e)
+			_ = os.Remove(song.MusicPath)
+			removeLyricSidecars(song.MusicPath)
+			continue
+		}
+		kept = append(kept, song)
+	}
+	return kept
+}
+
+// TidyMusicDir 将临时目录中的音乐文件按路径模板整理到存储后端,完成后清除临时目录
+func TidyMusicDir(ctx context.Context, tag string, p Processor, tempDir string, cfg *config.Config) error {
+	files, err := readTempDir(tempDir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		utils.WarnWithFormat("[%s] ⚠️ 未找到待整理的音乐文件", tag)
+		return errors.New("未找到待整理的音乐文件")
+	}
+	// 无论整理成功与否都清除临时目录
+	defer func() {
+		_ = processor.RemoveTempDir(tempDir)
+	}()
+
+	songs := make(map[string]*SongInfo)
+	for _, song := range p.Songs() {
+		songs[song.MusicPath] = song
+	}
+	for _, tf := range files {
+		f := tf.entry
+		// 歌词附属文件随音乐文件一起整理
+		if IsLyricSidecar(f) {
+			continue
+		}
+		if !utils.FilterMusicFile(f, p.EncryptedExts(), p.DecryptedExts()) {
+			utils.DebugWithFormat("[%s] 跳过非音乐文件: %s", tag, f.Name())
+			continue
+		}
+		src := tf.path
+		song, ok := songs[src]
+		if !ok {
+			if song, err = ReadTags(src); err != nil {
+				utils.WarnWithFormat("[%s] ⚠️ 读取标签失败 %s: %v", tag, f.Name(), err)
+				song = &SongInfo{SongName: strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))}
+			}
+		}
+		if _, err := TidySong(ctx, tag, string(p.Name()), song, src, cfg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TidySong 按路径模板整理单个音乐文件及其歌词附属文件, 返回存入的 key(同名文件已存在而跳过时为空)
+func TidySong(ctx context.Context, tag, platform string, song *SongInfo, musicPath string, cfg *config.Config) (string, error) {
+	ext := filepath.Ext(musicPath)
+	stem := storage.RenderStem(storage.Layout(cfg, platform, false), songFields(platform, song))
+	key, err := storage.Place(ctx, tag, musicPath, stem+ext, cfg.Tidy.Collision)
+	if err != nil || key == "" {
+		return key, err
+	}
+	stem = strings.TrimSuffix(key, ext)
+	for _, sidecarExt := range LyricSidecarExts {
+		sidecar := LyricSidecarPath(musicPath, sidecarExt)
+		if _, err := os.Stat(sidecar); err != nil {
+			continue
+		}
+		_ = storage.Store(ctx, tag, sidecar, stem+sidecarExt)
+	}
+	return key, nil
+}
+
+// songFields 音乐路径模板字段
+func songFields(platform string, song *SongInfo) storage.Fields {
+	artist := cmp.Or(song.SongArtists, "未知艺术家")
+	fields := storage.Fields{
+		"platform":    platform,
+		"id":          song.SongID,
+		"title":       cmp.Or(song.SongName, song.SongID),
+		"artist":      artist,
+		"albumartist": cmp.Or(song.SongAlbumArtist, artist),
+		"album":       cmp.Or(song.SongAlbum, "未知专辑"),
+		"year":        "",
+		"track":       song.TrackNumber,
+		"disc":        max(song.DiscNumber, 1),
+		"genre":       song.Genre,
+		"quality":     song.Quality,
+		"bitrate":     song.Bitrate,
+	}
+	if song.Year > 0 {
+		fields["year"] = song.Year
+	}
+	return fields
+}
+
 // ReadTags 读取音乐元数据
 func ReadTags(path string) (*SongInfo, error) {
 	tags, err := taglib.ReadTags(path)
@@ -167,9 +315,23 @@ func ReadTags(path string) (*SongInfo, error) {
 		songInfo.Genre = ge[0]
 	}
 
+	if tn, ok := tags[taglib.TrackNumber]; ok && len(tn) > 0 {
+		songInfo.TrackNumber = tagNumber(tn[0])
+	}
+
+	if dn, ok := tags[taglib.DiscNumber]; ok && len(dn) > 0 {
+		songInfo.DiscNumber = tagNumber(dn[0])
+	}
+
 	return songInfo, nil
 }
 
+// tagNumber 解析音轨号/碟片号标签, 如 3、3/12
+func tagNumber(v string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(strings.SplitN(v, "/", 2)[0]))
+	return n
+}
+
 // FillDefaultTags 标签写入默认值
 func FillDefaultTags(path string, info *SongInfo) {
 	updates := make(map[string][]string)
@@ -188,7 +350,7 @@ func FillDefaultTags(path string, info *SongInfo) {
 
 	//默认歌词
 	if info.Lyric == "" {
-		info.Lyric = "[00:00:00]此歌曲为没有填词的纯音乐，请您欣赏"
+		info.Lyric = PureMusicLyric
 		updates[taglib.Lyrics] = []string{info.Lyric}
 	}
 
@@ -200,22 +362,44 @@ func FillDefaultTags(path string, info *SongInfo) {
 
 	if len(updates) > 0 {
 		if err := taglib.WriteTags(path, updates, 0); err != nil {
-			utils.WarnWithFormat("write default tags failed: %w", err)
+			utils.WarnWithFormat("write default tags failed: %v", err)
 		}
 	}
 }
 
-// WriteTags 嵌入标签
-func WriteTags(song *SongInfo, filePath string) error {
-	// 写入文本标签
+// songTags 待写入的文本标签
+func songTags(song *SongInfo) map[string][]string {
+	albumArtist := song.SongAlbumArtist
+	if albumArtist == "" {
+		albumArtist = song.SongArtists
+	}
 	tags := map[string][]string{
 		taglib.Title:       {song.SongName},
 		taglib.Artist:      {song.SongArtists},
 		taglib.Album:       {song.SongAlbum},
-		taglib.AlbumArtist: {song.SongArtists},
+		taglib.AlbumArtist: {albumArtist},
 		taglib.Date:        {strconv.Itoa(song.Year)},
 		taglib.Lyrics:      {song.Lyric},
 	}
+	if song.TrackNumber > 0 {
+		tags[taglib.TrackNumber] = []string{strconv.Itoa(song.TrackNumber)}
+	}
+	if song.DiscNumber > 0 {
+		tags[taglib.DiscNumber] = []string{strconv.Itoa(song.DiscNumber)}
+	}
+	if song.Genre != "" {
+		tags[taglib.Genre] = []string{song.Genre}
+	}
+	if song.Comment != "" {
+		tags[taglib.Comment] = []string{song.Comment}
+	}
+	return tags
+}
+
+// WriteTags 嵌入标签
+func WriteTags(song *SongInfo, filePath string) error {
+	// 写入文本标签
+	tags := songTags(song)
 	// 写入文本标签（opts传taglib.Clear则清除原标签，传0则不清除）
 	if err := taglib.WriteTags(filePath, tags, 0); err != nil {
 		return fmt.Errorf("write metadata failed for %s: %w", filePath, err)
@@ -226,14 +410,7 @@ func WriteTags(song *SongInfo, filePath string) error {
 // WriteTagsWithCoverFile 嵌入标签(封面通过文件嵌入)
 func WriteTagsWithCoverFile(song *SongInfo, filePath string, coverFilePath string) error {
 	// 写入文本标签
-	tags := map[string][]string{
-		taglib.Title:       {song.SongName},
-		taglib.Artist:      {song.SongArtists},
-		taglib.Album:       {song.SongAlbum},
-		taglib.AlbumArtist: {song.SongArtists},
-		taglib.Date:        {strconv.Itoa(song.Year)},
-		taglib.Lyrics:      {song.Lyric},
-	}
+	tags := songTags(song)
 	var err error
 	// 写入文本标签（opts传taglib.Clear则清除原标签，传0则不清除）
 	if err = taglib.WriteTags(filePath, tags, 0); err != nil {
@@ -248,13 +425,13 @@ func WriteTagsWithCoverFile(song *SongInfo, filePath string, coverFilePath strin
 	return nil
 }
 
-// WriteTagsWithCoverURL 嵌入标签(封面通过url嵌入)
+// WriteTagsWithCoverURL 嵌入标签(封面通过url以原始分辨率嵌入)
 func WriteTagsWithCoverURL(song *SongInfo, filePath string) error {
 	imageCh := make(chan imageResult, 1)
 
 	if song.PicUrl != "" {
 		go func() {
-			data, err := utils.FetchImage(song.PicUrl)
+			data, err := utils.FetchImageOriginal(song.PicUrl)
 			imageCh <- imageResult{data, err}
 		}()
 	} else {
@@ -263,14 +440,7 @@ func WriteTagsWithCoverURL(song *SongInfo, filePath string) error {
 	}
 
 	// 写入文本标签
-	tags := map[string][]string{
-		taglib.Title:       {song.SongName},
-		taglib.Artist:      {song.SongArtists},
-		taglib.Album:       {song.SongAlbum},
-		taglib.AlbumArtist: {song.SongArtists},
-		taglib.Date:        {strconv.Itoa(song.Year)},
-		taglib.Lyrics:      {song.Lyric},
-	}
+	tags := songTags(song)
 
 	//opts传taglib.Clear则会清除原标签 传0则不清除
 	if err := taglib.WriteTags(filePath, tags, 0); err != nil {
@@ -1,23 +1,27 @@
 package music
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"net/http"
-	"os"
+	neturl "net/url"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/XiaoMengXinX/Music163Api-Go/api"
 	"github.com/XiaoMengXinX/Music163Api-Go/types"
 	ncmutils "github.com/XiaoMengXinX/Music163Api-Go/utils"
-	downloader "github.com/XiaoMengXinX/SimpleDownloader"
 	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/utils"
 
@@ -25,19 +29,69 @@ import (
 	"github.com/nichuanfang/gymdl/processor"
 )
 
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkNetEase,
+		[]string{
+			"music.163.com",
+			"y.music.163.com",
+			"163cn.tv",
+			"163cn.link",
+		},
+		[]*regexp.Regexp{
+			// 网页端 / 移动端链接
+			regexp.MustCompile(`^https?://(?:y\.)?music\.163\.com/(?:#/)?(?:m/)?(?:song|playlist|album|artist|djradio|program)\?id=\d+(?:&\S*)?$`),
+
+			// 网易云 App 短链
+			regexp.MustCompile(`^https?://163cn\.tv/[A-Za-z0-9]+(?:\?.*)?$`),
+
+			// 新版短链
+			regexp.MustCompile(`^https?://163cn\.link/[A-Za-z0-9]+(?:\?.*)?$`),
+		},
+		func() processor.Processor { return &NetEaseProcessor{} },
+	).WithIdentifier(identifyNetEase)
+}
+
+// neteaseLinkRe 网页端/移动端链接中的资源类型
+var neteaseLinkRe = regexp.MustCompile(`music\.163\.com/(?:#/)?(?:m/)?(song|playlist|album|artist|djradio|program)\?`)
+
+// neteaseKinds 链接路径 -> 资源类型
+var neteaseKinds = map[string]processor.ResourceKind{
+	"song":     processor.ResourceTrack,
+	"playlist": processor.ResourcePlaylist,
+	"album":    processor.ResourceAlbum,
+	"artist":   processor.ResourceArtist,
+	"djradio":  processor.ResourceRadio,
+	"program":  processor.ResourceProgram,
+}
+
+// identifyNetEase 识别网易云链接, 短链需还原后识别
+func identifyNetEase(link string) *processor.Resource {
+	m := neteaseLinkRe.FindStringSubmatch(link)
+	id := processor.QueryParam(link, "id")
+	if m == nil || id == "" {
+		return nil
+	}
+	return processor.NewResource(neteaseKinds[m[1]], id, "https://music.163.com/%s?id=%s", m[1], id)
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type NetEaseProcessor struct {
-	cfg     *config.Config //配置文件
-	songs   []*SongInfo    //歌曲元信息列表
-	tempDir string         //临时目录
-	musicU  string         //会员cookie
+	cfg         *config.Config    //配置文件
+	songs       []*SongInfo       //歌曲元信息列表
+	failures    []string          //列表下载中失败的歌曲及原因
+	unavailable map[string]string //无法下载的歌曲ID -> 原因(下架/需要VIP等)
+	tempDir     string            //临时目录
+	musicU      string            //会员cookie
 }
 
 // Init  初始化
 func (ncm *NetEaseProcessor) Init(cfg *config.Config) {
 	ncm.cfg = cfg
 	ncm.songs = make([]*SongInfo, 0)
+	ncm.unavailable = make(map[string]string)
 	ncm.tempDir = processor.BuildOutputDir(NCMTempDir)
 	cookiePath := filepath.Join(cfg.CookieCloud.CookieFilePath, cfg.CookieCloud.CookieFile)
 	ncm.musicU = utils.GetCookieValue(cookiePath, ".music.163.com", "MUSIC_U")
@@ -53,24 +107,44 @@ func (ncm *NetEaseProcessor) Songs() []*SongInfo {
 	return ncm.songs
 }
 
+func (ncm *NetEaseProcessor) Failures() []string {
+	return ncm.failures
+}
+
 /* ------------------------ 下载逻辑 ------------------------ */
 
-func (ncm *NetEaseProcessor) DownloadMusic(url string, callback func(string)) error {
+func (ncm *NetEaseProcessor) DownloadMusic(ctx context.Context, url string, report utils.ProgressFunc) error {
 	start := time.Now()
 	utils.InfoWithFormat("[NCM] 🎵 开始下载: %s", url)
-	ncmType, musicID := utils.ParseMusicID(url)
-	switch ncmType {
-	case 1:
+	kind, musicID := ncm.parseLink(url)
+	if kind != processor.ResourceTrack && kind != processor.ResourceUnknown {
+		// 列表使用固定的临时目录, 中断后再次下载时续传
+		ncm.tempDir = filepath.Join(NCMTempDir, fmt.Sprintf("%s-%d", kind, musicID))
+	}
+	switch kind {
+	case processor.ResourceTrack:
 		//单曲下载
-		return ncm.downloadSingle(musicID, start, callback)
-	case 2:
+		return ncm.downloadSingle(ctx, musicID, start, report)
+	case processor.ResourcePlaylist:
 		//列表下载
-		return ncm.downloadPlaylist(musicID, start, callback)
+		return ncm.downloadPlaylist(ctx, musicID, start, report)
+	case processor.ResourceAlbum:
+		//专辑下载
+		return ncm.downloadAlbum(ctx, musicID, start, report)
+	case processor.ResourceArtist:
+		//歌手热门歌曲/全部专辑下载
+		return ncm.downloadArtist(ctx, musicID, start, report)
+	case processor.ResourceRadio:
+		//电台下载
+		return ncm.downloadRadio(ctx, musicID, start, report)
+	case processor.ResourceProgram:
+		//电台节目下载
+		return ncm.downloadProgram(ctx, musicID, start, report)
 	}
 	return errors.New("不支持的下载类型")
 }
 
-func (ncm *NetEaseProcessor) DownloadCommand(url string) *exec.Cmd {
+func (ncm *NetEaseProcessor) DownloadCommand(ctx context.Context, url string) *exec.Cmd {
 	return nil
 }
 
@@ -80,11 +154,15 @@ func (ncm *NetEaseProcessor) BeforeTidy() error {
 	for _, song := range ncm.songs {
 		fileName = filepath.Join(ncm.tempDir, ncm.safeFileName(song))
 		coverFileName = filepath.Join(ncm.tempDir, ncm.safeCoverFileName(song))
+		ApplyLyrics(ncm.cfg, song, fileName)
 		err := WriteTagsWithCoverFile(song, fileName, coverFileName)
 		if err != nil {
 			return err
 		}
+		song.MusicPath = fileName
 	}
+	// 跳过曲库中已存在的歌曲
+	ncm.songs = SkipLibraryDuplicates(ncm.Name(), ncm.songs)
 	return nil
 }
 
@@ -96,24 +174,12 @@ func (ncm *NetEaseProcessor) DRMRemove() error {
 	return nil
 }
 
-func (ncm *NetEaseProcessor) TidyMusic() error {
-	files, err := os.ReadDir(ncm.tempDir)
-	if err != nil {
-		return fmt.Errorf("读取临时目录失败: %w", err)
-	}
-	if len(files) == 0 {
-		utils.WarnWithFormat("[AppleMusic] ⚠️ 未找到待整理的音乐文件")
-		return errors.New("未找到待整理的音乐文件")
-	}
-
-	switch ncm.cfg.Tidy.Mode {
-	case 1:
-		return ncm.tidyToLocal(files)
-	case 2:
-		return ncm.tidyToWebDAV(files, core.GlobalWebDAV)
-	default:
-		return fmt.Errorf("未知整理模式: %d", ncm.cfg.Tidy.Mode)
+func (ncm *NetEaseProcessor) TidyMusic(ctx context.Context) error {
+	if len(ncm.songs) == 0 {
+		_ = processor.RemoveTempDir(ncm.tempDir)
+		return core.ErrInLibrary
 	}
+	return TidyMusicDir(ctx, "NetEase", ncm, ncm.tempDir, ncm.cfg)
 }
 
 func (ncm *NetEaseProcessor) EncryptedExts() []string {
@@ -126,10 +192,38 @@ func (ncm *NetEaseProcessor) DecryptedExts() []string {
 
 /* ------------------------ 拓展方法 ------------------------ */
 
+// parseLink 解析链接对应的资源类型与ID, 短链先还原
+func (ncm *NetEaseProcessor) parseLink(link string) (processor.ResourceKind, int) {
+	res := identifyNetEase(link)
+	if res == nil {
+		res = identifyNetEase(utils.GetRedirectUrl(link))
+	}
+	if res != nil {
+		if id, err := strconv.Atoi(res.ID); err == nil {
+			return res.Kind, id
+		}
+	}
+	// 降级: 旧版分享链接按单曲/歌单解析
+	ncmType, musicID := utils.ParseMusicID(link)
+	if musicID == 0 {
+		return processor.ResourceUnknown, 0
+	}
+	if ncmType == 2 {
+		return processor.ResourcePlaylist, musicID
+	}
+	return processor.ResourceTrack, musicID
+}
+
 // downloadSingle 单曲下载
-func (ncm *NetEaseProcessor) downloadSingle(musicID int, start time.Time, callback func(string)) error {
+func (ncm *NetEaseProcessor) downloadSingle(ctx context.Context, musicID int, start time.Time, report utils.ProgressFunc) error {
 	var err error
 
+	if e, ok := core.GlobalLibrary.Has(string(ncm.Name()), strconv.Itoa(musicID)); ok {
+		core.LogLibraryHit("NCM", e)
+		report.Message(utils.PhaseDone, "已在曲库中，跳过下载")
+		return core.ErrInLibrary
+	}
+
 	utils.DebugWithFormat("[NCM] 获取单曲数据: ID=%d", musicID)
 	detail, songURL, songLyric, err := ncm.FetchSongData(musicID, ncm.cfg)
 	if err != nil {
@@ -137,12 +231,6 @@ func (ncm *NetEaseProcessor) downloadSingle(musicID int, start time.Time, callba
 		return err
 	}
 
-	if len(detail.Songs) == 0 || len(songURL.Data) == 0 || songURL.Data[0].Url == "" {
-		errMsg := "未获取到有效歌曲信息或歌曲无下载地址"
-		utils.ErrorWithFormat("[NCM] ❌ %s: ID=%d", errMsg, musicID)
-		return errors.New(errMsg)
-	}
-
 	// 构建歌曲元信息
 	songInfo := ncm.buildSongInfo(ncm.cfg, detail, songURL, songLyric)
 	fileName := ncm.safeFileName(songInfo)
@@ -156,20 +244,24 @@ func (ncm *NetEaseProcessor) downloadSingle(musicID int, start time.Time, callba
 
 	// 下载文件
 	utils.InfoWithFormat("[NCM] ⬇️ 开始下载: %s", fileName)
-	if err := ncm.downloadFile(songURL.Data[0].Url, fileName, songInfo.PicUrl, coverFileName, ncm.tempDir); err != nil {
+	if err := ncm.downloadFile(ctx, songURL.Data[0].Url, fileName, songInfo.PicUrl, coverFileName, ncm.tempDir); err != nil {
 		_ = processor.RemoveTempDir(ncm.tempDir)
+		if ctx.Err() != nil {
+			utils.InfoWithFormat("[NCM] 🚫 下载已取消: %s", fileName)
+			return err
+		}
 		utils.ErrorWithFormat("[NCM] ❌ 下载失败: %v", err)
 		return fmt.Errorf("下载失败: %w", err)
 	}
 	// 更新元信息列表
 	ncm.songs = append(ncm.songs, songInfo)
 	utils.InfoWithFormat("[NCM] ✅ 下载完成: %s （耗时 %v）", fileName, time.Since(start).Truncate(time.Millisecond))
-	callback(fmt.Sprintf("下载完成: %s （耗时 %v）", fileName, time.Since(start).Truncate(time.Millisecond)))
+	report.Message(utils.PhaseDone, "下载完成: %s （耗时 %v）", fileName, time.Since(start).Truncate(time.Millisecond))
 	return nil
 }
 
 // downloadPlaylist 列表下载
-func (ncm *NetEaseProcessor) downloadPlaylist(musicID int, start time.Time, callback func(string)) error {
+func (ncm *NetEaseProcessor) downloadPlaylist(ctx context.Context, musicID int, start time.Time, report utils.ProgressFunc) error {
 	utils.DebugWithFormat("[NCM] 获取歌单数据: ID=%d", musicID)
 	detail, err := ncm.FetchPlaylistData(musicID, ncm.cfg)
 	if err != nil {
@@ -183,6 +275,10 @@ func (ncm *NetEaseProcessor) downloadPlaylist(musicID int, start time.Time, call
 		return errors.New(errMsg)
 	}
 
+	if len(detail.Playlist.TrackIds) < detail.Playlist.TrackCount {
+		utils.WarnWithFormat("[NCM] ⚠️ 歌单曲目不完整: 共 %d 首, 仅获取到 %d 首(私密歌单需登录)", detail.Playlist.TrackCount, len(detail.Playlist.TrackIds))
+	}
+
 	// 批量获取歌曲信息（包含歌词）
 	trackIDs := make([]int, len(detail.Playlist.TrackIds))
 	for i, track := range detail.Playlist.TrackIds {
@@ -194,47 +290,158 @@ func (ncm *NetEaseProcessor) downloadPlaylist(musicID int, start time.Time, call
 		return err
 	}
 
-	utils.InfoWithFormat("[NCM] 开始下载歌单: %s (%d首)", detail.Playlist.Name, detail.Playlist.TrackCount)
-	callback(fmt.Sprintf("开始下载歌单: %s (%d首)", detail.Playlist.Name, detail.Playlist.TrackCount))
+	list := &ncmTrackList{Kind: "歌单", Name: detail.Playlist.Name, Songs: ncm.orderSongs(trackIDs, songMap)}
+	return ncm.downloadTracks(ctx, list, start, report)
+}
+
+// downloadTracks 下载列表中的歌曲(歌单/专辑/歌手/电台共用)
+// 按配置并发下载, 单曲失败重试后跳过并在结束时汇报; 已完成的歌曲记录在进度文件中, 中断后再次下载可续传
+func (ncm *NetEaseProcessor) downloadTracks(ctx context.Context, list *ncmTrackList, start time.Time, report utils.ProgressFunc) error {
+	total := len(list.Songs)
+	if total == 0 {
+		errMsg := "未获取到有效歌曲信息或歌曲无下载地址"
+		utils.ErrorWithFormat("[NCM] ❌ %s: %s %s", errMsg, list.Kind, list.Name)
+		return errors.New(errMsg)
+	}
+	utils.InfoWithFormat("[NCM] 开始下载%s: %s (%d首)", list.Kind, list.Name, total)
+	report.Emit(utils.Progress{Phase: utils.PhaseDownload, Total: total, Item: list.Name, Message: "开始下载" + list.Kind})
 
 	//创建下载目录
-	if err = processor.CreateOutputDir(ncm.tempDir); err != nil {
+	if err := processor.CreateOutputDir(ncm.tempDir); err != nil {
 		return err
 	}
-	var fileName string
-	var coverFileName string
-	for index, track := range detail.Playlist.TrackIds {
-		songInfo, ok := songMap[track.Id]
-		if !ok {
-			utils.WarnWithFormat("[NCM] ⚠️ 歌曲信息缺失，跳过: ID=%d", track.Id)
+	progress := loadNCMProgress(ncm.tempDir)
+
+	results := make([]error, total)
+	inLibrary := make([]bool, total)
+	sem := make(chan struct{}, ncm.cfg.NetEase.Concurrency)
+	var wg sync.WaitGroup
+	var finished atomic.Int32
+	for index, songInfo := range list.Songs {
+		if e, ok := core.GlobalLibrary.Has(string(ncm.Name()), songInfo.SongID); ok {
+			core.LogLibraryHit("NCM", e)
+			inLibrary[index] = true
 			continue
 		}
-		callback(fmt.Sprintf("开始下载第%d首...", index+1))
-		utils.InfoWithFormat("[NCM] 正在下载第%d首: %s", index+1, songInfo.SongName)
-		fileName = ncm.safeFileName(songInfo)
-		coverFileName = ncm.safeCoverFileName(songInfo)
-		if err = ncm.downloadFile(songInfo.Url, fileName, songInfo.PicUrl, coverFileName, ncm.tempDir); err != nil {
-			utils.ErrorWithFormat("[NCM] ❌ 歌单下载中断，第%d首下载失败: %v", index+1, err)
-			return err
+		wg.Add(1)
+		go func(index int, songInfo *SongInfo) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[index] = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			results[index] = ncm.downloadTrack(ctx, songInfo, progress)
+			n := finished.Add(1)
+			switch {
+			case results[index] == nil:
+				report.Emit(utils.Progress{Phase: utils.PhaseDownload, Current: int(n), Total: total, Item: songInfo.SongName, Message: "下载完成"})
+			case ctx.Err() == nil:
+				report.Emit(utils.Progress{Phase: utils.PhaseDownload, Current: int(n), Total: total, Item: songInfo.SongName, Message: "下载失败"})
+			}
+		}(index, songInfo)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		// 保留临时目录与进度文件, 再次下载该列表时跳过已完成的歌曲
+		utils.InfoWithFormat("[NCM] 🚫 %s下载已中断: %s (已完成 %d 首)", list.Kind, list.Name, progress.Len())
+		return ctx.Err()
+	}
+
+	skipped := 0
+	for index, songInfo := range list.Songs {
+		switch {
+		case inLibrary[index]:
+			skipped++
+		case results[index] == nil:
+			ncm.songs = append(ncm.songs, songInfo)
+		default:
+			ncm.failures = append(ncm.failures, fmt.Sprintf("%s - %s: %v", songInfo.SongArtists, songInfo.SongName, results[index]))
 		}
+	}
+	ncm.failures = append(ncm.failures, ncm.unlistedFailures(list.Songs)...)
 
-		ncm.songs = append(ncm.songs, songInfo)
-		utils.InfoWithFormat("[NCM] ✅ 下载完成: %s （耗时 %v）", fileName, time.Since(start).Truncate(time.Millisecond))
-		callback(fmt.Sprintf("下载完成: %s （耗时 %v）", fileName, time.Since(start).Truncate(time.Millisecond)))
+	if len(ncm.songs) == 0 {
+		_ = processor.RemoveTempDir(ncm.tempDir)
+		if len(ncm.failures) == 0 {
+			utils.InfoWithFormat("[NCM] ⏭️ %s歌曲均已在曲库中: %s", list.Kind, list.Name)
+			return core.ErrInLibrary
+		}
+		utils.ErrorWithFormat("[NCM] ❌ %s下载失败: %s", list.Kind, list.Name)
+		return fmt.Errorf("%d 首歌曲全部下载失败:\n%s", len(ncm.failures), strings.Join(ncm.failures, "\n"))
 	}
 
-	utils.InfoWithFormat("[NCM] ✅ 歌单下载完成: %s （耗时 %v）", detail.Playlist.Name, time.Since(start).Truncate(time.Millisecond))
-	callback(fmt.Sprintf("歌单下载完成: %s （耗时 %v）", detail.Playlist.Name, time.Since(start).Truncate(time.Millisecond)))
+	summary := fmt.Sprintf("%s下载完成: %s, 成功 %d 首 / 失败 %d 首 / 已在曲库 %d 首 （耗时 %v）",
+		list.Kind, list.Name, len(ncm.songs), len(ncm.failures), skipped, time.Since(start).Truncate(time.Millisecond))
+	utils.InfoWithFormat("[NCM] ✅ %s", summary)
+	for _, f := range ncm.failures {
+		utils.WarnWithFormat("[NCM] ⚠️ 下载失败: %s", f)
+	}
+	report.Emit(utils.Progress{Phase: utils.PhaseDone, Total: total, Message: summary})
 	return nil
 }
 
+// unlistedFailures 无法下载且不在列表中的歌曲(如未获取到歌曲信息), 按ID排序
+func (ncm *NetEaseProcessor) unlistedFailures(songs []*SongInfo) []string {
+	listed := make(map[string]bool, len(songs))
+	for _, song := range songs {
+		listed[song.SongID] = true
+	}
+	failures := make([]string, 0)
+	for _, id := range slices.Sorted(maps.Keys(ncm.unavailable)) {
+		if !listed[id] {
+			failures = append(failures, fmt.Sprintf("ID=%s: %s", id, ncm.unavailable[id]))
+		}
+	}
+	return failures
+}
+
+// downloadTrack 下载列表中的单首歌曲, 失败时按配置重试; 进度文件中已完成的歌曲直接复用
+func (ncm *NetEaseProcessor) downloadTrack(ctx context.Context, songInfo *SongInfo, progress *ncmProgress) error {
+	fileName := ncm.safeFileName(songInfo)
+	coverFileName := ncm.safeCoverFileName(songInfo)
+	if progress.Done(songInfo.SongID, fileName) {
+		utils.InfoWithFormat("[NCM] ⏩ 已下载，跳过: %s", fileName)
+		return nil
+	}
+	if songInfo.Url == "" {
+		return errors.New(ncm.unavailable[songInfo.SongID])
+	}
+
+	var err error
+	for attempt := 0; attempt <= ncm.cfg.NetEase.Retries; attempt++ {
+		if attempt > 0 {
+			utils.WarnWithFormat("[NCM] 🔁 第%d次重试: %s", attempt, fileName)
+			select {
+			case <-time.After(time.Duration(attempt) * 2 * time.Second):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		utils.InfoWithFormat("[NCM] ⬇️ 开始下载: %s", fileName)
+		if err = ncm.downloadFile(ctx, songInfo.Url, fileName, songInfo.PicUrl, coverFileName, ncm.tempDir); err == nil {
+			progress.Add(songInfo.SongID, fileName)
+			utils.InfoWithFormat("[NCM] ✅ 下载完成: %s", fileName)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		utils.WarnWithFormat("[NCM] ⚠️ 下载失败: %s, %v", fileName, err)
+	}
+	return err
+}
+
 // FetchSongData 获取单曲信息
 func (ncm *NetEaseProcessor) FetchSongData(musicID int, cfg *config.Config) (*types.SongsDetailData, *types.SongsURLData, *types.SongLyricData, error) {
 	utils.DebugWithFormat("[NCM] 请求歌曲信息中... ID=%d", musicID)
 
 	batch := api.NewBatch(
 		api.BatchAPI{Key: api.SongDetailAPI, Json: api.CreateSongDetailReqJson([]int{musicID})},
-		api.BatchAPI{Key: api.SongUrlAPI, Json: api.CreateSongURLJson(api.SongURLConfig{Ids: []int{musicID}})},
 		api.BatchAPI{Key: api.SongLyricAPI, Json: api.CreateSongLyricReqJson(musicID)},
 	)
 
@@ -251,21 +458,30 @@ func (ncm *NetEaseProcessor) FetchSongData(musicID int, cfg *config.Config) (*ty
 	_, parsed := batch.Parse()
 
 	var detail types.SongsDetailData
-	var urls types.SongsURLData
 	var lyrics types.SongLyricData
 
 	if err := json.Unmarshal([]byte(parsed[api.SongDetailAPI]), &detail); err != nil {
 		return nil, nil, nil, fmt.Errorf("解析歌曲详情失败: %w", err)
 	}
-	if err := json.Unmarshal([]byte(parsed[api.SongUrlAPI]), &urls); err != nil {
-		return nil, nil, nil, fmt.Errorf("解析歌曲URL失败: %w", err)
-	}
 	if err := json.Unmarshal([]byte(parsed[api.SongLyricAPI]), &lyrics); err != nil {
 		return nil, nil, nil, fmt.Errorf("解析歌曲歌词失败: %w", err)
 	}
+	if len(detail.Songs) == 0 {
+		return nil, nil, nil, errors.New("歌曲不存在或已下架")
+	}
+
+	// 按配置音质获取下载地址, 无权限时逐级降低
+	urlMap, err := ncm.fetchSongURLs([]int{musicID}, cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	u, ok := urlMap[musicID]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("歌曲无法下载: %s", ncm.unavailable[strconv.Itoa(musicID)])
+	}
 
 	utils.DebugWithFormat("[NCM] 歌曲信息获取成功: %s", detail.Songs[0].Name)
-	return &detail, &urls, &lyrics, nil
+	return &detail, &types.SongsURLData{Data: []types.SongURLData{u}}, &lyrics, nil
 }
 
 // FetchPlaylistData 获取播放列表信息
@@ -324,40 +540,47 @@ func (ncm *NetEaseProcessor) FetchSongLyric(musicID int, cfg *config.Config) str
 		return ""
 	}
 
-	utils.DebugWithFormat("[NCM] 歌词信息获取成功: %s", musicID)
+	utils.DebugWithFormat("[NCM] 歌词信息获取成功: %d", musicID)
 	return utils.ParseNCMLyric(&lyrics)
 }
 
 // downloadFile 下载文件和封面
-func (ncm *NetEaseProcessor) downloadFile(url string, fileName string, coverUrl string, coverFileName string, saveDir string) error {
+func (ncm *NetEaseProcessor) downloadFile(ctx context.Context, url string, fileName string, coverUrl string, coverFileName string, saveDir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if coverUrl != "" {
 		utils.DebugWithFormat("[NCM] 开始下载文件: %s 和封面: %s", fileName, coverFileName)
 	} else {
 		utils.DebugWithFormat("[NCM] 开始下载文件: %s", fileName)
 	}
 
-	d := downloader.NewDownloader().
-		SetSavePath(saveDir).
-		SetBreakPoint(true).
-		SetTimeOut(300 * time.Second)
-
 	var wg sync.WaitGroup
 	errCh := make(chan error, 2)
 
-	download := func(url, fileName string) {
+	download := func(link, fileName string) {
 		defer wg.Done()
-		task, err := d.NewDownloadTask(url)
+		// 修正主机名并强制 https
+		if u, err := neturl.Parse(link); err == nil {
+			u.Host = ncm.fixHost(u.Host)
+			u.Scheme = "https"
+			link = u.String()
+		}
+		dm, err := utils.NewDownloader(link, &utils.DownloadOptions{
+			SavePath:      saveDir,
+			FileName:      fileName,
+			Timeout:       300 * time.Second,
+			MaxRetries:    3,
+			RetryInterval: 2 * time.Second,
+			ChunkSize:     4 * 1024 * 1024,
+		})
 		if err != nil {
 			errCh <- err
 			return
 		}
-
-		task.CleanTempFiles()
-		task.ReplaceHostName(ncm.fixHost(task.GetHostName())).
-			ForceHttps().
-			ForceMultiThread()
-
-		if err := task.SetFileName(fileName).Download(); err != nil {
+		// 同步下载, 任务取消时中断请求并在退出后返回
+		if err := dm.RunContext(ctx); err != nil {
 			errCh <- err
 		}
 	}
@@ -372,78 +595,105 @@ func (ncm *NetEaseProcessor) downloadFile(url string, fileName string, coverUrl
 		go download(coverUrl, coverFileName)
 	}
 
+	// 等待下载协程全部退出, 避免取消后继续向已清理的临时目录写入
 	wg.Wait()
 	close(errCh)
 
+	if err := ctx.Err(); err != nil {
+		utils.DebugWithFormat("[NCM] 下载已取消: %s", fileName)
+		return err
+	}
 	if len(errCh) > 0 {
 		return <-errCh
 	}
 	return nil
 }
 
-// FetchPlaylistSongData 批量获取歌单歌曲信息
+// FetchPlaylistSongData 批量获取歌单歌曲信息, 详情与下载地址分批请求并按歌曲ID合并
 func (ncm *NetEaseProcessor) FetchPlaylistSongData(musicIDs []int, cfg *config.Config) (map[int]*SongInfo, error) {
-	utils.DebugWithFormat("[NCM] 批量请求歌曲信息: IDs=%v", musicIDs)
-
-	// 1. 批量请求detail和url
-	batch := api.NewBatch(
-		api.BatchAPI{Key: api.SongDetailAPI, Json: api.CreateSongDetailReqJson(musicIDs)},
-		api.BatchAPI{Key: api.SongUrlAPI, Json: api.CreateSongURLJson(api.SongURLConfig{Ids: musicIDs})},
-	)
-	req := ncmutils.RequestData{}
-	if ncm.musicU != "" {
-		req.Cookies = []*http.Cookie{{Name: "MUSIC_U", Value: ncm.musicU}}
-	}
-
-	result := batch.Do(req)
-	if result.Error != nil {
-		return nil, fmt.Errorf("网易云API请求失败: %w", result.Error)
+	utils.DebugWithFormat("[NCM] 批量请求歌曲信息: %d 首", len(musicIDs))
+
+	// 1. 分批请求详情
+	details := make(map[int]types.SongDetailData, len(musicIDs))
+	for _, chunk := range chunkIDs(musicIDs, ncmDetailChunkSize) {
+		var data types.SongsDetailData
+		if err := ncm.request(api.SongDetailAPI, json.RawMessage(api.CreateSongDetailReqJson(chunk)), &data); err != nil {
+			return nil, fmt.Errorf("获取歌曲详情失败: %w", err)
+		}
+		for _, s := range data.Songs {
+			details[s.Id] = s
+		}
 	}
 
-	_, parsed := batch.Parse()
-
-	var details types.SongsDetailData
-	var urls types.SongsURLData
-
-	if err := json.Unmarshal([]byte(parsed[api.SongDetailAPI]), &details); err != nil {
-		return nil, fmt.Errorf("解析歌曲详情失败: %w", err)
-	}
-	if err := json.Unmarshal([]byte(parsed[api.SongUrlAPI]), &urls); err != nil {
-		return nil, fmt.Errorf("解析歌曲URL失败: %w", err)
+	// 2. 分批获取下载地址(按配置音质, 无权限时逐级降低), 无法下载的歌曲下载地址为空
+	urls := make(map[int]types.SongURLData, len(musicIDs))
+	for _, chunk := range chunkIDs(musicIDs, ncmURLChunkSize) {
+		chunkURLs, err := ncm.fetchSongURLs(chunk, cfg)
+		if err != nil {
+			return nil, err
+		}
+		for id, u := range chunkURLs {
+			urls[id] = u
+		}
 	}
 
-	// 2. 歌词
-	songLyricMap := make(map[int]string)
+	// 3. 歌词(并发获取)
+	songLyricMap := make(map[int]string, len(musicIDs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, cfg.NetEase.Concurrency)
 	for _, id := range musicIDs {
-		lyric := ncm.FetchSongLyric(id, cfg)
-		songLyricMap[id] = lyric
+		if _, ok := details[id]; !ok {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(id int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			lyric := ncm.FetchSongLyric(id, cfg)
+			mu.Lock()
+			songLyricMap[id] = lyric
+			mu.Unlock()
+		}(id)
 	}
+	wg.Wait()
 
-	// 3. 构建 SongInfo map
-	songMap := make(map[int]*SongInfo)
-	for i, s := range details.Songs {
-		u := urls.Data[i]
-		lyric := songLyricMap[s.Id]
+	// 4. 按歌曲ID合并详情、下载地址与歌词
+	tidy := processor.DetermineTidyType(cfg)
+	songMap := make(map[int]*SongInfo, len(details))
+	for _, id := range musicIDs {
+		s, ok := details[id]
+		if !ok {
+			continue
+		}
+		u := urls[id]
+		lyric := songLyricMap[id]
 		if lyric == "" {
-			lyric = "[00:00:00]此歌曲为没有填词的纯音乐，请您欣赏"
+			lyric = PureMusicLyric
 		}
-		year := utils.ParseNCMYear(&details)
-		tidy := processor.DetermineTidyType(cfg)
-		songMap[s.Id] = &SongInfo{
+		songMap[id] = &SongInfo{
+			SongID:      strconv.Itoa(s.Id),
 			SongName:    s.Name,
 			SongArtists: utils.ParseArtist(s),
 			SongAlbum:   s.Al.Name,
 			FileExt:     ncm.detectExt(u.Url),
 			MusicSize:   int64(u.Size),
-			Bitrate:     strconv.Itoa((8 * u.Size / (s.Dt / 1000)) / 1000),
+			Bitrate:     ncmBitrate(u, s.Dt),
 			Duration:    s.Dt / 1000,
 			Url:         u.Url,
+			Quality:     ncmLevel(u),
 			PicUrl:      s.Al.PicUrl,
 			Tidy:        tidy,
 			Lyric:       lyric,
-			Year:        year,
+			Year:        time.UnixMilli(int64(s.PublishTime)).Year(),
+			TrackNumber: s.No,
+			DiscNumber:  discNumber(s.Cd),
 		}
 	}
+	if missing := len(musicIDs) - len(songMap); missing > 0 {
+		utils.WarnWithFormat("[NCM] ⚠️ %d 首歌曲未获取到详情(可能已下架)", missing)
+	}
 
 	return songMap, nil
 }
@@ -463,26 +713,48 @@ func (ncm *NetEaseProcessor) buildSongInfo(cfg *config.Config, detail *types.Son
 
 	ncmLyric := utils.ParseNCMLyric(lyric)
 	if ncmLyric == "" {
-		ncmLyric = "[00:00:00]此歌曲为没有填词的纯音乐，请您欣赏"
+		ncmLyric = PureMusicLyric
 	}
 	year := utils.ParseNCMYear(detail)
 
 	return &SongInfo{
+		SongID:      strconv.Itoa(s.Id),
 		SongName:    s.Name,
 		SongArtists: utils.ParseArtist(s),
 		SongAlbum:   s.Al.Name,
 		FileExt:     ncm.detectExt(u.Url),
 		MusicSize:   int64(u.Size),
-		Bitrate:     strconv.Itoa((8 * u.Size / (s.Dt / 1000)) / 1000),
+		Bitrate:     ncmBitrate(u, s.Dt),
 		Duration:    s.Dt / 1000,
 		Url:         u.Url,
+		Quality:     ncmLevel(u),
 		PicUrl:      s.Al.PicUrl,
 		Tidy:        tidy,
 		Lyric:       ncmLyric,
 		Year:        year,
+		TrackNumber: s.No,
+		DiscNumber:  discNumber(s.Cd),
 	}
 }
 
+// ncmBitrate 码率(kbps), 接口未返回时按文件大小与时长估算
+func ncmBitrate(u types.SongURLData, durationMs int) string {
+	if u.Br > 0 {
+		return strconv.Itoa(u.Br / 1000)
+	}
+	if durationMs < 1000 {
+		return "0"
+	}
+	return strconv.Itoa((8 * u.Size / (durationMs / 1000)) / 1000)
+}
+
+// discNumber 解析碟片号, 如 "01"、"1/2"
+func discNumber(cd string) int {
+	cd, _, _ = strings.Cut(cd, "/")
+	n, _ := strconv.Atoi(strings.TrimSpace(cd))
+	return n
+}
+
 // detectExt 检测扩展名
 func (ncm *NetEaseProcessor) detectExt(url string) string {
 	if idx := strings.Index(url, "?"); idx != -1 {
@@ -497,13 +769,14 @@ func (ncm *NetEaseProcessor) detectExt(url string) string {
 	}
 }
 
-// safeFileName 合法的文件名
+// safeFileName 合法的文件名, 带歌曲ID以免并发下载同名歌曲时互相覆盖
 func (ncm *NetEaseProcessor) safeFileName(info *SongInfo) string {
 	replacer := strings.NewReplacer("/", " ", "?", " ", "*", " ", ":", " ",
 		"|", " ", "\\", " ", "<", " ", ">", " ", "\"", " ")
-	return replacer.Replace(fmt.Sprintf("%s - %s.%s",
+	return replacer.Replace(fmt.Sprintf("%s - %s [%s].%s",
 		strings.ReplaceAll(info.SongArtists, "/", ","),
 		info.SongName,
+		info.SongID,
 		info.FileExt))
 }
 
@@ -511,78 +784,18 @@ func (ncm *NetEaseProcessor) safeFileName(info *SongInfo) string {
 func (ncm *NetEaseProcessor) safeCoverFileName(info *SongInfo) string {
 	replacer := strings.NewReplacer("/", " ", "?", " ", "*", " ", ":", " ",
 		"|", " ", "\\", " ", "<", " ", ">", " ", "\"", " ")
-	return replacer.Replace(fmt.Sprintf("%s - %s.%s",
+	return replacer.Replace(fmt.Sprintf("%s - %s [%s].%s",
 		strings.ReplaceAll(info.SongArtists, "/", ","),
-		info.SongName+"_cover", "jpg"))
+		info.SongName+"_cover", info.SongID, "jpg"))
 }
 
 // safeTempFileName 合法临时文件路径
 func (ncm *NetEaseProcessor) safeTempFileName(info *SongInfo) string {
 	replacer := strings.NewReplacer("/", " ", "?", " ", "*", " ", ":", " ",
 		"|", " ", "\\", " ", "<", " ", ">", " ", "\"", " ")
-	return replacer.Replace(fmt.Sprintf("%s - %s.%s",
+	return replacer.Replace(fmt.Sprintf("%s - %s [%s].%s",
 		strings.ReplaceAll(info.SongArtists, "/", ","),
 		fmt.Sprintf("%s_temp", info.SongName),
+		info.SongID,
 		info.FileExt))
 }
-
-// 整理到本地
-func (ncm *NetEaseProcessor) tidyToLocal(files []os.DirEntry) error {
-	dstDir := ncm.cfg.Tidy.DistDir
-	if dstDir == "" {
-		_ = processor.RemoveTempDir(ncm.tempDir)
-		return errors.New("未配置输出目录")
-	}
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		_ = processor.RemoveTempDir(ncm.tempDir)
-		return fmt.Errorf("创建输出目录失败: %w", err)
-	}
-
-	for _, f := range files {
-		if !utils.FilterMusicFile(f, ncm.EncryptedExts(), ncm.DecryptedExts()) {
-			utils.DebugWithFormat("[AppleMusic] 跳过非音乐文件: %s", f.Name())
-			continue
-		}
-		src := filepath.Join(ncm.tempDir, f.Name())
-		dst := filepath.Join(dstDir, utils.SanitizeFileName(f.Name()))
-		err := processor.ToLocal(src, dst)
-		if err != nil {
-			return err
-		}
-		utils.InfoWithFormat("[AppleMusic] 📦 已整理: %s", dst)
-	}
-	// 清除临时目录
-	err := processor.RemoveTempDir(ncm.tempDir)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-// 整理到webdav
-func (ncm *NetEaseProcessor) tidyToWebDAV(files []os.DirEntry, webdav *core.WebDAV) error {
-	if webdav == nil {
-		_ = processor.RemoveTempDir(ncm.tempDir)
-		return errors.New("WebDAV 未初始化")
-	}
-
-	for _, f := range files {
-		if !utils.FilterMusicFile(f, ncm.EncryptedExts(), ncm.DecryptedExts()) {
-			utils.DebugWithFormat("[AppleMusic] 跳过非音乐文件: %s", f.Name())
-			continue
-		}
-
-		filePath := filepath.Join(ncm.tempDir, f.Name())
-		if err := webdav.Upload(filePath); err != nil {
-			utils.WarnWithFormat("[AppleMusic] ☁️ 上传失败 %s: %v", f.Name(), err)
-			continue
-		}
-		utils.InfoWithFormat("[AppleMusic] ☁️ 已上传: %s", f.Name())
-	}
-	// 清除临时目录
-	err := processor.RemoveTempDir(ncm.tempDir)
-	if err != nil {
-		return err
-	}
-	return nil
-}
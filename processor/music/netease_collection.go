@@ -0,0 +1,444 @@
+package music
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/XiaoMengXinX/Music163Api-Go/api"
+	ncmutils "github.com/XiaoMengXinX/Music163Api-Go/utils"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// 网易云专辑、歌手、电台下载: 先取得歌曲ID列表, 再批量获取歌曲信息与下载地址, 最后按列表顺序下载
+
+/* ---------------------- 接口与数据结构 ---------------------- */
+
+const (
+	ncmAlbumAPI         = "/api/v1/album/%d"        // 专辑详情(含歌曲)
+	ncmArtistAPI        = "/api/v1/artist/%d"       // 歌手详情(含热门50首)
+	ncmArtistAlbumsAPI  = "/api/artist/albums/%d"   // 歌手专辑列表
+	ncmRadioProgramsAPI = "/api/dj/program/byradio" // 电台节目列表
+	ncmPageSize         = 100                       // 分页大小
+	ncmDetailChunkSize  = 500                       // 单次请求的歌曲详情数
+	ncmURLChunkSize     = 200                       // 单次请求的下载地址数
+)
+
+// ncmTrackList 待下载的歌曲列表
+type ncmTrackList struct {
+	Kind  string      // 列表类型: 歌单/专辑/歌手/电台
+	Name  string      // 列表名称
+	Songs []*SongInfo // 按顺序排列的歌曲
+}
+
+type ncmAlbumData struct {
+	Album struct {
+		Id          int    `json:"id"`
+		Name        string `json:"name"`
+		PicUrl      string `json:"picUrl"`
+		PublishTime int64  `json:"publishTime"`
+		Artist      struct {
+			Name string `json:"name"`
+		} `json:"artist"`
+	} `json:"album"`
+	Songs []struct {
+		Id int `json:"id"`
+	} `json:"songs"`
+}
+
+type ncmArtistData struct {
+	Artist struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"artist"`
+	HotSongs []struct {
+		Id int `json:"id"`
+	} `json:"hotSongs"`
+}
+
+type ncmArtistAlbumsData struct {
+	HotAlbums []struct {
+		Id   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"hotAlbums"`
+	More bool `json:"more"`
+}
+
+type ncmProgram struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SerialNum   int    `json:"serialNum"`
+	CoverUrl    string `json:"coverUrl"`
+	CreateTime  int64  `json:"createTime"`
+	MainSong    struct {
+		Id int `json:"id"`
+	} `json:"mainSong"`
+	Dj struct {
+		Nickname string `json:"nickname"`
+	} `json:"dj"`
+	Radio struct {
+		Name     string `json:"name"`
+		Category string `json:"category"`
+	} `json:"radio"`
+}
+
+type ncmRadioProgramsData struct {
+	Programs []ncmProgram `json:"programs"`
+	Count    int          `json:"count"`
+	More     bool         `json:"more"`
+}
+
+type ncmProgramData struct {
+	Program ncmProgram `json:"program"`
+}
+
+/* ---------------------- 下载逻辑 ---------------------- */
+
+// downloadAlbum 专辑下载
+func (ncm *NetEaseProcessor) downloadAlbum(ctx context.Context, albumID int, start time.Time, report utils.ProgressFunc) error {
+	utils.DebugWithFormat("[NCM] 获取专辑数据: ID=%d", albumID)
+	album, songs, err := ncm.fetchAlbumSongs(albumID)
+	if err != nil {
+		utils.ErrorWithFormat("[NCM] ❌ 获取专辑数据失败: %v", err)
+		return err
+	}
+	list := &ncmTrackList{Kind: "专辑", Name: album.Album.Name, Songs: songs}
+	return ncm.downloadTracks(ctx, list, start, report)
+}
+
+// downloadArtist 歌手下载: 默认热门50首, artist_scope 为 all 时下载全部专辑
+func (ncm *NetEaseProcessor) downloadArtist(ctx context.Context, artistID int, start time.Time, report utils.ProgressFunc) error {
+	utils.DebugWithFormat("[NCM] 获取歌手数据: ID=%d", artistID)
+	var artist ncmArtistData
+	if err := ncm.request(fmt.Sprintf(ncmArtistAPI, artistID), struct{}{}, &artist); err != nil {
+		utils.ErrorWithFormat("[NCM] ❌ 获取歌手数据失败: %v", err)
+		return err
+	}
+
+	if ncm.cfg.NetEase.ArtistScope == "all" {
+		songs, err := ncm.fetchArtistAlbumSongs(artistID)
+		if err != nil {
+			utils.ErrorWithFormat("[NCM] ❌ 获取歌手专辑失败: %v", err)
+			return err
+		}
+		list := &ncmTrackList{Kind: "歌手全部专辑", Name: artist.Artist.Name, Songs: songs}
+		return ncm.downloadTracks(ctx, list, start, report)
+	}
+
+	ids := make([]int, 0, len(artist.HotSongs))
+	for _, s := range artist.HotSongs {
+		ids = append(ids, s.Id)
+	}
+	if len(ids) == 0 {
+		return errors.New("未获取到歌手热门歌曲")
+	}
+	songMap, err := ncm.FetchPlaylistSongData(ids, ncm.cfg)
+	if err != nil {
+		return err
+	}
+	list := &ncmTrackList{Kind: "歌手热门歌曲", Name: artist.Artist.Name, Songs: ncm.orderSongs(ids, songMap)}
+	return ncm.downloadTracks(ctx, list, start, report)
+}
+
+// downloadRadio 电台下载(按节目从新到旧)
+func (ncm *NetEaseProcessor) downloadRadio(ctx context.Context, radioID int, start time.Time, report utils.ProgressFunc) error {
+	utils.DebugWithFormat("[NCM] 获取电台节目: ID=%d", radioID)
+	maxItems := ncm.cfg.NetEase.MaxItems
+	programs := make([]ncmProgram, 0)
+	for offset := 0; ; offset += ncmPageSize {
+		var page ncmRadioProgramsData
+		body := map[string]any{"radioId": radioID, "limit": ncmPageSize, "offset": offset, "asc": false}
+		if err := ncm.request(ncmRadioProgramsAPI, body, &page); err != nil {
+			utils.ErrorWithFormat("[NCM] ❌ 获取电台节目失败: %v", err)
+			return err
+		}
+		programs = append(programs, page.Programs...)
+		if maxItems > 0 && len(programs) >= maxItems {
+			programs = programs[:maxItems]
+			break
+		}
+		if !page.More || len(page.Programs) == 0 {
+			break
+		}
+	}
+	if len(programs) == 0 {
+		return errors.New("电台暂无节目")
+	}
+
+	songs, err := ncm.fetchProgramSongs(programs)
+	if err != nil {
+		return err
+	}
+	list := &ncmTrackList{Kind: "电台", Name: programs[0].Radio.Name, Songs: songs}
+	return ncm.downloadTracks(ctx, list, start, report)
+}
+
+// downloadProgram 电台节目下载
+func (ncm *NetEaseProcessor) downloadProgram(ctx context.Context, programID int, start time.Time, report utils.ProgressFunc) error {
+	utils.DebugWithFormat("[NCM] 获取电台节目: ID=%d", programID)
+	var detail ncmProgramData
+	body := map[string]string{"id": strconv.Itoa(programID)}
+	if err := ncm.request(api.ProgramDetailAPI, body, &detail); err != nil {
+		utils.ErrorWithFormat("[NCM] ❌ 获取电台节目失败: %v", err)
+		return err
+	}
+	if detail.Program.MainSong.Id == 0 {
+		return errors.New("未获取到电台节目音频")
+	}
+
+	songs, err := ncm.fetchProgramSongs([]ncmProgram{detail.Program})
+	if err != nil {
+		return err
+	}
+	list := &ncmTrackList{Kind: "电台节目", Name: detail.Program.Name, Songs: songs}
+	return ncm.downloadTracks(ctx, list, start, report)
+}
+
+/* ---------------------- 数据获取 ---------------------- */
+
+// fetchAlbumSongs 获取专辑及其歌曲, 专辑艺术家与封面以专辑信息为准
+func (ncm *NetEaseProcessor) fetchAlbumSongs(albumID int) (*ncmAlbumData, []*SongInfo, error) {
+	var album ncmAlbumData
+	if err := ncm.request(fmt.Sprintf(ncmAlbumAPI, albumID), struct{}{}, &album); err != nil {
+		return nil, nil, err
+	}
+	ids := make([]int, 0, len(album.Songs))
+	for _, s := range album.Songs {
+		ids = append(ids, s.Id)
+	}
+	if len(ids) == 0 {
+		return &album, nil, nil
+	}
+	songMap, err := ncm.FetchPlaylistSongData(ids, ncm.cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	songs := ncm.orderSongs(ids, songMap)
+	for _, song := range songs {
+		song.SongAlbum = album.Album.Name
+		song.SongAlbumArtist = album.Album.Artist.Name
+		if album.Album.PicUrl != "" {
+			song.PicUrl = album.Album.PicUrl
+		}
+		if album.Album.PublishTime > 0 {
+			song.Year = time.UnixMilli(album.Album.PublishTime).Year()
+		}
+	}
+	utils.DebugWithFormat("[NCM] 专辑信息获取成功: %s (%d首)", album.Album.Name, len(songs))
+	return &album, songs, nil
+}
+
+// fetchArtistAlbumSongs 获取歌手全部专辑的歌曲, 多张专辑收录的同一首歌只保留一次
+func (ncm *NetEaseProcessor) fetchArtistAlbumSongs(artistID int) ([]*SongInfo, error) {
+	albumIDs := make([]int, 0)
+	for offset := 0; ; offset += ncmPageSize {
+		var page ncmArtistAlbumsData
+		body := map[string]any{"limit": ncmPageSize, "offset": offset, "total": true}
+		if err := ncm.request(fmt.Sprintf(ncmArtistAlbumsAPI, artistID), body, &page); err != nil {
+			return nil, err
+		}
+		for _, a := range page.HotAlbums {
+			albumIDs = append(albumIDs, a.Id)
+		}
+		if !page.More || len(page.HotAlbums) == 0 {
+			break
+		}
+	}
+	utils.InfoWithFormat("[NCM] 歌手共 %d 张专辑", len(albumIDs))
+
+	maxItems := ncm.cfg.NetEase.MaxItems
+	seen := make(map[string]bool)
+	songs := make([]*SongInfo, 0)
+	for _, id := range albumIDs {
+		_, albumSongs, err := ncm.fetchAlbumSongs(id)
+		if err != nil {
+			utils.WarnWithFormat("[NCM] ⚠️ 获取专辑失败，跳过: ID=%d, %v", id, err)
+			continue
+		}
+		for _, song := range albumSongs {
+			if seen[song.SongID] {
+				continue
+			}
+			seen[song.SongID] = true
+			songs = append(songs, song)
+		}
+		if maxItems > 0 && len(songs) >= maxItems {
+			return songs[:maxItems], nil
+		}
+	}
+	return songs, nil
+}
+
+// fetchProgramSongs 获取电台节目音频, 标签以节目信息为准(主播/电台/期数)
+func (ncm *NetEaseProcessor) fetchProgramSongs(programs []ncmProgram) ([]*SongInfo, error) {
+	ids := make([]int, 0, len(programs))
+	for _, p := range programs {
+		ids = append(ids, p.MainSong.Id)
+	}
+	songMap, err := ncm.FetchPlaylistSongData(ids, ncm.cfg)
+	if err != nil {
+		return nil, err
+	}
+	songs := make([]*SongInfo, 0, len(programs))
+	for _, p := range programs {
+		song, ok := songMap[p.MainSong.Id]
+		if !ok {
+			ncm.unavailable[strconv.Itoa(p.MainSong.Id)] = "未获取到节目音频"
+			utils.WarnWithFormat("[NCM] ⚠️ 节目音频缺失，跳过: %s", p.Name)
+			continue
+		}
+		song.SongName = p.Name
+		if p.Dj.Nickname != "" {
+			song.SongArtists = p.Dj.Nickname
+			song.SongAlbumArtist = p.Dj.Nickname
+		}
+		song.SongAlbum = p.Radio.Name
+		song.TrackNumber = p.SerialNum
+		song.DiscNumber = 0
+		song.Year = time.UnixMilli(p.CreateTime).Year()
+		song.Genre = p.Radio.Category
+		song.Comment = strings.TrimSpace(p.Description)
+		song.Lyric = ""
+		if p.CoverUrl != "" {
+			song.PicUrl = p.CoverUrl
+		}
+		songs = append(songs, song)
+	}
+	return songs, nil
+}
+
+/* ---------------------- 断点续传 ---------------------- */
+
+// ncmProgressFile 列表下载进度文件, 位于列表的临时目录中
+const ncmProgressFile = ".progress.json"
+
+// ncmProgress 列表下载进度: 已完成的歌曲ID -> 文件名
+type ncmProgress struct {
+	mu    sync.Mutex
+	dir   string
+	files map[string]string
+}
+
+// loadNCMProgress 读取临时目录中的下载进度, 不存在时返回空进度
+func loadNCMProgress(dir string) *ncmProgress {
+	p := &ncmProgress{dir: dir, files: make(map[string]string)}
+	data, err := os.ReadFile(filepath.Join(dir, ncmProgressFile))
+	if err != nil {
+		return p
+	}
+	if err := json.Unmarshal(data, &p.files); err != nil {
+		utils.WarnWithFormat("[NCM] ⚠️ 下载进度文件损坏，重新下载: %v", err)
+		p.files = make(map[string]string)
+		return p
+	}
+	if len(p.files) > 0 {
+		utils.InfoWithFormat("[NCM] ⏩ 继续上次未完成的下载，已完成 %d 首", len(p.files))
+	}
+	return p
+}
+
+// Done 歌曲是否已下载完成(有记录且文件仍存在)
+func (p *ncmProgress) Done(songID, fileName string) bool {
+	p.mu.Lock()
+	name, ok := p.files[songID]
+	p.mu.Unlock()
+	if !ok || name != fileName {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(p.dir, fileName))
+	return err == nil
+}
+
+// Add 记录已完成的歌曲并写入进度文件
+func (p *ncmProgress) Add(songID, fileName string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.files[songID] = fileName
+	data, err := json.Marshal(p.files)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(p.dir, ncmProgressFile), data, 0644)
+	}
+	if err != nil {
+		utils.WarnWithFormat("[NCM] ⚠️ 保存下载进度失败: %v", err)
+	}
+}
+
+// Len 已完成的歌曲数
+func (p *ncmProgress) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.files)
+}
+
+/* ---------------------- 工具方法 ---------------------- */
+
+// orderSongs 按ID顺序排列歌曲信息, 缺失的歌曲跳过并记录在 ncm.unavailable
+func (ncm *NetEaseProcessor) orderSongs(ids []int, songMap map[int]*SongInfo) []*SongInfo {
+	songs := make([]*SongInfo, 0, len(ids))
+	for _, id := range ids {
+		songInfo, ok := songMap[id]
+		if !ok {
+			ncm.unavailable[strconv.Itoa(id)] = "未获取到歌曲信息"
+			utils.WarnWithFormat("[NCM] ⚠️ 歌曲信息缺失，跳过: ID=%d", id)
+			continue
+		}
+		songs = append(songs, songInfo)
+	}
+	return songs
+}
+
+// chunkIDs 按大小切分歌曲ID, 避免单次请求过大
+func chunkIDs(ids []int, size int) [][]int {
+	chunks := make([][]int, 0, (len(ids)+size-1)/size)
+	for start := 0; start < len(ids); start += size {
+		end := min(start+size, len(ids))
+		chunks = append(chunks, ids[start:end])
+	}
+	return chunks
+}
+
+// request 通过批处理接口调用网易云API, 响应解析到 out
+func (ncm *NetEaseProcessor) request(key string, body any, out any) error {
+	reqJson, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	batch := api.NewBatch(api.BatchAPI{Key: key, Json: string(reqJson)})
+	req := ncmutils.RequestData{}
+	if ncm.musicU != "" {
+		req.Cookies = []*http.Cookie{{Name: "MUSIC_U", Value: ncm.musicU}}
+	}
+	result := batch.Do(req)
+	if result.Error != nil {
+		return fmt.Errorf("网易云API请求失败: %w", result.Error)
+	}
+
+	_, parsed := batch.Parse()
+	raw, ok := parsed[key]
+	if !ok {
+		return fmt.Errorf("网易云API无返回数据: %s", key)
+	}
+	var status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(raw), &status); err != nil {
+		return fmt.Errorf("解析网易云API响应失败: %w", err)
+	}
+	if status.Code != http.StatusOK {
+		return fmt.Errorf("网易云API返回错误: code=%d %s", status.Code, status.Message)
+	}
+	if err := json.Unmarshal([]byte(raw), out); err != nil {
+		return fmt.Errorf("解析网易云API响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package music
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/XiaoMengXinX/Music163Api-Go/api"
+	"github.com/XiaoMengXinX/Music163Api-Go/types"
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// 网易云音质选择: 按配置的音质请求下载地址, 账号无权限(无地址/仅试听)时逐级降低音质
+
+// ncmQualityFallback 从配置的音质开始逐级降低的等级列表, 未知音质返回 nil(配置校验时已拒绝)
+func ncmQualityFallback(preferred string) []string {
+	i := slices.Index(config.NetEaseQualities, preferred)
+	if i < 0 {
+		return nil
+	}
+	return config.NetEaseQualities[i:]
+}
+
+// fetchSongURLs 获取歌曲下载地址, 以歌曲ID为键; 最终仍无法下载的歌曲不在结果中, 原因记录在 ncm.unavailable
+func (ncm *NetEaseProcessor) fetchSongURLs(ids []int, cfg *config.Config) (map[int]types.SongURLData, error) {
+	result := make(map[int]types.SongURLData, len(ids))
+	last := make(map[int]types.SongURLData)
+	pending := ids
+	levels := ncmQualityFallback(cfg.NetEase.Quality)
+	if levels == nil {
+		return nil, fmt.Errorf("未知网易云音质: %q", cfg.NetEase.Quality)
+	}
+	for i, level := range levels {
+		if len(pending) == 0 {
+			break
+		}
+		if i > 0 {
+			utils.InfoWithFormat("[NCM] 🎚️ %d 首歌曲无法获取 %s 音质，尝试 %s", len(pending), levels[i-1], level)
+		}
+		var urls types.SongsURLData
+		body := json.RawMessage(api.CreateSongURLJson(api.SongURLConfig{Ids: pending, Level: level}))
+		if err := ncm.request(api.SongUrlAPI, body, &urls); err != nil {
+			return nil, fmt.Errorf("获取歌曲下载地址失败: %w", err)
+		}
+		byID := make(map[int]types.SongURLData, len(urls.Data))
+		for _, u := range urls.Data {
+			byID[u.Id] = u
+		}
+
+		next := make([]int, 0)
+		for _, id := range pending {
+			u, ok := byID[id]
+			if ok && u.Url != "" && u.FreeTrialInfo == nil {
+				result[id] = u
+				continue
+			}
+			if ok {
+				last[id] = u
+			}
+			next = append(next, id)
+		}
+		pending = next
+	}
+
+	for _, id := range pending {
+		reason := "未获取到下载地址"
+		if u, ok := last[id]; ok {
+			reason = ncmUnavailableReason(u)
+		}
+		ncm.unavailable[strconv.Itoa(id)] = reason
+		utils.WarnWithFormat("[NCM] ⚠️ 歌曲无法下载: ID=%d, %s", id, reason)
+	}
+	return result, nil
+}
+
+// ncmUnavailableReason 无法下载的原因
+func ncmUnavailableReason(u types.SongURLData) string {
+	switch {
+	case u.Code == 404 || u.Code == -110:
+		return "歌曲已下架或暂无版权"
+	case u.FreeTrialInfo != nil:
+		return "仅可试听片段，需要VIP会员或购买"
+	case u.Fee == 1:
+		return "需要VIP会员"
+	case u.Fee == 4:
+		return "需要购买专辑"
+	default:
+		return fmt.Sprintf("暂无下载地址(code=%d)", u.Code)
+	}
+}
+
+// ncmLevel 实际获取到的音质等级
+func ncmLevel(u types.SongURLData) string {
+	if level, ok := u.Level.(string); ok {
+		return level
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package music
+
+import (
+	"slices"
+	"testing"
+)
+
+// TestNCMSafeFileName 同一歌手的同名歌曲使用不同的临时文件
+func TestNCMSafeFileName(t *testing.T) {
+	ncm := &NetEaseProcessor{}
+	a := &SongInfo{SongID: "1", SongArtists: "A/B", SongName: "歌: 曲", FileExt: "flac"}
+	b := &SongInfo{SongID: "2", SongArtists: "A/B", SongName: "歌: 曲", FileExt: "flac"}
+	if got := ncm.safeFileName(a); got != "A,B - 歌  曲 [1].flac" {
+		t.Errorf("safeFileName() = %q", got)
+	}
+	if ncm.safeFileName(a) == ncm.safeFileName(b) || ncm.safeCoverFileName(a) == ncm.safeCoverFileName(b) {
+		t.Error("同名歌曲的临时文件名相同")
+	}
+}
+
+// TestNCMOrderSongs 缺失信息的歌曲跳过, 并在失败列表中汇报
+func TestNCMOrderSongs(t *testing.T) {
+	ncm := &NetEaseProcessor{unavailable: map[string]string{"4": "需要VIP"}}
+	songMap := map[int]*SongInfo{
+		1: {SongID: "1"},
+		3: {SongID: "3"},
+		4: {SongID: "4"},
+	}
+	songs := ncm.orderSongs([]int{3, 2, 1, 4, 5}, songMap)
+	ids := make([]string, 0, len(songs))
+	for _, s := range songs {
+		ids = append(ids, s.SongID)
+	}
+	if !slices.Equal(ids, []string{"3", "1", "4"}) {
+		t.Errorf("orderSongs() = %v, 期望 [3 1 4]", ids)
+	}
+
+	// 列表中无法下载的歌曲(ID=4)在下载时汇报, 此处只汇报不在列表中的歌曲
+	want := []string{"ID=2: 未获取到歌曲信息", "ID=5: 未获取到歌曲信息"}
+	if got := ncm.unlistedFailures(songs); !slices.Equal(got, want) {
+		t.Errorf("unlistedFailures() = %v, 期望 %v", got, want)
+	}
+}
+
+// TestNCMQualityFallback 从配置的音质逐级降低, 未知音质不回退到默认音质
+func TestNCMQualityFallback(t *testing.T) {
+	if got := ncmQualityFallback("exhigh"); !slices.Equal(got, []string{"exhigh", "standard"}) {
+		t.Errorf("ncmQualityFallback(exhigh) = %v", got)
+	}
+	if got := ncmQualityFallback("flac"); got != nil {
+		t.Errorf("ncmQualityFallback(flac) = %v, 期望 nil", got)
+	}
+}
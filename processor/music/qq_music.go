@@ -1,25 +1,162 @@
 package music
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"math/rand"
+	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
 )
 
+const (
+	qqStreamHost  = "https://isure.stream.qqmusic.qq.com/"
+	qqCoverFormat = "https://y.gtimg.cn/music/photo_new/T002R800x800M000%s.jpg"
+	// qqPageSize 专辑/歌单分页查询时每页的歌曲数
+	qqPageSize = 500
+)
+
+// qqMusicuAPI 统一接口地址
+var qqMusicuAPI = "https://u.y.qq.com/cgi-bin/musicu.fcg"
+
+var (
+	// qqSongRe 单曲: songDetail/<mid>、song/<mid>.html、songmid=<mid>、song?id=<id>
+	qqSongRe = regexp.MustCompile(`(?:songDetail/|/song/|songmid=|song\?id=|songid=)([0-9A-Za-z]+)`)
+	// qqAlbumRe 专辑: albumDetail/<mid>、album/<mid>.html、albummid=<mid>、album?id=<id>
+	qqAlbumRe = regexp.MustCompile(`(?:albumDetail/|/album/|album\.html\?(?:.*&)?id=|albummid=|album\?id=|albumId=)([0-9A-Za-z]+)`)
+	// qqPlaylistRe 歌单: playlist/<id>、taoge.html?id=<id>、disstid=<id>
+	qqPlaylistRe = regexp.MustCompile(`(?:/playlist/|playlist\?id=|taoge\.html\?(?:.*&)?id=|disstid=)(\d+)`)
+)
+
+// qqQuality 音质档位,按优先级从高到低排列
+type qqQuality struct {
+	Name   string               // 音质名称
+	Prefix string               // 文件名前缀
+	Ext    string               // 文件后缀
+	Size   func(*qqTrack) int64 // 对应音质的文件大小,为0表示无此音质
+}
+
+var qqQualities = []qqQuality{
+	{"Hi-Res", "RS01", ".flac", func(t *qqTrack) int64 { return t.File.SizeHiRes }},
+	{"FLAC", "F000", ".flac", func(t *qqTrack) int64 { return t.File.SizeFlac }},
+	// 加密格式,下载后通过 um 解密
+	{"FLAC(加密)", "F0M0", ".mflac", func(t *qqTrack) int64 { return t.File.SizeFlac }},
+	{"MP3 320k", "M800", ".mp3", func(t *qqTrack) int64 { return t.File.Size320 }},
+	{"OGG 192k", "O600", ".ogg", func(t *qqTrack) int64 { return t.File.Size192Ogg }},
+	{"OGG 192k(加密)", "O6M0", ".mgg", func(t *qqTrack) int64 { return t.File.Size192Ogg }},
+	{"MP3 128k", "M500", ".mp3", func(t *qqTrack) int64 { return t.File.Size128 }},
+	{"AAC 96k", "C400", ".m4a", func(t *qqTrack) int64 { return t.File.Size96Aac }},
+}
+
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkQQMusic,
+		[]string{
+			"y.qq.com",
+			"c.y.qq.com",
+			"m.y.qq.com",
+			"i.y.qq.com",
+		},
+		[]*regexp.Regexp{
+			// 支持 song / album / playlist + id 参数 或 URL 路径形式
+			regexp.MustCompile(`^https?://(?:y\.qq\.com|c\.y\.qq\.com|m\.y\.qq\.com)/(?:song|album|playlist)(?:/[A-Za-z0-9_\-]+)?(?:\?id=\d+|/[\dA-Za-z]+)(?:&.*)?$`),
+			// 新版网页: /n/ryqq/songDetail|albumDetail|playlist/<id>, 旧版网页: /n/yqq/song|album|playlist/<id>.html
+			regexp.MustCompile(`^https?://y\.qq\.com/n/(?:ryqq/(?:songDetail|albumDetail|playlist)|yqq/(?:song|album|playlist))/[0-9A-Za-z]+(?:\.html)?(?:\?.*)?$`),
+			// 手机端分享: playsong.html?songmid= / details/taoge.html?id= / details/album.html?albummid=
+			regexp.MustCompile(`^https?://i\.y\.qq\.com/(?:v8/playsong\.html|n2/m/share/details/(?:taoge|album)\.html)\?.*(?:songmid|id|albummid|albumId)=[0-9A-Za-z]+.*$`),
+		},
+		func() processor.Processor { return &QQMusicProcessor{} },
+	).WithIdentifier(identifyQQMusic)
+}
+
+// identifyQQMusic 识别 QQ 音乐链接
+func identifyQQMusic(link string) *processor.Resource {
+	switch {
+	case qqSongRe.MatchString(link):
+		// 旧版链接为数字ID, 下载时换取 mid 后再查询曲库
+		id := qqSongRe.FindStringSubmatch(link)[1]
+		return processor.NewResource(processor.ResourceTrack, id, "https://y.qq.com/n/ryqq/songDetail/%s", id)
+	case qqAlbumRe.MatchString(link):
+		id := qqAlbumRe.FindStringSubmatch(link)[1]
+		return processor.NewResource(processor.ResourceAlbum, id, "https://y.qq.com/n/ryqq/albumDetail/%s", id)
+	case qqPlaylistRe.MatchString(link):
+		id := qqPlaylistRe.FindStringSubmatch(link)[1]
+		return processor.NewResource(processor.ResourcePlaylist, id, "https://y.qq.com/n/ryqq/playlist/%s", id)
+	}
+	return nil
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type QQMusicProcessor struct {
-	cfg     *config.Config
-	tempDir string
-	songs   []*SongInfo
+	cfg      *config.Config
+	tempDir  string
+	songs    []*SongInfo
+	failures []string // 列表下载中失败的歌曲及原因
+	uin      string   // QQ号
+	authst   string   // 登录凭证(qqmusic_key / qm_keyst)
+	client   *http.Client
+}
+
+// qqTrack 歌曲信息(track_info)
+type qqTrack struct {
+	ID       int    `json:"id"`
+	Mid      string `json:"mid"`
+	Name     string `json:"name"`
+	Interval int    `json:"interval"`
+	Singer   []struct {
+		Name string `json:"name"`
+	} `json:"singer"`
+	Album struct {
+		Mid        string `json:"mid"`
+		Name       string `json:"name"`
+		TimePublic string `json:"time_public"`
+	} `json:"album"`
+	File struct {
+		MediaMid   string `json:"media_mid"`
+		Size128    int64  `json:"size_128mp3"`
+		Size320    int64  `json:"size_320mp3"`
+		SizeFlac   int64  `json:"size_flac"`
+		SizeHiRes  int64  `json:"size_hires"`
+		Size192Ogg int64  `json:"size_192ogg"`
+		Size96Aac  int64  `json:"size_96aac"`
+	} `json:"file"`
 }
 
 // Init  初始化
 func (p *QQMusicProcessor) Init(cfg *config.Config) {
 	p.cfg = cfg
 	p.songs = make([]*SongInfo, 0)
+	p.failures = nil
 	p.tempDir = processor.BuildOutputDir(QQTempDir)
+	p.client = &http.Client{Timeout: 30 * time.Second}
+
+	cookiePath := filepath.Join(cfg.CookieCloud.CookieFilePath, cfg.CookieCloud.CookieFile)
+	// uin 形如 o0123456789
+	p.uin = strings.TrimLeft(utils.GetCookieValue(cookiePath, ".qq.com", "uin"), "o0")
+	if p.uin == "" {
+		p.uin = "0"
+	}
+	p.authst = utils.GetCookieValue(cookiePath, ".qq.com", "qqmusic_key")
+	if p.authst == "" {
+		p.authst = utils.GetCookieValue(cookiePath, ".qq.com", "qm_keyst")
+	}
 }
 
 /* ---------------------- 基础接口实现 ---------------------- */
@@ -32,46 +169,474 @@ func (p *QQMusicProcessor) Songs() []*SongInfo {
 	return p.songs
 }
 
+func (p *QQMusicProcessor) Failures() []string {
+	return p.failures
+}
+
 /* ------------------------ 下载逻辑 ------------------------ */
 
-func (p *QQMusicProcessor) DownloadMusic(url string, callback func(string)) error {
-	// TODO implement me
-	panic("implement me")
+func (p *QQMusicProcessor) DownloadMusic(ctx context.Context, url string, report utils.ProgressFunc) error {
+	start := time.Now()
+	utils.InfoWithFormat("[QQ] 🎵 开始下载: %s", url)
+	if p.authst == "" {
+		utils.WarnWithFormat("[QQ] ⚠️ 未找到 QQ 音乐登录 cookie，仅能下载试听音质")
+	}
+
+	title, tracks, err := p.resolveTracks(ctx, url)
+	if err != nil {
+		utils.ErrorWithFormat("[QQ] ❌ 获取歌曲信息失败: %v", err)
+		return err
+	}
+	if len(tracks) == 0 {
+		return errors.New("未获取到有效歌曲信息")
+	}
+	if len(tracks) > 1 {
+		utils.InfoWithFormat("[QQ] 开始下载: %s (%d首)", title, len(tracks))
+		report.Emit(utils.Progress{Phase: utils.PhaseDownload, Total: len(tracks), Item: title, Message: "开始下载"})
+	}
+
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[QQ] ❌ 创建临时目录失败: %v", err)
+		return err
+	}
+
+	skipped := 0
+	for index, track := range tracks {
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), track.Mid); ok {
+			core.LogLibraryHit("QQ", e)
+			report.Emit(utils.Progress{Phase: utils.PhaseDownload, Current: index + 1, Total: len(tracks), Item: track.Name, Message: "已在曲库中，跳过"})
+			skipped++
+			continue
+		}
+		song, err := p.downloadTrack(ctx, track, func(e utils.Progress) {
+			e.Current, e.Total, e.Item, e.Message = index+1, len(tracks), track.Name, "正在下载"
+			report.Emit(e)
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				_ = processor.RemoveTempDir(p.tempDir)
+				utils.InfoWithFormat("[QQ] 🚫 下载已取消: %s", url)
+				return ctx.Err()
+			}
+			// 歌单中个别歌曲无版权时跳过,单曲直接失败
+			if len(tracks) == 1 {
+				_ = processor.RemoveTempDir(p.tempDir)
+				utils.ErrorWithFormat("[QQ] ❌ 下载失败: %v", err)
+				return fmt.Errorf("下载失败: %w", err)
+			}
+			utils.WarnWithFormat("[QQ] ⚠️ 第%d首下载失败，跳过: %s: %v", index+1, track.Name, err)
+			p.failures = append(p.failures, fmt.Sprintf("%s - %s: %v", qqArtists(track), track.Name, err))
+			continue
+		}
+		p.songs = append(p.songs, song)
+		utils.InfoWithFormat("[QQ] ✅ 下载完成: %s [%s]", filepath.Base(song.MusicPath), song.Bitrate)
+	}
+
+	if len(p.songs) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		if skipped > 0 {
+			utils.InfoWithFormat("[QQ] ⏭️ 歌曲均已在曲库中: %s", title)
+			return core.ErrInLibrary
+		}
+		return errors.New("未下载到任何歌曲")
+	}
+
+	utils.InfoWithFormat("[QQ] ✅ 下载完成: %s （耗时 %v）", title, time.Since(start).Truncate(time.Millisecond))
+	report.Message(utils.PhaseDone, "下载完成: %s （耗时 %v）", title, time.Since(start).Truncate(time.Millisecond))
+	return nil
 }
 
-func (p *QQMusicProcessor) DownloadCommand(url string) *exec.Cmd {
-	// TODO implement me
-	panic("implement me")
+func (p *QQMusicProcessor) DownloadCommand(ctx context.Context, url string) *exec.Cmd {
+	return nil
 }
 
 func (p *QQMusicProcessor) BeforeTidy() error {
-	// TODO implement me
-	panic("implement me")
+	for _, song := range p.songs {
+		ApplyLyrics(p.cfg, song, song.MusicPath)
+		if err := WriteTagsWithCoverURL(song, song.MusicPath); err != nil {
+			utils.WarnWithFormat("[QQ] ⚠️ 写入标签失败: %v", err)
+		}
+	}
+	// 跳过曲库中已存在的歌曲
+	p.songs = SkipLibraryDuplicates(p.Name(), p.songs)
+	return nil
 }
 
 func (p *QQMusicProcessor) NeedRemoveDRM() bool {
-	// TODO implement me
-	panic("implement me")
+	for _, song := range p.songs {
+		if utils.Contains(p.EncryptedExts(), filepath.Ext(song.MusicPath)) {
+			return true
+		}
+	}
+	return false
 }
 
+// DRMRemove 调用 um 解密加密格式,解密后更新歌曲路径与格式
 func (p *QQMusicProcessor) DRMRemove() error {
-	// TODO implement me
-	panic("implement me")
+	for _, song := range p.songs {
+		src := song.MusicPath
+		if !utils.Contains(p.EncryptedExts(), filepath.Ext(src)) {
+			continue
+		}
+		utils.InfoWithFormat("[QQ] 🔓 开始解密: %s", filepath.Base(src))
+		output, err := processor.BuildUmCmd(src, p.tempDir).CombinedOutput()
+		if err != nil {
+			utils.ErrorWithFormat("[QQ] ❌ 解密失败: %v\n输出:\n%s", err, output)
+			_ = processor.RemoveTempDir(p.tempDir)
+			return fmt.Errorf("um 解密失败: %w", err)
+		}
+		dst := p.decryptedPath(src)
+		if dst == "" {
+			_ = processor.RemoveTempDir(p.tempDir)
+			return fmt.Errorf("未找到解密后的文件: %s", filepath.Base(src))
+		}
+		_ = os.Remove(src)
+		song.MusicPath = dst
+		song.FileExt = strings.TrimPrefix(filepath.Ext(dst), ".")
+	}
+	return nil
 }
 
-func (p *QQMusicProcessor) TidyMusic() error {
-	// TODO implement me
-	panic("implement me")
+func (p *QQMusicProcessor) TidyMusic(ctx context.Context) error {
+	if len(p.songs) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return core.ErrInLibrary
+	}
+	return TidyMusicDir(ctx, "QQ", p, p.tempDir, p.cfg)
 }
 
 func (p *QQMusicProcessor) EncryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{".mflac", ".mgg", ".qmcflac", ".qmc0", ".qmc3"}
 }
 
 func (p *QQMusicProcessor) DecryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{".flac", ".mp3", ".ogg", ".m4a"}
 }
 
 /* ------------------------ 拓展方法 ------------------------ */
+
+// resolveTracks 解析链接对应的歌曲列表,返回标题与歌曲
+func (p *QQMusicProcessor) resolveTracks(ctx context.Context, link string) (string, []*qqTrack, error) {
+	switch {
+	case qqSongRe.MatchString(link):
+		id := qqSongRe.FindStringSubmatch(link)[1]
+		param := map[string]any{"song_mid": id}
+		if _, err := strconv.Atoi(id); err == nil {
+			param = map[string]any{"song_id": id}
+		}
+		var data struct {
+			TrackInfo *qqTrack `json:"track_info"`
+		}
+		if err := p.request(ctx, "music.pf_song_detail_svr", "get_song_detail_yqq", param, &data); err != nil {
+			return "", nil, err
+		}
+		if data.TrackInfo == nil || data.TrackInfo.Mid == "" {
+			return "", nil, errors.New("歌曲不存在")
+		}
+		return data.TrackInfo.Name, []*qqTrack{data.TrackInfo}, nil
+
+	case qqAlbumRe.MatchString(link):
+		tracks, err := p.albumTracks(ctx, qqAlbumRe.FindStringSubmatch(link)[1])
+		if err != nil {
+			return "", nil, err
+		}
+		title := "专辑"
+		if len(tracks) > 0 {
+			title = tracks[0].Album.Name
+		}
+		return title, tracks, nil
+
+	case qqPlaylistRe.MatchString(link):
+		id, _ := strconv.Atoi(qqPlaylistRe.FindStringSubmatch(link)[1])
+		return p.playlistTracks(ctx, id)
+	}
+	return "", nil, errors.New("不支持的QQ音乐链接")
+}
+
+// albumTracks 分页获取专辑的全部歌曲, id 为 albumMid 或数字 albumId
+func (p *QQMusicProcessor) albumTracks(ctx context.Context, id string) ([]*qqTrack, error) {
+	tracks := make([]*qqTrack, 0)
+	for begin := 0; ; begin += qqPageSize {
+		param := map[string]any{"albumMid": id, "begin": begin, "num": qqPageSize, "order": 2}
+		if n, err := strconv.Atoi(id); err == nil {
+			param = map[string]any{"albumId": n, "begin": begin, "num": qqPageSize, "order": 2}
+		}
+		var data struct {
+			TotalNum int `json:"totalNum"`
+			SongList []struct {
+				SongInfo *qqTrack `json:"songInfo"`
+			} `json:"songList"`
+		}
+		if err := p.request(ctx, "music.musichallAlbum.AlbumSongList", "GetAlbumSongList", param, &data); err != nil {
+			return nil, err
+		}
+		for _, s := range data.SongList {
+			if s.SongInfo != nil {
+				tracks = append(tracks, s.SongInfo)
+			}
+		}
+		if qqLastPage(begin, len(data.SongList), data.TotalNum, len(tracks), "专辑 "+id) {
+			return tracks, nil
+		}
+	}
+}
+
+// playlistTracks 分页获取歌单标题与全部歌曲
+func (p *QQMusicProcessor) playlistTracks(ctx context.Context, id int) (string, []*qqTrack, error) {
+	var (
+		title  string
+		tracks = make([]*qqTrack, 0)
+	)
+	for begin := 0; ; begin += qqPageSize {
+		param := map[string]any{
+			"disstid": id, "userinfo": 1, "tag": 1, "orderlist": 1,
+			"song_begin": begin, "song_num": qqPageSize, "onlysonglist": 0, "enc_host_uin": "",
+		}
+		var data struct {
+			DirInfo struct {
+				Title string `json:"title"`
+				// SongNum 歌曲总数
+				SongNum int `json:"songnum"`
+			} `json:"dirinfo"`
+			TotalSongNum int        `json:"total_song_num"`
+			SongList     []*qqTrack `json:"songlist"`
+		}
+		if err := p.request(ctx, "music.srfDissInfo.aiDissInfo", "uniform_get_Dissinfo", param, &data); err != nil {
+			return "", nil, err
+		}
+		if title == "" {
+			title = data.DirInfo.Title
+		}
+		tracks = append(tracks, data.SongList...)
+		total := max(data.TotalSongNum, data.DirInfo.SongNum)
+		if qqLastPage(begin, len(data.SongList), total, len(tracks), fmt.Sprintf("歌单 %d", id)) {
+			return title, tracks, nil
+		}
+	}
+}
+
+// qqLastPage 是否已获取到最后一页: 达到接口返回的总数, 或本页不足一页; 未取全时记录警告
+func qqLastPage(begin, pageLen, total, fetched int, name string) bool {
+	if pageLen > 0 && pageLen >= qqPageSize && (total == 0 || begin+pageLen < total) {
+		return false
+	}
+	if total > fetched {
+		utils.WarnWithFormat("[QQ] ⚠️ %s 共 %d 首, 仅获取到 %d 首", name, total, fetched)
+	}
+	return true
+}
+
+// downloadTrack 选择账号可用的最高音质并下载单曲, report 接收下载器的字节进度
+func (p *QQMusicProcessor) downloadTrack(ctx context.Context, track *qqTrack, report utils.ProgressFunc) (*SongInfo, error) {
+	quality, streamURL, err := p.resolveStream(ctx, track)
+	if err != nil {
+		return nil, err
+	}
+	song := p.buildSongInfo(ctx, track, quality)
+	fileName := utils.SanitizeFileName(fmt.Sprintf("%s - %s%s",
+		strings.ReplaceAll(song.SongArtists, "/", ","), song.SongName, quality.Ext))
+	utils.InfoWithFormat("[QQ] ⬇️ 开始下载: %s [%s]", fileName, quality.Name)
+
+	dm, err := utils.NewDownloader(streamURL, &utils.DownloadOptions{
+		SavePath:   p.tempDir,
+		FileName:   fileName,
+		Timeout:    300 * time.Second,
+		MaxRetries: 3,
+		ChunkSize:  4 * 1024 * 1024,
+		OnProgress: report,
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 任务取消时停止下载, 返回时下载协程已退出
+	if err := dm.RunContext(ctx); err != nil {
+		return nil, err
+	}
+	song.MusicPath = filepath.Join(p.tempDir, fileName)
+	return song, nil
+}
+
+// resolveStream 按音质从高到低获取下载地址,账号无权限的音质返回空地址
+func (p *QQMusicProcessor) resolveStream(ctx context.Context, track *qqTrack) (*qqQuality, string, error) {
+	mediaMid := track.File.MediaMid
+	if mediaMid == "" {
+		mediaMid = track.Mid
+	}
+	candidates := make([]*qqQuality, 0, len(qqQualities))
+	fileNames := make([]string, 0, len(qqQualities))
+	songMids := make([]string, 0, len(qqQualities))
+	songTypes := make([]int, 0, len(qqQualities))
+	for i := range qqQualities {
+		q := &qqQualities[i]
+		if q.Size(track) == 0 {
+			continue
+		}
+		candidates = append(candidates, q)
+		fileNames = append(fileNames, q.Prefix+mediaMid+q.Ext)
+		songMids = append(songMids, track.Mid)
+		songTypes = append(songTypes, 0)
+	}
+	if len(candidates) == 0 {
+		return nil, "", errors.New("歌曲无可下载的音质")
+	}
+
+	param := map[string]any{
+		"guid":      strconv.FormatInt(rand.Int63n(9000000000)+1000000000, 10),
+		"songmid":   songMids,
+		"songtype":  songTypes,
+		"filename":  fileNames,
+		"uin":       p.uin,
+		"loginflag": 1,
+		"platform":  "20",
+	}
+	var data struct {
+		Sip        []string `json:"sip"`
+		MidURLInfo []struct {
+			FileName string `json:"filename"`
+			Purl     string `json:"purl"`
+		} `json:"midurlinfo"`
+	}
+	if err := p.request(ctx, "vkey.GetVkeyServer", "CgiGetVkey", param, &data); err != nil {
+		return nil, "", err
+	}
+	purls := make(map[string]string, len(data.MidURLInfo))
+	for _, info := range data.MidURLInfo {
+		purls[info.FileName] = info.Purl
+	}
+	host := qqStreamHost
+	if len(data.Sip) > 0 && data.Sip[0] != "" {
+		host = data.Sip[0]
+	}
+	for i, q := range candidates {
+		if purl := purls[fileNames[i]]; purl != "" {
+			return q, host + purl, nil
+		}
+	}
+	return nil, "", errors.New("账号无权限下载该歌曲(需要会员或无版权)")
+}
+
+// qqArtists 歌手名, 多个歌手以 / 分隔
+func qqArtists(track *qqTrack) string {
+	artists := make([]string, 0, len(track.Singer))
+	for _, s := range track.Singer {
+		artists = append(artists, s.Name)
+	}
+	return strings.Join(artists, "/")
+}
+
+// buildSongInfo 构建歌曲元信息
+func (p *QQMusicProcessor) buildSongInfo(ctx context.Context, track *qqTrack, quality *qqQuality) *SongInfo {
+	size := quality.Size(track)
+	bitrate := ""
+	if track.Interval > 0 {
+		bitrate = strconv.FormatInt(8*size/int64(track.Interval)/1000, 10)
+	}
+	year := 0
+	if len(track.Album.TimePublic) >= 4 {
+		year, _ = strconv.Atoi(track.Album.TimePublic[:4])
+	}
+	picURL := ""
+	if track.Album.Mid != "" {
+		picURL = fmt.Sprintf(qqCoverFormat, track.Album.Mid)
+	}
+	lyric := p.fetchLyric(ctx, track)
+	if lyric == "" {
+		lyric = PureMusicLyric
+	}
+	return &SongInfo{
+		SongID:      track.Mid,
+		SongName:    track.Name,
+		SongArtists: qqArtists(track),
+		SongAlbum:   track.Album.Name,
+		FileExt:     strings.TrimPrefix(quality.Ext, "."),
+		MusicSize:   size,
+		Bitrate:     bitrate,
+		Duration:    track.Interval,
+		PicUrl:      picURL,
+		Lyric:       lyric,
+		Year:        year,
+		Tidy:        processor.DetermineTidyType(p.cfg),
+	}
+}
+
+// fetchLyric 获取歌词(LRC)
+func (p *QQMusicProcessor) fetchLyric(ctx context.Context, track *qqTrack) string {
+	var data struct {
+		Lyric string `json:"lyric"`
+	}
+	param := map[string]any{"songMID": track.Mid, "songID": track.ID}
+	if err := p.request(ctx, "music.musichallSong.PlayLyricInfo", "GetPlayLyricInfo", param, &data); err != nil {
+		utils.WarnWithFormat("[QQ] ⚠️ 获取歌词失败: %s: %v", track.Name, err)
+		return ""
+	}
+	lyric, err := base64.StdEncoding.DecodeString(data.Lyric)
+	if err != nil {
+		return ""
+	}
+	return html.UnescapeString(string(lyric))
+}
+
+// request 调用 musicu.fcg 接口,将 data 字段解析到 out
+func (p *QQMusicProcessor) request(ctx context.Context, module, method string, param map[string]any, out any) error {
+	payload := map[string]any{
+		"comm": map[string]any{
+			"ct":     24,
+			"cv":     0,
+			"format": "json",
+			"uin":    p.uin,
+			"authst": p.authst,
+		},
+		"req": map[string]any{
+			"module": module,
+			"method": method,
+			"param":  param,
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, qqMusicuAPI, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Referer", "https://y.qq.com/")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36")
+	if p.authst != "" {
+		req.Header.Set("Cookie", fmt.Sprintf("uin=%s; qqmusic_key=%s; qm_keyst=%s", p.uin, p.authst, p.authst))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("QQ音乐API请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code int `json:"code"`
+		Req  struct {
+			Code int             `json:"code"`
+			Data json.RawMessage `json:"data"`
+		} `json:"req"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析QQ音乐API响应失败: %w", err)
+	}
+	if result.Code != 0 || result.Req.Code != 0 {
+		return fmt.Errorf("QQ音乐API返回错误: %s.%s code=%d/%d", module, method, result.Code, result.Req.Code)
+	}
+	return json.Unmarshal(result.Req.Data, out)
+}
+
+// decryptedPath um 解密后的文件路径(同名不同后缀)
+func (p *QQMusicProcessor) decryptedPath(src string) string {
+	base := strings.TrimSuffix(src, filepath.Ext(src))
+	for _, ext := range p.DecryptedExts() {
+		if utils.FileExist(base + ext) {
+			return base + ext
+		}
+	}
+	return ""
+}
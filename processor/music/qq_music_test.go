@@ -0,0 +1,133 @@
+package music
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+func TestMain(m *testing.M) {
+	_ = utils.InitLogger(&config.LogConfig{Mode: 1, Level: 4})
+	os.Exit(m.Run())
+}
+
+// TestIdentifyQQMusicSongID 识别时不查询接口, 数字歌曲ID原样保留
+func TestIdentifyQQMusicSongID(t *testing.T) {
+	tests := []struct {
+		link, id string
+	}{
+		{"https://y.qq.com/n/yqq/song/102065756.html", "102065756"},
+		{"https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV", "0039MnYb0qxYhV"},
+	}
+	for _, tt := range tests {
+		res := identifyQQMusic(tt.link)
+		if res == nil || res.Kind != processor.ResourceTrack || res.ID != tt.id {
+			t.Errorf("identifyQQMusic(%q) = %v, 期望 track %s", tt.link, res, tt.id)
+		}
+	}
+}
+
+// fakeQQAPI 模拟统一接口, handle 按方法与参数返回 data
+func fakeQQAPI(t *testing.T, handle func(method string, param map[string]any) any) *QQMusicProcessor {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Req struct {
+				Method string         `json:"method"`
+				Param  map[string]any `json:"param"`
+			} `json:"req"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		data := handle(body.Req.Method, body.Req.Param)
+		_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "req": map[string]any{"code": 0, "data": data}})
+	}))
+	t.Cleanup(ts.Close)
+
+	api := qqMusicuAPI
+	qqMusicuAPI = ts.URL
+	t.Cleanup(func() { qqMusicuAPI = api })
+	return &QQMusicProcessor{client: ts.Client()}
+}
+
+// TestQQResolveSongID 下载时数字歌曲ID换取 mid, 与曲库中的记录一致
+func TestQQResolveSongID(t *testing.T) {
+	p := fakeQQAPI(t, func(method string, param map[string]any) any {
+		if param["song_id"] != "102065756" {
+			t.Errorf("查询参数 = %v, 期望 song_id", param)
+		}
+		return map[string]any{"track_info": map[string]any{"mid": "0039MnYb0qxYhV", "name": "晴天"}}
+	})
+	_, tracks, err := p.resolveTracks(context.Background(), "https://y.qq.com/n/ryqq/songDetail/102065756")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || tracks[0].Mid != "0039MnYb0qxYhV" {
+		t.Errorf("resolveTracks() = %+v, 期望 mid 0039MnYb0qxYhV", tracks)
+	}
+}
+
+// fakeQQList 模拟分页接口: 共 total 首, 最多返回 limit 首
+func fakeQQList(t *testing.T, total, limit int) *QQMusicProcessor {
+	t.Helper()
+	return fakeQQAPI(t, func(method string, param map[string]any) any {
+		begin, num := param["begin"], param["num"]
+		if method == "uniform_get_Dissinfo" {
+			begin, num = param["song_begin"], param["song_num"]
+		}
+		from, n := int(begin.(float64)), int(num.(float64))
+		tracks := make([]map[string]any, 0)
+		for i := from; i < min(from+n, limit); i++ {
+			tracks = append(tracks, map[string]any{"mid": fmt.Sprintf("mid%d", i), "album": map[string]any{"name": "专辑"}})
+		}
+		if method == "uniform_get_Dissinfo" {
+			return map[string]any{"dirinfo": map[string]any{"title": "歌单", "songnum": total}, "songlist": tracks}
+		}
+		songs := make([]map[string]any, 0, len(tracks))
+		for _, tr := range tracks {
+			songs = append(songs, map[string]any{"songInfo": tr})
+		}
+		return map[string]any{"totalNum": total, "songList": songs}
+	})
+}
+
+// TestQQResolveTracksPaging 专辑与歌单超过一页时分页获取全部歌曲
+func TestQQResolveTracksPaging(t *testing.T) {
+	tests := []struct {
+		link         string
+		total, limit int
+		title        string
+	}{
+		{"https://y.qq.com/n/ryqq/playlist/7011264340", 1200, 1200, "歌单"},
+		{"https://y.qq.com/n/ryqq/albumDetail/002MAeob3zLXwZ", 1000, 1000, "专辑"},
+		{"https://y.qq.com/n/ryqq/playlist/1", 10, 10, "歌单"},
+		// 接口返回的歌曲少于总数时, 以短页结束
+		{"https://y.qq.com/n/ryqq/playlist/2", 800, 600, "歌单"},
+	}
+	for _, tt := range tests {
+		p := fakeQQList(t, tt.total, tt.limit)
+		title, tracks, err := p.resolveTracks(context.Background(), tt.link)
+		if err != nil {
+			t.Fatalf("resolveTracks(%s): %v", tt.link, err)
+		}
+		if title != tt.title || len(tracks) != tt.limit {
+			t.Errorf("resolveTracks(%s) = %q, %d 首, 期望 %q, %d 首", tt.link, title, len(tracks), tt.title, tt.limit)
+			continue
+		}
+		for i, tr := range tracks {
+			if tr.Mid != fmt.Sprintf("mid%d", i) {
+				t.Errorf("第 %d 首 = %s, 分页顺序错误", i, tr.Mid)
+				break
+			}
+		}
+	}
+}
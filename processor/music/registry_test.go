@@ -0,0 +1,132 @@
+package music
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/storage"
+)
+
+// jobState 处理器实例中按任务保存的状态
+func jobState(t *testing.T, p Processor) (songs *[]*SongInfo, tempDir *string) {
+	t.Helper()
+	switch p := p.(type) {
+	case *NetEaseProcessor:
+		return &p.songs, &p.tempDir
+	case *QQMusicProcessor:
+		return &p.songs, &p.tempDir
+	case *AppleMusicProcessor:
+		return &p.songs, &p.tempDir
+	case *SpotifyProcessor:
+		return &p.songs, &p.tempDir
+	case *SoundCloudProcessor:
+		return &p.songs, &p.tempDir
+	case *YoutubeMusicProcessor:
+		return &p.songs, &p.tempDir
+	}
+	t.Fatalf("未知处理器: %T", p)
+	return nil, nil
+}
+
+// TestFactoryConcurrentJobs 同一平台的并发任务各自使用独立的处理器实例与临时目录, 并发整理互不干扰(go test -race)
+func TestFactoryConcurrentJobs(t *testing.T) {
+	// 临时目录为相对路径, 在测试目录中运行
+	t.Chdir(t.TempDir())
+	dist := t.TempDir()
+	cfg := &config.Config{
+		CookieCloud: &config.CookieCloudConfig{CookieFilePath: t.TempDir(), CookieFile: "cookies.txt"},
+		Tidy: &config.TidyConfig{
+			Backend:     "local",
+			DistDir:     dist,
+			MusicLayout: "{platform}/{title}.{ext}",
+			Collision:   storage.CollisionSuffix,
+		},
+	}
+	backend := storage.GlobalBackend
+	storage.GlobalBackend = &storage.Local{Root: dist}
+	t.Cleanup(func() { storage.GlobalBackend = backend })
+
+	links := []string{
+		"https://music.163.com/song?id=1",
+		"https://y.qq.com/n/ryqq/songDetail/0039MnYb0qxYhV",
+		"https://music.apple.com/us/album/x/1440857781?i=1440857782",
+		"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC",
+		"https://soundcloud.com/user/track",
+		"https://music.youtube.com/watch?v=dQw4w9WgXcQ",
+	}
+	const jobs = 8
+	for _, link := range links {
+		reg := processor.Lookup(link)
+		if reg == nil {
+			t.Fatalf("未找到处理器: %s", link)
+		}
+		t.Run(string(reg.Name), func(t *testing.T) {
+			procs := make([]Processor, jobs)
+			var wg sync.WaitGroup
+			for i := range jobs {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p, ok := reg.Factory().(Processor)
+					if !ok {
+						t.Errorf("%s 不是音乐处理器", reg.Name)
+						return
+					}
+					p.Init(cfg)
+					procs[i] = p
+
+					// 模拟下载阶段: 各任务在自己的临时目录写入同名文件并记录歌曲
+					songs, tempDir := jobState(t, p)
+					if err := processor.CreateOutputDir(*tempDir); err != nil {
+						t.Error(err)
+						return
+					}
+					path := filepath.Join(*tempDir, "song"+p.DecryptedExts()[0])
+					if err := os.WriteFile(path, []byte(fmt.Sprint("任务", i)), 0644); err != nil {
+						t.Error(err)
+						return
+					}
+					*songs = append(*songs, &SongInfo{SongID: fmt.Sprint(i), SongName: fmt.Sprint("歌曲", i), MusicPath: path})
+
+					if err := p.TidyMusic(context.Background()); err != nil {
+						t.Errorf("任务 %d 整理失败: %v", i, err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			dirs := make(map[string]int, jobs)
+			for i, p := range procs {
+				if p == nil {
+					continue
+				}
+				songs, tempDir := jobState(t, p)
+				if len(*songs) != 1 || (*songs)[0].SongID != fmt.Sprint(i) {
+					t.Errorf("任务 %d 的歌曲列表被其他任务修改: %d 首", i, len(*songs))
+				}
+				if j, ok := dirs[*tempDir]; ok {
+					t.Errorf("任务 %d 与任务 %d 共用临时目录 %s", i, j, *tempDir)
+				}
+				dirs[*tempDir] = i
+				if _, err := os.Stat(*tempDir); !os.IsNotExist(err) {
+					t.Errorf("任务 %d 的临时目录未清除: %s", i, *tempDir)
+				}
+
+				// 每个任务整理出的文件是自己下载的内容
+				key := fmt.Sprintf("%s/歌曲%d%s", reg.Name, i, p.DecryptedExts()[0])
+				data, err := os.ReadFile(filepath.Join(dist, key))
+				if err != nil {
+					t.Errorf("任务 %d 未整理出 %s: %v", i, key, err)
+				} else if string(data) != fmt.Sprint("任务", i) {
+					t.Errorf("任务 %d 整理出的 %s 内容为 %q", i, key, data)
+				}
+			}
+		})
+	}
+}
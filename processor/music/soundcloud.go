@@ -1,18 +1,92 @@
 package music
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
 )
 
+// soundcloudArtworkRe 封面尺寸后缀,如 -large.jpg / -t500x500.jpg
+var soundcloudArtworkRe = regexp.MustCompile(`-(?:large|t\d+x\d+|crop|badge|small|tiny|mini)\.(jpg|png)`)
+
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkSoundcloud,
+		[]string{
+			"soundcloud.com",
+			"snd.sc",
+		},
+		[]*regexp.Regexp{
+			// 用户主页 / 曲目 / 播放列表
+			regexp.MustCompile(`^https?://(?:soundcloud\.com|snd\.sc)/[A-Za-z0-9_\-]+/(?:sets/[A-Za-z0-9_\-]+|[A-Za-z0-9_\-]+)(?:\?.*)?$`),
+		},
+		func() processor.Processor { return &SoundCloudProcessor{} },
+	).WithIdentifier(identifySoundcloud)
+}
+
+// soundcloudUserTabs 用户主页下的分栏
+var soundcloudUserTabs = []string{"tracks", "sets", "albums", "popular-tracks", "reposts"}
+
+// identifySoundcloud 识别 SoundCloud 链接(ID 为 用户/曲目 形式的路径), snd.sc 短链需还原后识别
+func identifySoundcloud(link string) *processor.Resource {
+	u, err := url.Parse(link)
+	if err != nil || !strings.HasSuffix(u.Host, "soundcloud.com") {
+		return nil
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	kind := processor.ResourceUnknown
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		kind = processor.ResourceUser
+	case len(parts) == 3 && parts[1] == "sets":
+		kind = processor.ResourcePlaylist
+	case len(parts) == 2 && parts[1] == "likes":
+		kind = processor.ResourcePlaylist
+	case len(parts) == 2 && utils.Contains(soundcloudUserTabs, parts[1]):
+		kind, parts = processor.ResourceUser, parts[:1]
+	case len(parts) == 2:
+		kind = processor.ResourceTrack
+	default:
+		return nil
+	}
+	id := strings.Join(parts, "/")
+	return processor.NewResource(kind, id, "https://soundcloud.com/%s", id)
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type SoundCloudProcessor struct {
 	cfg     *config.Config
 	tempDir string
 	songs   []*SongInfo
+	tracker *processor.YtDlpTracker
+	isSet   bool // 是否为歌单(sets)链接
+}
+
+// soundcloudTrack yt-dlp info.json 中用到的字段
+type soundcloudTrack struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Uploader      string `json:"uploader"`
+	Genre         string `json:"genre"`
+	Description   string `json:"description"`
+	Thumbnail     string `json:"thumbnail"`
+	UploadDate    string `json:"upload_date"`
+	PlaylistTitle string `json:"playlist_title"`
 }
 
 // Init  初始化
@@ -20,6 +94,8 @@ func (p *SoundCloudProcessor) Init(cfg *config.Config) {
 	p.cfg = cfg
 	p.songs = make([]*SongInfo, 0)
 	p.tempDir = processor.BuildOutputDir(SoundcloudTempDir)
+	p.tracker = nil
+	p.isSet = false
 }
 
 /* ---------------------- 基础接口实现 ---------------------- */
@@ -34,44 +110,148 @@ func (p *SoundCloudProcessor) Songs() []*SongInfo {
 
 /* ------------------------ 下载逻辑 ------------------------ */
 
-func (p *SoundCloudProcessor) DownloadMusic(url string, callback func(string)) error {
-	// TODO implement me
-	panic("implement me")
+func (p *SoundCloudProcessor) DownloadMusic(ctx context.Context, url string, report utils.ProgressFunc) error {
+	start := time.Now()
+	utils.InfoWithFormat("[SoundCloud] 🎵 开始下载: %s", url)
+	p.isSet = strings.Contains(url, "/sets/")
+
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[SoundCloud] ❌ 创建临时目录失败: %v", err)
+		return err
+	}
+
+	cmd := p.DownloadCommand(ctx, url)
+	utils.DebugWithFormat("[SoundCloud] 执行命令: %s", strings.Join(cmd.Args, " "))
+
+	p.tracker = processor.NewYtDlpTracker(report)
+	logOut, err := processor.RunCommand(cmd, p.tracker.Feed)
+	if err != nil && ctx.Err() != nil {
+		_ = processor.RemoveTempDir(p.tempDir)
+		utils.InfoWithFormat("[SoundCloud] 🚫 下载已取消: %s", url)
+		return ctx.Err()
+	}
+	// 歌单/喜欢列表中个别曲目失败(如地区限制、Go+ 专享)时 yt-dlp 也会返回非0,只要有曲目下载成功就继续整理
+	if p.tracker.Downloaded() == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		utils.ErrorWithFormat("[SoundCloud] ❌ 下载失败: %v\n输出:\n%s", err, logOut)
+		if len(p.tracker.Errors) > 0 {
+			return fmt.Errorf("yt-dlp 下载失败: %s", p.tracker.Errors[0])
+		}
+		return fmt.Errorf("yt-dlp 下载失败: %v", err)
+	}
+	if err != nil {
+		utils.WarnWithFormat("[SoundCloud] ⚠️ 部分曲目下载失败: %s", strings.Join(p.tracker.Errors, "; "))
+	}
+
+	utils.InfoWithFormat("[SoundCloud] ✅ 下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond))
+	report.Message(utils.PhaseDone, "下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond))
+	return nil
 }
 
-func (p *SoundCloudProcessor) DownloadCommand(url string) *exec.Cmd {
-	// TODO implement me
-	panic("implement me")
+func (p *SoundCloudProcessor) DownloadCommand(ctx context.Context, url string) *exec.Cmd {
+	args := []string{
+		"-f", "bestaudio/best",
+		"-x", "--audio-format", "best",
+		"-o", filepath.Join(p.tempDir, "%(uploader)s - %(title)s.%(ext)s"),
+		// 元数据由 BeforeTidy 读取 info.json 后写入
+		"--write-info-json",
+		"-o", "infojson:" + filepath.Join(p.tempDir, "%(id)s"),
+		// 单曲/歌单/用户喜欢与转发列表均按列表展开
+		"--yes-playlist",
+	}
+	// 登录 cookie 可下载私密链接与用户的喜欢列表
+	cookiePath := filepath.Join(p.cfg.CookieCloud.CookieFilePath, p.cfg.CookieCloud.CookieFile)
+	if _, err := os.Stat(cookiePath); err == nil {
+		args = append(args, "--cookies", cookiePath)
+	}
+	args = append(args, processor.YtDlpOutputArgs()...)
+	args = append(args, url)
+
+	return processor.Command(ctx, "yt-dlp", args...)
 }
 
 func (p *SoundCloudProcessor) BeforeTidy() error {
-	// TODO implement me
-	panic("implement me")
+	songs, err := ReadMusicDir(p.tempDir, processor.DetermineTidyType(p.cfg), p)
+	if err != nil {
+		return err
+	}
+	for _, song := range songs {
+		song.SongID = p.tracker.IDOf(song.MusicPath)
+		track, err := p.readTrackInfo(song.SongID)
+		if err != nil {
+			utils.WarnWithFormat("[SoundCloud] ⚠️ 读取曲目信息失败 %s: %v", filepath.Base(song.MusicPath), err)
+			continue
+		}
+		p.applyTrackInfo(song, track)
+		ApplyLyrics(p.cfg, song, song.MusicPath)
+		if err := WriteTagsWithCoverURL(song, song.MusicPath); err != nil {
+			utils.WarnWithFormat("[SoundCloud] ⚠️ 写入标签失败: %v", err)
+		}
+	}
+	// 更新元信息列表(跳过曲库中已存在的歌曲)
+	p.songs = SkipLibraryDuplicates(p.Name(), songs)
+	return nil
 }
 
 func (p *SoundCloudProcessor) NeedRemoveDRM() bool {
-	// TODO implement me
-	panic("implement me")
+	return false
 }
 
 func (p *SoundCloudProcessor) DRMRemove() error {
-	// TODO implement me
-	panic("implement me")
+	return nil
 }
 
-func (p *SoundCloudProcessor) TidyMusic() error {
-	// TODO implement me
-	panic("implement me")
+func (p *SoundCloudProcessor) TidyMusic(ctx context.Context) error {
+	if len(p.songs) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return core.ErrInLibrary
+	}
+	return TidyMusicDir(ctx, "SoundCloud", p, p.tempDir, p.cfg)
 }
 
 func (p *SoundCloudProcessor) EncryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{}
 }
 
 func (p *SoundCloudProcessor) DecryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{".mp3", ".m4a", ".opus", ".ogg", ".aac"}
 }
 
 /* ------------------------ 拓展方法 ------------------------ */
+
+// readTrackInfo 读取曲目对应的 info.json
+func (p *SoundCloudProcessor) readTrackInfo(id string) (*soundcloudTrack, error) {
+	if id == "" {
+		return nil, fmt.Errorf("未找到曲目ID")
+	}
+	data, err := os.ReadFile(filepath.Join(p.tempDir, id+".info.json"))
+	if err != nil {
+		return nil, err
+	}
+	track := &soundcloudTrack{}
+	if err := json.Unmarshal(data, track); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// applyTrackInfo 使用曲目信息补全标签
+func (p *SoundCloudProcessor) applyTrackInfo(song *SongInfo, track *soundcloudTrack) {
+	song.SongName = track.Title
+	song.SongArtists = track.Uploader
+	song.SongAlbumArtist = track.Uploader
+	// 歌单以歌单名作为专辑,单曲/喜欢列表以曲名作为专辑
+	song.SongAlbum = track.Title
+	if p.isSet && track.PlaylistTitle != "" {
+		song.SongAlbum = track.PlaylistTitle
+	}
+	song.Genre = track.Genre
+	song.Comment = strings.TrimSpace(track.Description)
+	song.PicUrl = soundcloudArtworkRe.ReplaceAllString(track.Thumbnail, "-original.$1")
+	if len(track.UploadDate) >= 4 {
+		if year, err := strconv.Atoi(track.UploadDate[:4]); err == nil {
+			song.Year = year
+		}
+	}
+}
@@ -1,12 +1,70 @@
 package music
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
 )
 
+var (
+	// spotifyLinkRe 链接类型与ID: track/album/playlist/artist
+	spotifyLinkRe = regexp.MustCompile(`spotify\.com/(?:intl-[a-z]+/)?(track|album|playlist|artist)/([A-Za-z0-9]+)`)
+	// spotdlFoundRe 解析到的歌曲数量,如: Found 12 songs in Album (Album)
+	spotdlFoundRe = regexp.MustCompile(`Found (\d+) songs? in (.+)`)
+	// spotdlDownloadedRe 单曲下载完成,如: Downloaded "Artist - Title": https://...
+	spotdlDownloadedRe = regexp.MustCompile(`Downloaded "(.+?)"`)
+	// spotdlSkippedRe 已存在的文件
+	spotdlSkippedRe = regexp.MustCompile(`Skipping (.+?) \(`)
+	// spotifyFileIDRe 文件名中的歌曲ID,如: Artist - Title [4uLU6hMCjMI75M1A2tKUQC].mp3
+	spotifyFileIDRe = regexp.MustCompile(`\[([A-Za-z0-9]{22})\]\.[^.]+$`)
+)
+
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkSpotify,
+		[]string{
+			"open.spotify.com",
+			"play.spotify.com",
+		},
+		[]*regexp.Regexp{
+			// track / album / playlist + ID (通常 22 字符), 兼容 intl-xx 地区前缀
+			regexp.MustCompile(`^https?://(?:open\.spotify\.com|play\.spotify\.com)/(?:intl-[a-z]+/)?(?:track|album|playlist)/[A-Za-z0-9]+(?:\?.*)?$`),
+		},
+		func() processor.Processor { return &SpotifyProcessor{} },
+	).WithIdentifier(identifySpotify)
+}
+
+// spotifyKinds 链接路径 -> 资源类型
+var spotifyKinds = map[string]processor.ResourceKind{
+	"track":    processor.ResourceTrack,
+	"album":    processor.ResourceAlbum,
+	"playlist": processor.ResourcePlaylist,
+	"artist":   processor.ResourceArtist,
+}
+
+// identifySpotify 识别 Spotify 链接
+func identifySpotify(link string) *processor.Resource {
+	m := spotifyLinkRe.FindStringSubmatch(link)
+	if m == nil {
+		return nil
+	}
+	return processor.NewResource(spotifyKinds[m[1]], m[2], "https://open.spotify.com/%s/%s", m[1], m[2])
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type SpotifyProcessor struct {
@@ -15,6 +73,13 @@ type SpotifyProcessor struct {
 	songs   []*SongInfo
 }
 
+// spotdlSong spotdl save 保存的歌曲信息
+type spotdlSong struct {
+	Name   string `json:"name"`
+	SongID string `json:"song_id"`
+	URL    string `json:"url"`
+}
+
 // Init  初始化
 func (p *SpotifyProcessor) Init(cfg *config.Config) {
 	p.cfg = cfg
@@ -33,44 +98,200 @@ func (p *SpotifyProcessor) Songs() []*SongInfo {
 }
 
 /* ------------------------ 下载逻辑 ------------------------ */
-func (p *SpotifyProcessor) DownloadMusic(url string, callback func(string)) error {
-	// TODO implement me
-	panic("implement me")
+
+func (p *SpotifyProcessor) DownloadMusic(ctx context.Context, url string, report utils.ProgressFunc) error {
+	start := time.Now()
+	utils.InfoWithFormat("[Spotify] 🎵 开始下载: %s", url)
+
+	res := identifySpotify(url)
+	if res != nil && res.Kind == processor.ResourceTrack {
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), res.ID); ok {
+			core.LogLibraryHit("Spotify", e)
+			report.Message(utils.PhaseDone, "已在曲库中，跳过下载")
+			return core.ErrInLibrary
+		}
+	}
+
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[Spotify] ❌ 创建临时目录失败: %v", err)
+		return err
+	}
+
+	// 专辑/歌单下载前查询曲库, 跳过已入库的歌曲
+	urls := []string{url}
+	if res != nil && res.Kind != processor.ResourceTrack && core.GlobalLibrary != nil {
+		var err error
+		if urls, err = p.skipLibraryTracks(ctx, url, report); err != nil {
+			_ = processor.RemoveTempDir(p.tempDir)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+	}
+
+	cmd := p.downloadCommand(ctx, urls)
+	utils.DebugWithFormat("[Spotify] 执行命令: %s", strings.Join(cmd.Args, " "))
+
+	// 逐首下载时总数即链接数
+	total, done := 0, 0
+	if len(urls) > 1 {
+		total = len(urls)
+	}
+	logOut, err := processor.RunCommand(cmd, func(line string) {
+		utils.DebugWithFormat("[Spotify] %s", line)
+		switch {
+		case len(urls) == 1 && spotdlFoundRe.MatchString(line):
+			m := spotdlFoundRe.FindStringSubmatch(line)
+			total, _ = strconv.Atoi(m[1])
+			report.Emit(utils.Progress{Phase: utils.PhaseResolve, Total: total, Item: m[2], Message: fmt.Sprintf("解析到 %d 首歌曲", total)})
+		case spotdlDownloadedRe.MatchString(line):
+			done++
+			name := spotdlDownloadedRe.FindStringSubmatch(line)[1]
+			report.Emit(utils.Progress{Phase: utils.PhaseDownload, Current: done, Total: total, Item: name, Message: "已下载"})
+		case spotdlSkippedRe.MatchString(line):
+			done++
+			name := spotdlSkippedRe.FindStringSubmatch(line)[1]
+			report.Emit(utils.Progress{Phase: utils.PhaseDownload, Current: done, Total: total, Item: name, Message: "已存在"})
+		case strings.Contains(line, "Error"):
+			utils.WarnWithFormat("[Spotify] ⚠️ %s", line)
+		}
+	})
+	if err != nil {
+		_ = processor.RemoveTempDir(p.tempDir)
+		if ctx.Err() != nil {
+			utils.InfoWithFormat("[Spotify] 🚫 下载已取消: %s", url)
+			return ctx.Err()
+		}
+		utils.ErrorWithFormat("[Spotify] ❌ 下载失败: %v\n输出:\n%s", err, logOut)
+		return fmt.Errorf("spotdl 下载失败: %w", err)
+	}
+	if done == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		utils.ErrorWithFormat("[Spotify] ❌ 未下载到任何歌曲\n输出:\n%s", logOut)
+		return fmt.Errorf("spotdl 未下载到任何歌曲: %s", utils.TruncateString(logOut, 200))
+	}
+
+	utils.InfoWithFormat("[Spotify] ✅ 下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond))
+	report.Message(utils.PhaseDone, "下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond))
+	return nil
 }
 
-func (p *SpotifyProcessor) DownloadCommand(url string) *exec.Cmd {
-	// TODO implement me
-	panic("implement me")
+func (p *SpotifyProcessor) DownloadCommand(ctx context.Context, url string) *exec.Cmd {
+	return p.downloadCommand(ctx, []string{url})
 }
 
 func (p *SpotifyProcessor) BeforeTidy() error {
-	// TODO implement me
-	panic("implement me")
+	songs, err := ReadMusicDir(p.tempDir, processor.DetermineTidyType(p.cfg), p)
+	if err != nil {
+		return err
+	}
+	// 文件名中记录了歌曲ID
+	for _, song := range songs {
+		if m := spotifyFileIDRe.FindStringSubmatch(filepath.Base(song.MusicPath)); m != nil {
+			song.SongID = m[1]
+		}
+	}
+	// 更新元信息列表(跳过曲库中已存在的歌曲)
+	p.songs = SkipLibraryDuplicates(p.Name(), songs)
+	return nil
 }
 
 func (p *SpotifyProcessor) NeedRemoveDRM() bool {
-	// TODO implement me
-	panic("implement me")
+	return false
 }
 
 func (p *SpotifyProcessor) DRMRemove() error {
-	// TODO implement me
-	panic("implement me")
+	return nil
 }
 
-func (p *SpotifyProcessor) TidyMusic() error {
-	// TODO implement me
-	panic("implement me")
+func (p *SpotifyProcessor) TidyMusic(ctx context.Context) error {
+	if len(p.songs) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return core.ErrInLibrary
+	}
+	return TidyMusicDir(ctx, "Spotify", p, p.tempDir, p.cfg)
 }
 
 func (p *SpotifyProcessor) EncryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{}
 }
 
 func (p *SpotifyProcessor) DecryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{".mp3", ".m4a", ".opus", ".ogg", ".flac"}
 }
 
 /* ------------------------ 拓展方法 ------------------------ */
+
+// downloadCommand 构建 spotdl 命令, 文件名中带歌曲ID用于曲库去重
+func (p *SpotifyProcessor) downloadCommand(ctx context.Context, urls []string) *exec.Cmd {
+	cookiePath := filepath.Join(p.cfg.CookieCloud.CookieFilePath, p.cfg.CookieCloud.CookieFile)
+	args := append([]string{"download"}, urls...)
+	args = append(args,
+		"--output", filepath.Join(p.tempDir, "{artists} - {title} [{track-id}].{output-ext}"),
+		"--format", "mp3",
+		"--bitrate", "auto",
+		"--simple-tui",
+		"--print-errors",
+	)
+	// YouTube Music 会员 cookie 可获取更高码率
+	if utils.GetCookieValue(cookiePath, ".youtube.com", "SID") != "" {
+		args = append(args, "--cookie-file", cookiePath)
+	}
+	return processor.Command(ctx, "spotdl", args...)
+}
+
+// skipLibraryTracks 下载前查询曲库: 专辑/歌单通过 spotdl save 展开为歌曲后逐首查询,
+// 部分歌曲已入库时改为逐首下载其余歌曲, 全部已入库时返回 core.ErrInLibrary
+func (p *SpotifyProcessor) skipLibraryTracks(ctx context.Context, url string, report utils.ProgressFunc) ([]string, error) {
+	tracks, err := p.listTracks(ctx, url)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// 无法展开时整体下载, 下载后按文件哈希去重
+		utils.WarnWithFormat("[Spotify] ⚠️ 获取歌曲列表失败, 下载后再查询曲库: %v", err)
+		return []string{url}, nil
+	}
+	missing := make([]string, 0, len(tracks))
+	for _, t := range tracks {
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), t.SongID); ok {
+			core.LogLibraryHit("Spotify", e)
+			continue
+		}
+		missing = append(missing, cmp.Or(t.URL, "https://open.spotify.com/track/"+t.SongID))
+	}
+	skipped := len(tracks) - len(missing)
+	switch {
+	case len(tracks) > 0 && len(missing) == 0:
+		utils.InfoWithFormat("[Spotify] ⏭️ %d 首歌曲均已在曲库中", skipped)
+		report.Message(utils.PhaseDone, "已在曲库中，跳过下载")
+		return nil, core.ErrInLibrary
+	case skipped > 0:
+		utils.InfoWithFormat("[Spotify] ⏭️ 曲库中已有 %d 首, 仅下载其余 %d 首", skipped, len(missing))
+		report.Message(utils.PhaseResolve, "曲库中已有 %d 首，跳过", skipped)
+		return missing, nil
+	default:
+		return []string{url}, nil
+	}
+}
+
+// listTracks 通过 spotdl save 获取专辑/歌单中的歌曲(不下载)
+func (p *SpotifyProcessor) listTracks(ctx context.Context, url string) ([]spotdlSong, error) {
+	saveFile := filepath.Join(p.tempDir, "tracks.spotdl")
+	defer os.Remove(saveFile)
+	cmd := processor.Command(ctx, "spotdl", "save", url, "--save-file", saveFile)
+	if logOut, err := processor.RunCommand(cmd, nil); err != nil {
+		return nil, fmt.Errorf("spotdl save 失败: %w: %s", err, utils.TruncateString(logOut, 200))
+	}
+	data, err := os.ReadFile(saveFile)
+	if err != nil {
+		return nil, err
+	}
+	var tracks []spotdlSong
+	if err := json.Unmarshal(data, &tracks); err != nil {
+		return nil, fmt.Errorf("解析歌曲列表失败: %w", err)
+	}
+	return tracks, nil
+}
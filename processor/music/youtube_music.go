@@ -1,18 +1,75 @@
 package music
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
 )
 
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	// 与 YouTube 视频共用 youtube.com 域名, 优先匹配 music.youtube.com
+	processor.Register(processor.LinkYoutubeMusic,
+		[]string{
+			"youtube.com",
+			"music.youtube.com",
+			"youtu.be",
+		},
+		[]*regexp.Regexp{
+			// YouTube Music 视频
+			regexp.MustCompile(`^https?://music\.youtube\.com/watch\?v=[\w-]+(?:&.*)?$`),
+
+			// 播放列表 / 专辑(OLAK5uy_ 开头的列表)
+			regexp.MustCompile(`^https?://music\.youtube\.com/playlist\?list=[\w-]+(?:&.*)?$`),
+
+			// 专辑页
+			regexp.MustCompile(`^https?://music\.youtube\.com/browse/MPREb_[\w-]+(?:\?.*)?$`),
+		},
+		func() processor.Processor { return &YoutubeMusicProcessor{} },
+	).WithPriority(10).WithIdentifier(identifyYoutubeMusic)
+}
+
+// ytmBrowseRe 专辑页ID
+var ytmBrowseRe = regexp.MustCompile(`/browse/(MPREb_[\w-]+)`)
+
+// identifyYoutubeMusic 识别 YouTube Music 链接
+func identifyYoutubeMusic(link string) *processor.Resource {
+	if id := processor.QueryParam(link, "v"); id != "" {
+		return processor.NewResource(processor.ResourceTrack, id, "https://music.youtube.com/watch?v=%s", id)
+	}
+	if id := processor.QueryParam(link, "list"); id != "" {
+		// OLAK5uy_ 开头的列表为专辑
+		kind := processor.ResourcePlaylist
+		if strings.HasPrefix(id, "OLAK5uy_") {
+			kind = processor.ResourceAlbum
+		}
+		return processor.NewResource(kind, id, "https://music.youtube.com/playlist?list=%s", id)
+	}
+	if m := ytmBrowseRe.FindStringSubmatch(link); m != nil {
+		return processor.NewResource(processor.ResourceAlbum, m[1], "https://music.youtube.com/browse/%s", m[1])
+	}
+	return nil
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type YoutubeMusicProcessor struct {
 	cfg     *config.Config
 	tempDir string
 	songs   []*SongInfo
+	tracker *processor.YtDlpTracker
 }
 
 // Init  初始化
@@ -20,6 +77,7 @@ func (p *YoutubeMusicProcessor) Init(cfg *config.Config) {
 	p.cfg = cfg
 	p.songs = make([]*SongInfo, 0)
 	p.tempDir = processor.BuildOutputDir(YoutubeTempDir)
+	p.tracker = nil
 }
 
 /* ---------------------- 基础接口实现 ---------------------- */
@@ -33,44 +91,152 @@ func (p *YoutubeMusicProcessor) Songs() []*SongInfo {
 }
 
 /* ------------------------ 下载逻辑 ------------------------ */
-func (p *YoutubeMusicProcessor) DownloadMusic(url string, callback func(string)) error {
-	// TODO implement me
-	panic("implement me")
+
+func (p *YoutubeMusicProcessor) DownloadMusic(ctx context.Context, link string, report utils.ProgressFunc) error {
+	start := time.Now()
+	utils.InfoWithFormat("[YoutubeMusic] 🎵 开始下载: %s", link)
+
+	if id := p.videoID(link); id != "" {
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), id); ok {
+			core.LogLibraryHit("YoutubeMusic", e)
+			report.Message(utils.PhaseDone, "已在曲库中，跳过下载")
+			return core.ErrInLibrary
+		}
+	}
+
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[YoutubeMusic] ❌ 创建临时目录失败: %v", err)
+		return err
+	}
+
+	cmd := p.DownloadCommand(ctx, link)
+	utils.DebugWithFormat("[YoutubeMusic] 执行命令: %s", strings.Join(cmd.Args, " "))
+
+	p.tracker = processor.NewYtDlpTracker(report)
+	logOut, err := processor.RunCommand(cmd, p.tracker.Feed)
+	if err != nil && ctx.Err() != nil {
+		_ = processor.RemoveTempDir(p.tempDir)
+		utils.InfoWithFormat("[YoutubeMusic] 🚫 下载已取消: %s", link)
+		return ctx.Err()
+	}
+	// 歌单中个别歌曲失败时 yt-dlp 也会返回非0,只要有歌曲下载成功就继续整理
+	if p.tracker.Downloaded() == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		utils.ErrorWithFormat("[YoutubeMusic] ❌ 下载失败: %v\n输出:\n%s", err, logOut)
+		if len(p.tracker.Errors) > 0 {
+			return fmt.Errorf("yt-dlp 下载失败: %s", p.tracker.Errors[0])
+		}
+		return fmt.Errorf("yt-dlp 下载失败: %v", err)
+	}
+	if err != nil {
+		utils.WarnWithFormat("[YoutubeMusic] ⚠️ 部分歌曲下载失败: %s", strings.Join(p.tracker.Errors, "; "))
+	}
+
+	utils.InfoWithFormat("[YoutubeMusic] ✅ 下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond))
+	report.Message(utils.PhaseDone, "下载完成（耗时 %v）", time.Since(start).Truncate(time.Millisecond))
+	return nil
 }
 
-func (p *YoutubeMusicProcessor) DownloadCommand(url string) *exec.Cmd {
-	// TODO implement me
-	panic("implement me")
+func (p *YoutubeMusicProcessor) DownloadCommand(ctx context.Context, link string) *exec.Cmd {
+	ytmCfg := p.cfg.YoutubeMusic
+	args := []string{
+		"-f", "bestaudio[ext=m4a]/bestaudio/best",
+		"-x", "--audio-format", ytmCfg.AudioFormat,
+		"-o", filepath.Join(p.tempDir, "%(artist,creator,uploader)s - %(track,title)s.%(ext)s"),
+		// 封面: 转为 jpg 并裁剪为正方形
+		"--embed-thumbnail",
+		"--convert-thumbnails", "jpg",
+		"--ppa", `ThumbnailsConvertor+FFmpeg_o:-c:v mjpeg -vf crop="'if(gt(ih,iw),iw,ih)':'if(gt(iw,ih),ih,iw)'"`,
+		// 元数据: 优先使用 YouTube Music 提供的歌曲信息
+		"--embed-metadata",
+		"--parse-metadata", "%(track,title)s:%(meta_title)s",
+		"--parse-metadata", "%(artist,creator,uploader)s:%(meta_artist)s",
+		"--parse-metadata", "%(album_artist,artist,creator,uploader)s:%(meta_album_artist)s",
+		"--parse-metadata", "%(album|)s:%(meta_album)s",
+		"--parse-metadata", "%(release_year,upload_date>%Y)s:%(meta_date)s",
+		"--replace-in-metadata", "meta_artist,meta_album_artist", ` - Topic$`, "",
+	}
+	if ytmCfg.AudioFormat != "best" && ytmCfg.AudioQuality != "" {
+		args = append(args, "--audio-quality", ytmCfg.AudioQuality)
+	}
+	if p.isPlaylist(link) {
+		args = append(args, "--yes-playlist")
+		// 专辑按列表顺序写入音轨号
+		if p.isAlbum(link) {
+			args = append(args, "--parse-metadata", "%(playlist_index)s:%(meta_track)s")
+		}
+	} else {
+		args = append(args, "--no-playlist")
+	}
+	// 年龄限制/会员内容需要登录 cookie
+	cookiePath := filepath.Join(p.cfg.CookieCloud.CookieFilePath, p.cfg.CookieCloud.CookieFile)
+	if _, err := os.Stat(cookiePath); err == nil {
+		args = append(args, "--cookies", cookiePath)
+	}
+	args = append(args, processor.YtDlpOutputArgs()...)
+	args = append(args, link)
+
+	return processor.Command(ctx, "yt-dlp", args...)
 }
 
 func (p *YoutubeMusicProcessor) BeforeTidy() error {
-	// TODO implement me
-	panic("implement me")
+	songs, err := ReadMusicDir(p.tempDir, processor.DetermineTidyType(p.cfg), p)
+	if err != nil {
+		return err
+	}
+	for _, song := range songs {
+		song.SongID = p.tracker.IDOf(song.MusicPath)
+	}
+	// 更新元信息列表(跳过曲库中已存在的歌曲)
+	p.songs = SkipLibraryDuplicates(p.Name(), songs)
+	return nil
 }
 
 func (p *YoutubeMusicProcessor) NeedRemoveDRM() bool {
-	// TODO implement me
-	panic("implement me")
+	return false
 }
 
 func (p *YoutubeMusicProcessor) DRMRemove() error {
-	// TODO implement me
-	panic("implement me")
+	return nil
 }
 
-func (p *YoutubeMusicProcessor) TidyMusic() error {
-	// TODO implement me
-	panic("implement me")
+func (p *YoutubeMusicProcessor) TidyMusic(ctx context.Context) error {
+	if len(p.songs) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return core.ErrInLibrary
+	}
+	return TidyMusicDir(ctx, "YoutubeMusic", p, p.tempDir, p.cfg)
 }
 
 func (p *YoutubeMusicProcessor) EncryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{}
 }
 
 func (p *YoutubeMusicProcessor) DecryptedExts() []string {
-	// TODO implement me
-	panic("implement me")
+	return []string{".m4a", ".opus", ".ogg", ".mp3", ".flac"}
 }
 
 /* ------------------------ 拓展方法 ------------------------ */
+
+// videoID 单曲链接的视频ID
+func (p *YoutubeMusicProcessor) videoID(link string) string {
+	if p.isPlaylist(link) {
+		return ""
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("v")
+}
+
+// isPlaylist 是否为播放列表/专辑链接
+func (p *YoutubeMusicProcessor) isPlaylist(link string) bool {
+	return strings.Contains(link, "/playlist?") || strings.Contains(link, "/browse/")
+}
+
+// isAlbum 是否为专辑链接(专辑对应的播放列表以 OLAK5uy_ 开头)
+func (p *YoutubeMusicProcessor) isAlbum(link string) bool {
+	return strings.Contains(link, "list=OLAK5uy_") || strings.Contains(link, "/browse/MPREb_")
+}
@@ -1,15 +1,20 @@
 package processor
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/nichuanfang/gymdl/config"
-
+	"github.com/nichuanfang/gymdl/storage"
 	"github.com/nichuanfang/gymdl/utils"
 )
 
@@ -43,17 +48,22 @@ const (
 	LinkDouyin      LinkType = "抖音"
 	LinkXiaohongshu LinkType = "小红书"
 	LinkYoutube     LinkType = "Youtube"
+
+	LinkAppleMusicVideo LinkType = "AppleMusicVideo"
 )
 
 /* ---------------------- 通用业务工具 ---------------------- */
 
+// outputDirSeq 输出目录序号,同一秒内创建的多个任务目录互不冲突
+var outputDirSeq atomic.Uint64
+
 // BuildOutputDir 构建输出目录
-// 规则: baseTempDir + 时间戳（例如：temp/20251030153045）
+// 规则: baseTempDir + 时间戳 + 序号（例如：temp/20251030153045-1）
 func BuildOutputDir(baseTempDir string) string {
 	// 1. 获取当前时间戳（格式：YYYYMMDDHHMMSS）
 	timestamp := time.Now().Format("20060102150405")
 	// 2. 构建输出目录路径
-	return filepath.Join(baseTempDir, timestamp)
+	return filepath.Join(baseTempDir, fmt.Sprintf("%s-%d", timestamp, outputDirSeq.Add(1)))
 }
 
 // CreateOutputDir 创建临时目录
@@ -95,78 +105,68 @@ func RemoveTempDir(dir string) error {
 	return nil
 }
 
-// DetermineTidyType 获取整理类型
-func DetermineTidyType(cfg *config.Config) string {
-	return map[int]string{1: "LOCAL", 2: "WEBDAV"}[cfg.Tidy.Mode]
+// Command 构建外部下载命令, 任务取消时终止进程;
+// 下载工具被终止后, 其子进程(如 ffmpeg、N_m3u8DL-RE)可能仍占用输出管道, 等待 5 秒后强制返回
+func Command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = 5 * time.Second
+	return cmd
 }
 
-// copyFile 使用缓冲区复制文件，确保文件句柄关闭
-func copyFile(src, dst string) error {
-	input, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer input.Close() // 手动关闭源文件
-
-	output, err := os.Create(dst)
+// RunCommand 执行外部下载命令,逐行回调输出(兼容 \r 刷新的进度行),返回最后若干行输出用于错误提示
+func RunCommand(cmd *exec.Cmd, onLine func(line string)) (string, error) {
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return err
+		return "", err
 	}
-
-	// 使用 1MB 缓冲区
-	buf := make([]byte, 1024*1024)
-	_, err = io.CopyBuffer(output, input, buf)
-
-	// 立即关闭目标文件
-	if closeErr := output.Close(); closeErr != nil {
-		utils.WarnWithFormat("[Um] ⚠️ 关闭目标文件失败: %v", closeErr)
+	// 合并标准错误,yt-dlp/spotdl 的部分进度与错误信息输出在 stderr
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return "", err
 	}
 
-	return err
-}
-
-// ToLocal 整理到本地目录
-func ToLocal(src string, dst string) error {
-	if sameDrive(src, dst) {
-		// 同盘直接重命名
-		if err := os.Rename(src, dst); err != nil {
-			return err
+	const keep = 20
+	tail := make([]string, 0, keep)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
 		}
-	} else {
-		// 跨盘复制 + 删除
-		if err := copyFile(src, dst); err != nil {
-			return err
+		if len(tail) == keep {
+			tail = tail[1:]
 		}
-
-		// 删除源文件
-		if err := os.Remove(src); err != nil {
-			return err
+		tail = append(tail, line)
+		if onLine != nil {
+			onLine(line)
 		}
 	}
-	return nil
+	// 单行超长等原因中断读取时,继续读完输出,避免子进程写满管道后阻塞
+	if err := scanner.Err(); err != nil {
+		utils.WarnWithFormat("读取命令输出中断: %v", err)
+		_, _ = io.Copy(io.Discard, stdout)
+	}
+	err = cmd.Wait()
+	return strings.Join(tail, "\n"), err
 }
 
-// sameDrive 判断两个路径是否在同一个磁盘或 UNC 网络共享
-func sameDrive(path1, path2 string) bool {
-	abs1, err1 := filepath.Abs(path1)
-	abs2, err2 := filepath.Abs(path2)
-	if err1 != nil || err2 != nil {
-		return false
+// scanLines 按 \n 或 \r 分割输出
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
 	}
-
-	// 本地盘符比较 (C:, D:...)
-	if len(abs1) >= 2 && len(abs2) >= 2 && abs1[1] == ':' && abs2[1] == ':' {
-		return strings.EqualFold(abs1[:2], abs2[:2])
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
 	}
-
-	// UNC 网络路径比较 (\\NAS\share)
-	if strings.HasPrefix(abs1, `\\`) && strings.HasPrefix(abs2, `\\`) {
-		parts1 := strings.SplitN(abs1, `\`, 4)
-		parts2 := strings.SplitN(abs2, `\`, 4)
-		if len(parts1) >= 3 && len(parts2) >= 3 {
-			return strings.EqualFold(parts1[1], parts2[1]) && strings.EqualFold(parts1[2], parts2[2])
-		}
+	if atEOF {
+		return len(data), data, nil
 	}
+	return 0, nil, nil
+}
 
-	return false
+// DetermineTidyType 获取整理类型(存储后端名称)
+func DetermineTidyType(cfg *config.Config) string {
+	return storage.TidyType(cfg)
 }
@@ -0,0 +1,156 @@
+package processor
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 处理器注册表: 各平台处理器在 init 中自注册, 链接解析器按域名与正则查找对应处理器
+
+// Factory 处理器构造函数
+type Factory func() Processor
+
+// Registration 处理器注册信息
+type Registration struct {
+	Name     LinkType         // 处理器名称(平台)
+	Domains  []string         // 快速判定域名
+	Patterns []*regexp.Regexp // 链接匹配规则
+	Factory  Factory          // 处理器构造函数
+	identify Identifier       // 资源识别
+	priority int              // 匹配优先级, 越大越先匹配
+	order    int              // 注册顺序, 优先级相同时先注册的先匹配
+}
+
+var (
+	registryMu sync.RWMutex
+	// registrations 按匹配顺序排列的注册信息
+	registrations []*Registration
+	// domainIndex 域名 -> 注册信息(按匹配顺序)
+	domainIndex = make(map[string][]*Registration)
+)
+
+/* ---------------------- 注册 ---------------------- */
+
+// Register 注册处理器, 同名处理器重复注册时 panic
+func Register(name LinkType, domains []string, patterns []*regexp.Regexp, factory Factory) *Registration {
+	if factory == nil {
+		panic(fmt.Sprintf("processor: %s 的构造函数为空", name))
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, r := range registrations {
+		if r.Name == name {
+			panic(fmt.Sprintf("processor: %s 重复注册", name))
+		}
+	}
+	r := &Registration{
+		Name:     name,
+		Domains:  domains,
+		Patterns: patterns,
+		Factory:  factory,
+		order:    len(registrations),
+	}
+	registrations = append(registrations, r)
+	reindex()
+	return r
+}
+
+// WithPriority 设置匹配优先级, 用于域名重叠的平台(如 YouTube 与 YouTube Music 共用 youtube.com)
+func (r *Registration) WithPriority(priority int) *Registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	r.priority = priority
+	reindex()
+	return r
+}
+
+// WithIdentifier 设置资源识别函数
+func (r *Registration) WithIdentifier(identify Identifier) *Registration {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	r.identify = identify
+	return r
+}
+
+// reindex 按优先级重排注册信息并重建域名索引, 调用方需持有写锁
+func reindex() {
+	sort.SliceStable(registrations, func(i, j int) bool {
+		if registrations[i].priority != registrations[j].priority {
+			return registrations[i].priority > registrations[j].priority
+		}
+		return registrations[i].order < registrations[j].order
+	})
+	domainIndex = make(map[string][]*Registration)
+	for _, r := range registrations {
+		for _, d := range r.Domains {
+			d = strings.ToLower(d)
+			domainIndex[d] = append(domainIndex[d], r)
+		}
+	}
+}
+
+/* ---------------------- 查找 ---------------------- */
+
+// Match 链接是否符合该处理器的匹配规则
+func (r *Registration) Match(link string) bool {
+	for _, re := range r.Patterns {
+		if re.MatchString(link) {
+			return true
+		}
+	}
+	return false
+}
+
+// Identify 识别链接对应的资源, 未设置识别函数或无法识别(如短链)时返回 nil
+func (r *Registration) Identify(link string) *Resource {
+	if r.identify == nil {
+		return nil
+	}
+	res := r.identify(link)
+	if res != nil {
+		res.Platform = r.Name
+	}
+	return res
+}
+
+// Get 按名称获取已注册的处理器
+func Get(name LinkType) *Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, r := range registrations {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Lookup 查找链接对应的处理器: 先按域名快速匹配, 未命中时穷举全部规则
+func Lookup(link string) *Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if u, err := url.Parse(link); err == nil {
+		for _, r := range domainIndex[strings.ToLower(u.Host)] {
+			if r.Match(link) {
+				return r
+			}
+		}
+	}
+	for _, r := range registrations {
+		if r.Match(link) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Registered 已注册的处理器(按匹配顺序)
+func Registered() []*Registration {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]*Registration(nil), registrations...)
+}
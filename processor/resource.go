@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// 资源标识: 同一资源的不同链接(短链/分享链接/网页链接)解析为相同的 平台+类型+ID
+
+// ResourceKind 资源类型
+type ResourceKind string
+
+const (
+	ResourceUnknown  ResourceKind = ""
+	ResourceTrack    ResourceKind = "track"    // 单曲
+	ResourceAlbum    ResourceKind = "album"    // 专辑
+	ResourcePlaylist ResourceKind = "playlist" // 歌单/播放列表/合集
+	ResourceArtist   ResourceKind = "artist"   // 歌手
+	ResourceRadio    ResourceKind = "radio"    // 电台
+	ResourceProgram  ResourceKind = "program"  // 电台节目
+	ResourceVideo    ResourceKind = "video"    // 视频/笔记
+	ResourceUser     ResourceKind = "user"     // 用户主页
+)
+
+// Resource 链接对应的资源
+type Resource struct {
+	Platform LinkType     `json:"platform"`
+	Kind     ResourceKind `json:"kind"`
+	ID       string       `json:"id"`
+	URL      string       `json:"url"` // 规范链接
+}
+
+// Identifier 从链接中识别资源类型、ID 与规范链接, 无法识别时返回 nil
+type Identifier func(link string) *Resource
+
+// Key 资源唯一标识, 用于去重
+func (r *Resource) Key() string {
+	if r.ID == "" {
+		return string(r.Platform) + ":" + r.URL
+	}
+	return fmt.Sprintf("%s:%s:%s", r.Platform, r.Kind, r.ID)
+}
+
+// String 日志展示
+func (r *Resource) String() string {
+	if r.ID == "" {
+		return fmt.Sprintf("【%s】%s", r.Platform, r.URL)
+	}
+	return fmt.Sprintf("【%s】%s %s", r.Platform, r.Kind, r.ID)
+}
+
+// NewResource 构建资源标识, format 与 args 组成规范链接
+func NewResource(kind ResourceKind, id string, format string, args ...any) *Resource {
+	return &Resource{Kind: kind, ID: id, URL: fmt.Sprintf(format, args...)}
+}
+
+// QueryParam 读取链接中的查询参数, 兼容 #/ 形式的前端路由
+func QueryParam(link, key string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	if v := u.Query().Get(key); v != "" {
+		return v
+	}
+	if fu, err := url.Parse(u.Fragment); err == nil {
+		return fu.Query().Get(key)
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package processor
+
+import "testing"
+
+func TestResourceKey(t *testing.T) {
+	tests := []struct {
+		res  Resource
+		want string
+	}{
+		{Resource{Platform: LinkNetEase, Kind: ResourceTrack, ID: "1901371647", URL: "https://music.163.com/song?id=1901371647"}, "网易云音乐:track:1901371647"},
+		{Resource{Platform: LinkQQMusic, Kind: ResourceAlbum, ID: "002eFUFm2XYZ7z"}, "QQ音乐:album:002eFUFm2XYZ7z"},
+		// 同一ID不同类型不是同一资源
+		{Resource{Platform: LinkNetEase, Kind: ResourcePlaylist, ID: "1901371647"}, "网易云音乐:playlist:1901371647"},
+		// 无法识别ID时按链接去重
+		{Resource{Platform: LinkDouyin, URL: "https://v.douyin.com/iRNBho6u/"}, "抖音:https://v.douyin.com/iRNBho6u/"},
+	}
+	for _, tt := range tests {
+		if got := tt.res.Key(); got != tt.want {
+			t.Errorf("Key() = %q, 期望 %q", got, tt.want)
+		}
+	}
+
+	// 不同链接识别为同一资源时 Key 相同
+	a := NewResource(ResourceTrack, "1", "https://music.163.com/song?id=%s", "1")
+	b := NewResource(ResourceTrack, "1", "https://music.163.com/#/song?id=%s", "1")
+	a.Platform, b.Platform = LinkNetEase, LinkNetEase
+	if a.Key() != b.Key() {
+		t.Errorf("同一资源 Key 不同: %s / %s", a.Key(), b.Key())
+	}
+}
+
+func TestQueryParam(t *testing.T) {
+	tests := []struct {
+		link, key, want string
+	}{
+		{"https://music.163.com/song?id=1&userid=2", "id", "1"},
+		{"https://music.163.com/#/song?id=3", "id", "3"},
+		{"https://music.163.com/m/#/playlist?id=4&creatorId=5", "creatorId", "5"},
+		{"https://music.163.com/song?id=1#/song?id=3", "id", "1"},
+		{"https://music.163.com/song", "id", ""},
+		{"://bad", "id", ""},
+	}
+	for _, tt := range tests {
+		if got := QueryParam(tt.link, tt.key); got != tt.want {
+			t.Errorf("QueryParam(%q, %q) = %q, 期望 %q", tt.link, tt.key, got, tt.want)
+		}
+	}
+}
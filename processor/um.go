@@ -0,0 +1,27 @@
+package processor
+
+import (
+	"os/exec"
+)
+
+// um(unlock-music) 加密音乐解密工具
+
+// UmEncryptedExts um 支持解密的加密后缀
+func UmEncryptedExts() []string {
+	return []string{".ncm", ".qmc3", ".qmcflac", ".mflac", ".mgg",
+		".mflac0", ".mgg1", ".mggl", ".mgalaxy", ".mflach", ".xm",
+		".kwm", ".mflac", ".kgm", ".vpr", ".kgg", ".x2m", ".x3m",
+		".xm", ".mg3d", ".qta"}
+}
+
+// BuildUmCmd 构建一个执行 um 命令的 *exec.Cmd
+func BuildUmCmd(inputFile, outputDir string) *exec.Cmd {
+	// um 命令参数列表
+	args := []string{
+		"--update-metadata",
+		"--overwrite",   // 覆盖输出文件
+		"-o", outputDir, // 输出目录
+		"-i", inputFile, // 输入文件
+	}
+	return exec.Command("um", args...)
+}
@@ -0,0 +1,184 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// Apple Music MV下载(gamdl)
+
+// appleMusicVideoRe 地区与MV ID
+var appleMusicVideoRe = regexp.MustCompile(`music\.apple\.com/([a-z]{2})/music-video/(?:[^/?]+/)?(\d+)`)
+
+// gamdlVideoCodecs 配置中的编码名称 -> gamdl MV编码名称
+var gamdlVideoCodecs = map[string]string{
+	"avc":  "h264",
+	"h264": "h264",
+	"hevc": "h265",
+	"h265": "h265",
+}
+
+// appleMusicVideoExts gamdl 输出的MV后缀
+var appleMusicVideoExts = []string{".mp4", ".m4v"}
+
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkAppleMusicVideo,
+		[]string{
+			"music.apple.com",
+		},
+		[]*regexp.Regexp{
+			// MV（music-video）
+			regexp.MustCompile(`^https?://music\.apple\.com/[a-z]{2}/music-video/(?:[A-Za-z0-9%._\-]+/)?\d+(?:\?.*)?$`),
+		},
+		func() processor.Processor { return &AppleMusicVideoProcessor{} },
+	).WithIdentifier(identifyAppleMusicVideo)
+}
+
+// identifyAppleMusicVideo 识别 Apple Music MV 链接
+func identifyAppleMusicVideo(link string) *processor.Resource {
+	m := appleMusicVideoRe.FindStringSubmatch(link)
+	if m == nil {
+		return nil
+	}
+	return processor.NewResource(processor.ResourceVideo, m[2], "https://music.apple.com/%s/music-video/%s", m[1], m[2])
+}
+
+/* ---------------------- 结构体与构造方法 ---------------------- */
+
+type AppleMusicVideoProcessor struct {
+	cfg     *config.Config
+	tempDir string
+	videos  []*VideoInfo
+}
+
+// Init  初始化
+func (p *AppleMusicVideoProcessor) Init(cfg *config.Config) {
+	p.cfg = cfg
+	p.videos = make([]*VideoInfo, 0)
+	p.tempDir = processor.BuildOutputDir(AppleMusicVideoTempDir)
+}
+
+/* ---------------------- 基础接口实现 ---------------------- */
+
+func (p *AppleMusicVideoProcessor) Name() processor.LinkType {
+	return processor.LinkAppleMusicVideo
+}
+
+func (p *AppleMusicVideoProcessor) Videos() []*VideoInfo {
+	return p.videos
+}
+
+/* ------------------------ 下载逻辑 ------------------------ */
+
+func (p *AppleMusicVideoProcessor) Download(ctx context.Context, link string, reporter ProgressReporter) error {
+	start := time.Now()
+	utils.InfoWithFormat("[AppleMusicVideo] 📺 开始下载: %s", link)
+
+	var id string
+	if res := identifyAppleMusicVideo(link); res != nil {
+		id = res.ID
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), id); ok {
+			core.LogLibraryHit("AppleMusicVideo", e)
+			return core.ErrInLibrary
+		}
+	}
+
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[AppleMusicVideo] ❌ 创建临时目录失败: %v", err)
+		return err
+	}
+
+	cmd := processor.GamdlCommand(ctx, p.cfg, p.tempDir, append(p.videoArgs(), link)...)
+	utils.DebugWithFormat("[AppleMusicVideo] 执行命令: %s", strings.Join(cmd.Args, " "))
+	tracker := processor.NewGamdlTracker(progressFunc(reporter))
+	logOut, err := processor.RunCommand(cmd, tracker.Feed)
+	if err != nil {
+		_ = processor.RemoveTempDir(p.tempDir)
+		if ctx.Err() != nil {
+			utils.InfoWithFormat("[AppleMusicVideo] 🚫 下载已取消: %s", link)
+			return ctx.Err()
+		}
+		utils.ErrorWithFormat("[AppleMusicVideo] ❌ 下载失败: %v\n输出:\n%s", err, logOut)
+		return fmt.Errorf("gamdl 下载失败: %w", err)
+	}
+	if len(tracker.Failures) > 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return fmt.Errorf("MV下载失败: %s", tracker.Failures[0])
+	}
+
+	p.videos, err = p.collectVideos(id)
+	if err != nil {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return err
+	}
+	utils.InfoWithFormat("[AppleMusicVideo] ✅ 下载完成: %d 个视频（耗时 %v）", len(p.videos), time.Since(start).Truncate(time.Millisecond))
+	progressFunc(reporter).Message(utils.PhaseDone, "下载完成: %d 个视频（耗时 %v）", len(p.videos), time.Since(start).Truncate(time.Millisecond))
+	return nil
+}
+
+/* ------------------------ 拓展方法 ------------------------ */
+
+func (p *AppleMusicVideoProcessor) Tidy(ctx context.Context) error {
+	// 跳过曲库中已存在的视频
+	p.videos = SkipLibraryDuplicates(p.Name(), p.videos)
+	if len(p.videos) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return core.ErrInLibrary
+	}
+	return TidyVideoDir(ctx, "AppleMusicVideo", p, p.tempDir, p.cfg)
+}
+
+// videoArgs MV编码、分辨率与封装格式, 沿用视频下载配置
+func (p *AppleMusicVideoProcessor) videoArgs() []string {
+	vc := p.cfg.Video
+	args := []string{"--music-video-remux-format", "mp4"}
+	if codec, ok := gamdlVideoCodecs[strings.ToLower(vc.Codec)]; ok {
+		args = append(args, "--music-video-codec-priority", codec)
+	}
+	if vc.MaxHeight > 0 {
+		args = append(args, "--music-video-resolution", fmt.Sprintf("%dp", vc.MaxHeight))
+	}
+	return args
+}
+
+// collectVideos 读取临时目录中的MV
+func (p *AppleMusicVideoProcessor) collectVideos(id string) ([]*VideoInfo, error) {
+	files, err := os.ReadDir(p.tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取临时目录失败: %w", err)
+	}
+	videos := make([]*VideoInfo, 0, 1)
+	for _, f := range files {
+		if f.IsDir() || !utils.Contains(appleMusicVideoExts, strings.ToLower(filepath.Ext(f.Name()))) {
+			continue
+		}
+		v := &VideoInfo{
+			VideoID:   id,
+			Title:     strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())),
+			Tidy:      processor.DetermineTidyType(p.cfg),
+			VideoPath: filepath.Join(p.tempDir, f.Name()),
+		}
+		if info, err := f.Info(); err == nil {
+			v.Size = utils.FormatBytes(info.Size())
+		}
+		videos = append(videos, v)
+	}
+	if len(videos) == 0 {
+		return nil, errors.New("未找到下载的MV文件")
+	}
+	return videos, nil
+}
@@ -3,22 +3,76 @@ package video
 // bilibili下载
 
 import (
-	"errors"
-	"fmt"
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/nichuanfang/gymdl/config"
 	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
 	"github.com/nichuanfang/gymdl/utils"
-	"os"
-	"path/filepath"
 )
 
+// bilibiliVideoRe 视频ID(BV号/av号)
+var bilibiliVideoRe = regexp.MustCompile(`/video/((?:BV|bv)[0-9A-Za-z]{10}|av\d+)`)
+
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkBilibili,
+		[]string{
+			"www.bilibili.com",
+			"m.bilibili.com",
+			"bilibili.com",
+			"space.bilibili.com",
+			"b23.tv",
+		},
+		[]*regexp.Regexp{
+			// 视频(BV号/av号), 支持 ?p= 分P
+			regexp.MustCompile(`^https?://(?:www\.|m\.)?bilibili\.com/video/(?:BV[0-9A-Za-z]{10}|av\d+)/?(?:\?.*)?$`),
+
+			// 合集 / 系列 / 收藏夹
+			regexp.MustCompile(`^https?://space\.bilibili\.com/\d+/(?:channel/(?:collectiondetail|seriesdetail)|lists/\d+|favlist)(?:\?.*)?$`),
+			regexp.MustCompile(`^https?://(?:www\.)?bilibili\.com/(?:list/|medialist/detail/ml)\w+(?:\?.*)?$`),
+
+			// 番剧 / 课程
+			regexp.MustCompile(`^https?://(?:www\.|m\.)?bilibili\.com/(?:bangumi/play|cheese/play)/(?:ep|ss)\d+(?:\?.*)?$`),
+
+			// 短链接
+			regexp.MustCompile(`^https?://b23\.tv/[\w-]+/?$`),
+		},
+		func() processor.Processor { return &BiliBiliProcessor{} },
+	).WithIdentifier(identifyBilibili)
+}
+
+// bilibiliListRe 合集/系列/收藏夹/番剧/课程
+var bilibiliListRe = regexp.MustCompile(`(?:space\.bilibili\.com/\d+/(?:channel/(?:collectiondetail|seriesdetail)\?[^#]*|lists/\d+[^#]*|favlist[^#]*)|bilibili\.com/(?:list/|medialist/detail/ml)\w+|bilibili\.com/(?:bangumi|cheese)/play/(?:ep|ss)\d+)`)
+
+// identifyBilibili 识别B站链接, 视频ID与曲库中的一致(多P为 BV号_p序号), b23.tv 短链需还原后识别
+func identifyBilibili(link string) *processor.Resource {
+	p := &BiliBiliProcessor{}
+	if m := bilibiliVideoRe.FindStringSubmatch(link); m != nil {
+		id := p.videoID(link)
+		if page := p.part(link); page != "" {
+			return processor.NewResource(processor.ResourceVideo, id, "https://www.bilibili.com/video/%s?p=%s", m[1], page)
+		}
+		return processor.NewResource(processor.ResourceVideo, id, "https://www.bilibili.com/video/%s", m[1])
+	}
+	if m := bilibiliListRe.FindString(link); m != "" {
+		return processor.NewResource(processor.ResourcePlaylist, m, "https://%s", strings.TrimPrefix(m, "www."))
+	}
+	return nil
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type BiliBiliProcessor struct {
 	cfg     *config.Config
 	tempDir string
 	videos  []*VideoInfo
+	tracker *processor.YtDlpTracker
 }
 
 // Init  初始化
@@ -26,6 +80,7 @@ func (p *BiliBiliProcessor) Init(cfg *config.Config) {
 	p.cfg = cfg
 	p.videos = make([]*VideoInfo, 0)
 	p.tempDir = processor.BuildOutputDir(BilibiliTempDir)
+	p.tracker = nil
 }
 
 /* ---------------------- 基础接口实现 ---------------------- */
@@ -40,85 +95,92 @@ func (p *BiliBiliProcessor) Videos() []*VideoInfo {
 
 /* ------------------------ 下载逻辑 ------------------------ */
 
-func (p *BiliBiliProcessor) Download(url string, callback func(progress string)) error {
-	utils.Debugf("开始下载视频:%s", url)
-	return nil
-}
-
-/* ------------------------ 拓展方法 ------------------------ */
+func (p *BiliBiliProcessor) Download(ctx context.Context, link string, reporter ProgressReporter) error {
+	start := time.Now()
+	utils.InfoWithFormat("[Bilibili] 📺 开始下载: %s", link)
 
-func (p *BiliBiliProcessor) Tidy() error {
-	files, err := os.ReadDir(p.tempDir)
-	if err != nil {
-		return fmt.Errorf("读取临时目录失败: %w", err)
+	// b23.tv 短链先还原,以便识别分P参数
+	if strings.Contains(link, "b23.tv") {
+		link = resolveRedirect(ctx, link)
 	}
-	if len(files) == 0 {
-		utils.WarnWithFormat("[DouYinVideo] ⚠️ 未找到待整理的资源文件")
-		return errors.New("未找到待整理的资源文件")
+
+	// 单P视频的ID即BV号,多P视频的ID为 BV号_p序号
+	if id := p.videoID(link); id != "" {
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), id); ok {
+			core.LogLibraryHit("Bilibili", e)
+			return core.ErrInLibrary
+		}
 	}
 
-	switch p.cfg.Tidy.Mode {
-	case 1:
-		return p.tidyToLocal(files)
-	case 2:
-		return p.tidyToWebDAV(files, core.GlobalWebDAV)
-	default:
-		return fmt.Errorf("未知整理模式: %d", p.cfg.Tidy.Mode)
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[Bilibili] ❌ 创建临时目录失败: %v", err)
+		return err
 	}
-}
 
-// 整理到本地
-func (p *BiliBiliProcessor) tidyToLocal(files []os.DirEntry) error {
-	dstDir := p.cfg.Tidy.DistDir
-	if dstDir == "" {
-		_ = processor.RemoveTempDir(p.tempDir)
-		return errors.New("未配置输出目录")
+	var subLangs []string
+	if p.cfg.Video.Subtitles {
+		subLangs = append(subLangs, p.cfg.Video.SubLangs)
 	}
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		_ = processor.RemoveTempDir(p.tempDir)
-		return fmt.Errorf("创建输出目录失败: %w", err)
+	// 弹幕以字幕语言 danmaku 的形式提供(xml)
+	if p.cfg.Video.Danmaku {
+		subLangs = append(subLangs, "danmaku")
 	}
+	args := ytDlpVideoArgs(p.cfg, p.tempDir, subLangs)
+	if p.part(link) != "" {
+		// 指定分P时仅下载该分P
+		args = append(args, "--no-playlist")
+	} else {
+		// 多P视频/合集/收藏夹下载全部
+		args = append(args, "--yes-playlist")
+	}
+	args = append(args, link)
 
-	for _, f := range files {
-		src := filepath.Join(p.tempDir, f.Name())
-		dst := filepath.Join(dstDir, utils.SanitizeFileName(f.Name()))
-		if err := os.Rename(src, dst); err != nil {
-			utils.WarnWithFormat("[DouYinVideo] ⚠️ 移动失败 %s → %s: %v", src, dst, err)
-			continue
-		}
-		utils.InfoWithFormat("[DouYinVideo] 📦 已整理: %s", dst)
+	tracker, err := runYtDlp(ctx, "Bilibili", ytDlpCommand(ctx, args), p.tempDir, reporter)
+	if err != nil {
+		return err
 	}
-	//清除临时目录
-	err := processor.RemoveTempDir(p.tempDir)
+	p.tracker = tracker
+
+	p.videos, err = collectYtDlpVideos(p.tempDir, p.tracker, processor.DetermineTidyType(p.cfg))
 	if err != nil {
-		utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除临时目录失败: %s (%v)", p.tempDir, err)
+		_ = processor.RemoveTempDir(p.tempDir)
 		return err
 	}
-	utils.DebugWithFormat("[DouYinVideo] 🧹 已删除临时目录: %s", p.tempDir)
+	utils.InfoWithFormat("[Bilibili] ✅ 下载完成: %d 个视频（耗时 %v）", len(p.videos), time.Since(start).Truncate(time.Millisecond))
+	progressFunc(reporter).Message(utils.PhaseDone, "下载完成: %d 个视频（耗时 %v）", len(p.videos), time.Since(start).Truncate(time.Millisecond))
 	return nil
 }
 
-// 整理到webdav
-func (p *BiliBiliProcessor) tidyToWebDAV(files []os.DirEntry, webdav *core.WebDAV) error {
-	if webdav == nil {
+/* ------------------------ 拓展方法 ------------------------ */
+
+func (p *BiliBiliProcessor) Tidy(ctx context.Context) error {
+	// 跳过曲库中已存在的视频
+	p.videos = SkipLibraryDuplicates(p.Name(), p.videos)
+	if len(p.videos) == 0 {
 		_ = processor.RemoveTempDir(p.tempDir)
-		return errors.New("WebDAV 未初始化")
+		return core.ErrInLibrary
 	}
+	return TidyVideoDir(ctx, "Bilibili", p, p.tempDir, p.cfg)
+}
 
-	for _, f := range files {
-		filePath := filepath.Join(p.tempDir, f.Name())
-		if err := webdav.Upload(filePath); err != nil {
-			utils.WarnWithFormat("[DouYinVideo] ☁️ 上传失败 %s: %v", f.Name(), err)
-			continue
-		}
-		utils.InfoWithFormat("[DouYinVideo] ☁️ 已上传: %s", f.Name())
+// videoID 视频链接对应的 yt-dlp 视频ID
+func (p *BiliBiliProcessor) videoID(link string) string {
+	m := bilibiliVideoRe.FindStringSubmatch(link)
+	if m == nil {
+		return ""
+	}
+	if page := p.part(link); page != "" && page != "1" {
+		return m[1] + "_p" + page
 	}
-	//清除临时目录
-	err := processor.RemoveTempDir(p.tempDir)
+	return m[1]
+}
+
+// part 链接中的分P序号
+func (p *BiliBiliProcessor) part(link string) string {
+	u, err := url.Parse(link)
 	if err != nil {
-		utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除临时目录失败: %s (%v)", p.tempDir, err)
-		return err
+		return ""
 	}
-	utils.DebugWithFormat("[DouYinVideo] 🧹 已删除临时目录: %s", p.tempDir)
-	return nil
+	return u.Query().Get("p")
 }
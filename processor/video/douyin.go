@@ -1,6 +1,7 @@
 package video
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,7 +9,6 @@ import (
 	"math/rand"
 	"net/http"
 	"net/url"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
@@ -36,6 +36,53 @@ const (
 // Platform 表示平台类型
 type Platform string
 
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkDouyin,
+		[]string{
+			"www.douyin.com",
+			"v.douyin.com",
+			"www.iesdouyin.com",
+		},
+		[]*regexp.Regexp{
+			// 正常视频链接
+			regexp.MustCompile(`https?://www\.douyin\.com/video/[\w-]+`),
+			// 图文作品
+			regexp.MustCompile(`https?://www\.douyin\.com/note/\d+`),
+			// 用户主页 / 合集
+			regexp.MustCompile(`https?://www\.douyin\.com/(?:user/[\w-]+|collection/\d+)`),
+			// 分享页
+			regexp.MustCompile(`https?://www\.iesdouyin\.com/share/(?:video|note|slides|user|mix/detail)/[\w-]+`),
+			// 短链接形式
+			regexp.MustCompile(`https?://v\.douyin\.com/[\w-]+/?`),
+		},
+		func() processor.Processor { return &DouYinProcessor{} },
+	).WithIdentifier(identifyDouyin)
+}
+
+// douyinVideoRe 视频作品ID(网页/分享页)
+var douyinVideoRe = regexp.MustCompile(`/(?:share/)?video/(\d+)`)
+
+// identifyDouyin 识别抖音链接, v.douyin.com 短链需还原后识别
+func identifyDouyin(link string) *processor.Resource {
+	switch {
+	case douyinVideoRe.MatchString(link):
+		id := douyinVideoRe.FindStringSubmatch(link)[1]
+		return processor.NewResource(processor.ResourceVideo, id, "https://www.douyin.com/video/%s", id)
+	case douyinNoteRe.MatchString(link):
+		id := douyinNoteRe.FindStringSubmatch(link)[1]
+		return processor.NewResource(processor.ResourceVideo, id, "https://www.douyin.com/note/%s", id)
+	case douyinMixRe.MatchString(link):
+		id := douyinMixRe.FindStringSubmatch(link)[1]
+		return processor.NewResource(processor.ResourcePlaylist, id, "https://www.douyin.com/collection/%s", id)
+	case douyinUserRe.MatchString(link):
+		id := douyinUserRe.FindStringSubmatch(link)[1]
+		return processor.NewResource(processor.ResourceUser, id, "https://www.douyin.com/user/%s", id)
+	}
+	return nil
+}
+
 // DouYinProcessor 抖音视频处理器，实现视频下载功能
 type DouYinProcessor struct {
 	cfg       *config.Config
@@ -43,6 +90,7 @@ type DouYinProcessor struct {
 	videos    []*VideoInfo
 	videoInfo *VideoInfo
 	reporter  ProgressReporter // 进度报告器作为结构体字段
+	ctx       context.Context  // 任务上下文,取消时中止下载
 }
 
 // Init 初始化抖音处理器
@@ -64,20 +112,57 @@ func (p *DouYinProcessor) Videos() []*VideoInfo {
 }
 
 // Download 下载抖音视频
-func (p *DouYinProcessor) Download(link string, reporter ProgressReporter) error {
+func (p *DouYinProcessor) Download(ctx context.Context, link string, reporter ProgressReporter) error {
 	// 保存reporter到结构体字段
 	p.reporter = reporter
+	p.ctx = ctx
+
+	// 短链先还原,以便识别作品类型
+	target := link
+	if strings.Contains(link, "v.douyin.com") {
+		target = resolveRedirect(ctx, link)
+	}
+	// 图文作品/用户主页/合集走网页接口
+	var handled func(string) error
+	switch {
+	case douyinNoteRe.MatchString(target):
+		handled = p.downloadNote
+	case douyinMixRe.MatchString(target), douyinUserRe.MatchString(target):
+		handled = p.downloadList
+	}
+	if handled != nil {
+		if err := handled(target); err != nil {
+			if ctx.Err() != nil {
+				return p.canceled()
+			}
+			return err
+		}
+		return nil
+	}
+
 	err := p.method1(link)
 	//err := errors.New("method1 error")
 	if err != nil {
+		if ctx.Err() != nil {
+			return p.canceled()
+		}
+		if errors.Is(err, core.ErrInLibrary) {
+			return err
+		}
 		utils.InfoWithFormat("method1下载失败，尝试使用method2: %v", err)
 		// 当method1失败时，尝试使用method2
 		err2 := p.method2(link)
 		//err2 := errors.New("method2 error")
 		if err2 != nil {
+			if ctx.Err() != nil {
+				return p.canceled()
+			}
 			utils.InfoWithFormat("method2下载失败，尝试使用method3: %v", err2)
 			// 当method2也失败时，尝试使用method3
 			if err3 := p.method3(link); err3 != nil {
+				if ctx.Err() != nil {
+					return p.canceled()
+				}
 				return fmt.Errorf("所有下载方法都失败: method1错误: %v, method2错误: %v, method3错误: %v", err, err2, err3)
 			}
 		}
@@ -86,6 +171,22 @@ func (p *DouYinProcessor) Download(link string, reporter ProgressReporter) error
 	return nil
 }
 
+// canceled 任务取消后清理临时目录
+func (p *DouYinProcessor) canceled() error {
+	_ = processor.RemoveTempDir(p.tempDir)
+	utils.InfoWithFormat("[DouYinVideo] 🚫 下载已取消")
+	return p.ctx.Err()
+}
+
+// apiGet 发送可取消的GET请求
+func (p *DouYinProcessor) apiGet(apiURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(p.ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return http.DefaultClient.Do(req)
+}
+
 // method3 使用wzapi接口下载抖音视频
 func (p *DouYinProcessor) method3(link string) error {
 	// 构建API URL
@@ -94,7 +195,7 @@ func (p *DouYinProcessor) method3(link string) error {
 	utils.InfoWithFormat("调用method3 API: %s", apiURL)
 
 	// 发送HTTP GET请求
-	resp, err := http.Get(apiURL)
+	resp, err := p.apiGet(apiURL)
 	if err != nil {
 		return fmt.Errorf("调用API失败: %v", err)
 	}
@@ -175,7 +276,7 @@ func (p *DouYinProcessor) method2(link string) error {
 	utils.InfoWithFormat("调用method2 API: %s", apiURL)
 
 	// 发送HTTP GET请求
-	resp, err := http.Get(apiURL)
+	resp, err := p.apiGet(apiURL)
 	if err != nil {
 		return fmt.Errorf("调用API失败: %v", err)
 	}
@@ -243,13 +344,18 @@ func (p *DouYinProcessor) method2(link string) error {
 
 func (p *DouYinProcessor) method1(link string) error {
 	// 初始化 Playwright 和浏览器
-	ctx, page, pw, err := p.initPlaywrightAndBrowser()
+	browserCtx, page, pw, err := p.initPlaywrightAndBrowser()
 	if err != nil {
 		return err
 	}
+	// 任务取消时关闭浏览器上下文,中断页面加载
+	stop := context.AfterFunc(p.ctx, func() {
+		browserCtx.Close()
+	})
 	defer func() {
+		stop()
 		page.Close()
-		ctx.Close()
+		browserCtx.Close()
 		pw.Stop()
 	}()
 	//创建通道用于接收API响应数据
@@ -282,12 +388,17 @@ func (p *DouYinProcessor) method1(link string) error {
 		return err
 	}
 	utils.InfoWithFormat("提取视频ID成功: %s", videoID)
+	if e, ok := core.GlobalLibrary.Has(string(p.Name()), videoID); ok {
+		core.LogLibraryHit("DouYinVideo", e)
+		return core.ErrInLibrary
+	}
 	// 提取视频内容和URL
 	html, err := page.Content()
 	if err != nil {
 		return fmt.Errorf("获取页面内容失败: %v", err)
 	}
 	err = p.extractDataFromHTML(html)
+	p.videoInfo.VideoID = videoID
 	// 保存视频信息，当前只获取一个视频，所以直接保存
 	p.videos = append(p.videos, p.videoInfo)
 	if err != nil {
@@ -845,171 +956,21 @@ func (p *DouYinProcessor) downloadVideo() error {
 	return nil
 }
 
+// _downloadResource 下载单个资源到临时目录,进度回调给 reporter
 func (p *DouYinProcessor) _downloadResource(url, savePath, filename string) (string, error) {
-	// 确保保存目录存在
-	if err := os.MkdirAll(savePath, 0755); err != nil {
-		return "", fmt.Errorf("创建保存目录失败: %w", err)
-	}
-
-	// 正确设置下载选项
-	downloader, err := utils.NewDownloader(url, &utils.DownloadOptions{
-		SavePath:   savePath,
-		FileName:   filename,
-		Timeout:    1200 * time.Second, // 正确设置为time.Duration类型
-		IgnoreSSL:  true,
-		MaxRetries: 3,                // 增加重试次数
-		ChunkSize:  10 * 1024 * 1024, // 正确设置为10MB字节数
-	})
-
-	// 启动下载
-	if err = downloader.Start(); err != nil {
-		utils.ErrorWithFormat("[downloader] 启动下载失败: %v", err)
-		return "", err
-	}
-
-	// 等待下载完成
-	for {
-		progress := downloader.GetProgress()
-		if progress.Status == utils.StatusCompleted || progress.Status == utils.StatusFailed {
-			if progress.Status == utils.StatusCompleted {
-				// 返回格式化的文件大小
-				return progress.FormattedSize, nil
-			} else {
-				// 返回具体的错误信息
-				return "", fmt.Errorf("[downloader] 下载失败: %s, 错误: %s", url, progress.ErrorMessage)
-			}
-		}
-		// 修正进度显示格式，使用Progress而不是FormattedSpeed
-		progressText := fmt.Sprintf("正在下载:\n文件名%s：\n进度: %.2f%%\n速度: %s\n已下载: %s/%s",
-			filename,
-			progress.Progress,
-			progress.FormattedSpeed,
-			progress.FormattedDownloaded, progress.FormattedSize)
-		if p.reporter != nil {
-			p.reporter.ReportProgress(progressText)
-		}
-		time.Sleep(1 * time.Second) // 减少轮询间隔
-	}
+	return downloadResource(p.ctx, url, savePath, filename, p.reporter)
 }
 
-func (p *DouYinProcessor) Tidy() error {
+func (p *DouYinProcessor) Tidy(ctx context.Context) error {
 	if len(p.videos) == 0 {
 		utils.WarnWithFormat("[DouYinVideo] ⚠️ 未找到待整理的视频信息")
 		return errors.New("未找到待整理的视频信息")
 	}
-
-	switch p.cfg.Tidy.Mode {
-	case 1:
-		return p.tidyToLocal()
-	case 2:
-		return p.tidyToWebDAV(core.GlobalWebDAV)
-	default:
-		return fmt.Errorf("未知整理模式: %d", p.cfg.Tidy.Mode)
-	}
-}
-
-// 整理到本地
-func (p *DouYinProcessor) tidyToLocal() error {
-	dstDir := p.cfg.Tidy.DistDir
-	if dstDir == "" {
-		return errors.New("未配置输出目录")
-	}
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		return fmt.Errorf("创建输出目录失败: %w", err)
-	}
-
-	mvDir := filepath.Join(dstDir, "douyin")
-	if err := os.MkdirAll(mvDir, 0755); err != nil {
-		return fmt.Errorf("创建目录失败: %w", err)
-	}
-
-	// 只处理p.videos中记录的文件
-	for _, videoInfo := range p.videos {
-		// 移动视频文件
-		if videoInfo.VideoPath != "" {
-			dst := filepath.Join(mvDir, utils.SanitizeFileName(filepath.Base(videoInfo.VideoPath)))
-			if err := os.Rename(videoInfo.VideoPath, dst); err != nil {
-				utils.WarnWithFormat("[DouYinVideo] ⚠️ 视频移动失败 %s → %s: %v", videoInfo.VideoPath, dst, err)
-			} else {
-				utils.InfoWithFormat("[DouYinVideo] 📦 已整理视频: %s", dst)
-				// 移动成功后，删除原位置的临时文件（如果还存在）
-				if err := os.Remove(videoInfo.VideoPath); err != nil && !os.IsNotExist(err) {
-					utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除视频临时文件失败: %s (%v)", videoInfo.VideoPath, err)
-				} else if err == nil {
-					utils.DebugWithFormat("[DouYinVideo] 🧹 已删除视频临时文件: %s", videoInfo.VideoPath)
-				}
-				videoInfo.VideoPath = dst // 更新路径为新的位置
-			}
-		}
-
-		// 移动封面文件
-		if videoInfo.CoverPath != "" {
-			dst := filepath.Join(mvDir, utils.SanitizeFileName(filepath.Base(videoInfo.CoverPath)))
-			if err := os.Rename(videoInfo.CoverPath, dst); err != nil {
-				utils.WarnWithFormat("[DouYinVideo] ⚠️ 封面移动失败 %s → %s: %v", videoInfo.CoverPath, dst, err)
-			} else {
-				utils.InfoWithFormat("[DouYinVideo] 📦 已整理封面: %s", dst)
-				// 移动成功后，删除原位置的临时文件（如果还存在）
-				if err := os.Remove(videoInfo.CoverPath); err != nil && !os.IsNotExist(err) {
-					utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除封面临时文件失败: %s (%v)", videoInfo.CoverPath, err)
-				} else if err == nil {
-					utils.DebugWithFormat("[DouYinVideo] 🧹 已删除封面临时文件: %s", videoInfo.CoverPath)
-				}
-				videoInfo.CoverPath = dst // 更新路径为新的位置
-			}
-		}
-	}
-
-	return nil
-}
-
-// 整理到webdav
-func (p *DouYinProcessor) tidyToWebDAV(webdav *core.WebDAV) error {
-	if webdav == nil {
-		return errors.New("WebDAV 未初始化")
-	}
-
-	// 只处理p.videos中记录的文件
-	for _, videoInfo := range p.videos {
-		// 上传视频文件
-		if videoInfo.VideoPath != "" {
-			if err := webdav.Upload(videoInfo.VideoPath); err != nil {
-				utils.WarnWithFormat("[DouYinVideo] ☁️ 视频上传失败 %s: %v", filepath.Base(videoInfo.VideoPath), err)
-			} else {
-				utils.InfoWithFormat("[DouYinVideo] ☁️ 已上传视频: %s", filepath.Base(videoInfo.VideoPath))
-			}
-		}
-
-		// 上传封面文件
-		if videoInfo.CoverPath != "" {
-			if err := webdav.Upload(videoInfo.CoverPath); err != nil {
-				utils.WarnWithFormat("[DouYinVideo] ☁️ 封面上传失败 %s: %v", filepath.Base(videoInfo.CoverPath), err)
-			} else {
-				utils.InfoWithFormat("[DouYinVideo] ☁️ 已上传封面: %s", filepath.Base(videoInfo.CoverPath))
-			}
-		}
+	// 跳过曲库中已存在的视频
+	if p.videos = SkipLibraryDuplicates(p.Name(), p.videos); len(p.videos) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return core.ErrInLibrary
 	}
 
-	// 上传完成后，删除对应的临时文件
-	for _, videoInfo := range p.videos {
-		// 删除视频临时文件
-		if videoInfo.VideoPath != "" {
-			if err := os.Remove(videoInfo.VideoPath); err != nil && !os.IsNotExist(err) {
-				utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除视频临时文件失败: %s (%v)", videoInfo.VideoPath, err)
-			} else if err == nil {
-				utils.DebugWithFormat("[DouYinVideo] 🧹 已删除视频临时文件: %s", videoInfo.VideoPath)
-			}
-		}
-
-		// 删除封面临时文件
-		if videoInfo.CoverPath != "" {
-			if err := os.Remove(videoInfo.CoverPath); err != nil && !os.IsNotExist(err) {
-				utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除封面临时文件失败: %s (%v)", videoInfo.CoverPath, err)
-			} else if err == nil {
-				utils.DebugWithFormat("[DouYinVideo] 🧹 已删除封面临时文件: %s", videoInfo.CoverPath)
-			}
-		}
-	}
-
-	return nil
+	return TidyVideoDir(ctx, "DouYinVideo", p, p.tempDir, p.cfg)
 }
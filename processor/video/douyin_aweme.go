@@ -0,0 +1,484 @@
+package video
+
+// 抖音图文作品、用户主页与合集下载
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nichuanfang/gymdl/core"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+	"github.com/playwright-community/playwright-go"
+	"github.com/withsawyer/gopher-tools/datetime"
+)
+
+var (
+	// douyinNoteRe 图文作品ID
+	douyinNoteRe = regexp.MustCompile(`/(?:share/)?(?:note|slides)/(\d+)`)
+	// douyinUserRe 用户主页 sec_uid
+	douyinUserRe = regexp.MustCompile(`/(?:share/)?user/([\w-]+)`)
+	// douyinMixRe 合集ID
+	douyinMixRe = regexp.MustCompile(`/(?:collection|(?:share/)?mix/detail)/(\d+)`)
+	// douyinRouterRe 页面中的路由数据(分享页作品详情)
+	douyinRouterRe = regexp.MustCompile(`(?s)window\._ROUTER_DATA\s*=\s*(\{.*?\})\s*</script>`)
+)
+
+// douyinDetailAPIs 作品详情接口
+var douyinDetailAPIs = []string{"/aweme/detail/", "/aweme/iteminfo/"}
+
+// douyinListAPIs 主页/合集翻页接口
+var douyinListAPIs = []string{"/aweme/post/", "/mix/aweme/", "/mix/item/list/"}
+
+// douyinMaxStalls 连续翻页无新作品的次数上限
+const douyinMaxStalls = 3
+
+type douyinURLs struct {
+	URLList []string `json:"url_list"`
+}
+
+// douyinAweme 作品详情(网页接口与分享页路由数据结构一致)
+type douyinAweme struct {
+	AwemeID    string `json:"aweme_id"`
+	Desc       string `json:"desc"`
+	CreateTime int64  `json:"create_time"`
+	Author     struct {
+		Nickname string `json:"nickname"`
+	} `json:"author"`
+	Video struct {
+		PlayAddr douyinURLs `json:"play_addr"`
+		Cover    douyinURLs `json:"cover"`
+		Width    int        `json:"width"`
+		Height   int        `json:"height"`
+	} `json:"video"`
+	// 图集图片, 视频作品为空
+	Images []struct {
+		URLList []string `json:"url_list"`
+		// 实况照片
+		Video *struct {
+			PlayAddr douyinURLs `json:"play_addr"`
+		} `json:"video"`
+	} `json:"images"`
+	// 背景音乐
+	Music *struct {
+		Title   string     `json:"title"`
+		PlayURL douyinURLs `json:"play_url"`
+	} `json:"music"`
+}
+
+// douyinAwemePage 接口响应: 列表接口返回 aweme_list, 详情接口返回 aweme_detail/item_list
+type douyinAwemePage struct {
+	AwemeList   []*douyinAweme `json:"aweme_list"`
+	AwemeDetail *douyinAweme   `json:"aweme_detail"`
+	ItemList    []*douyinAweme `json:"item_list"`
+	HasMore     any            `json:"has_more"` // 不同接口分别为 bool/int
+}
+
+/* ------------------------ 图文作品 ------------------------ */
+
+// downloadNote 下载单个图文作品(全部图片、实况照片及背景音乐)
+func (p *DouYinProcessor) downloadNote(link string) error {
+	id := douyinNoteRe.FindStringSubmatch(link)[1]
+	if e, ok := core.GlobalLibrary.Has(string(p.Name()), id); ok {
+		core.LogLibraryHit("DouYinVideo", e)
+		return core.ErrInLibrary
+	}
+
+	awemes, err := p.captureAwemes("https://www.douyin.com/note/"+id, douyinDetailAPIs, 1)
+	if err != nil {
+		return err
+	}
+	var aweme *douyinAweme
+	for _, a := range awemes {
+		if a.AwemeID == id {
+			aweme = a
+			break
+		}
+	}
+	if aweme == nil {
+		return fmt.Errorf("未获取到作品详情: %s", id)
+	}
+
+	if err = processor.CreateOutputDir(p.tempDir); err != nil {
+		return err
+	}
+	v, err := p.downloadAweme(aweme)
+	if err != nil {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return err
+	}
+	p.videos = append(p.videos, v)
+	return nil
+}
+
+/* ------------------------ 主页/合集 ------------------------ */
+
+// downloadList 翻页获取用户主页或合集中的作品并逐个下载, 数量受 douyin.max_items 限制
+func (p *DouYinProcessor) downloadList(link string) error {
+	start := time.Now()
+	var pageURL, kind string
+	if m := douyinMixRe.FindStringSubmatch(link); m != nil {
+		pageURL, kind = "https://www.douyin.com/collection/"+m[1], "合集"
+	} else {
+		pageURL, kind = "https://www.douyin.com/user/"+douyinUserRe.FindStringSubmatch(link)[1], "主页"
+	}
+	utils.InfoWithFormat("[DouYinVideo] 📃 获取%s作品列表: %s", kind, pageURL)
+
+	awemes, err := p.captureAwemes(pageURL, douyinListAPIs, p.cfg.Douyin.MaxItems)
+	if err != nil {
+		return err
+	}
+	if len(awemes) == 0 {
+		return fmt.Errorf("未获取到%s作品列表", kind)
+	}
+	utils.InfoWithFormat("[DouYinVideo] 共获取 %d 个作品", len(awemes))
+
+	if err = processor.CreateOutputDir(p.tempDir); err != nil {
+		return err
+	}
+	skipped, failed := 0, 0
+	for i, a := range awemes {
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), a.AwemeID); ok {
+			core.LogLibraryHit("DouYinVideo", e)
+			skipped++
+			continue
+		}
+		// 作品之间间隔下载, 避免触发风控
+		if i > 0 {
+			if err = p.wait(); err != nil {
+				return err
+			}
+		}
+		progressFunc(p.reporter).Emit(utils.Progress{Phase: utils.PhaseDownload, Current: i + 1, Total: len(awemes), Item: a.Desc, Message: "正在下载作品"})
+		v, err := p.downloadAweme(a)
+		if err != nil {
+			if p.ctx.Err() != nil {
+				return p.ctx.Err()
+			}
+			utils.WarnWithFormat("[DouYinVideo] ⚠️ 作品下载失败 %s: %v", a.AwemeID, err)
+			failed++
+			continue
+		}
+		p.videos = append(p.videos, v)
+	}
+
+	if len(p.videos) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		if failed == 0 && skipped > 0 {
+			return core.ErrInLibrary
+		}
+		return fmt.Errorf("%s作品全部下载失败", kind)
+	}
+	utils.InfoWithFormat("[DouYinVideo] ✅ %s下载完成: 成功 %d, 已入库 %d, 失败 %d（耗时 %v）",
+		kind, len(p.videos), skipped, failed, time.Since(start).Truncate(time.Millisecond))
+	return nil
+}
+
+// wait 按配置的间隔等待, 任务取消时提前返回
+func (p *DouYinProcessor) wait() error {
+	select {
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case <-time.After(time.Duration(p.cfg.Douyin.Interval) * time.Millisecond):
+		return nil
+	}
+}
+
+/* ------------------------ 作品数据采集 ------------------------ */
+
+// captureAwemes 打开页面并持续下滑翻页, 从接口响应与页面路由数据中收集作品,
+// 直到没有更多作品、达到数量上限(limit<=0 表示不限制)或连续多次翻页无新作品
+func (p *DouYinProcessor) captureAwemes(pageURL string, apis []string, limit int) ([]*douyinAweme, error) {
+	browserCtx, page, pw, err := p.initPlaywrightAndBrowser()
+	if err != nil {
+		return nil, err
+	}
+	// 任务取消时关闭浏览器上下文,中断页面加载
+	stop := context.AfterFunc(p.ctx, func() {
+		browserCtx.Close()
+	})
+	defer func() {
+		stop()
+		page.Close()
+		browserCtx.Close()
+		pw.Stop()
+	}()
+	// 主页作品列表需要登录态
+	if err = p.loadCookies(browserCtx); err != nil {
+		utils.WarnWithFormat("[DouYinVideo] ⚠️ 加载 cookies 失败: %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		seen    = make(map[string]bool)
+		awemes  = make([]*douyinAweme, 0)
+		hasMore = true
+	)
+	add := func(list ...*douyinAweme) {
+		for _, a := range list {
+			if a == nil || a.AwemeID == "" || seen[a.AwemeID] {
+				continue
+			}
+			seen[a.AwemeID] = true
+			awemes = append(awemes, a)
+		}
+	}
+	status := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(awemes), hasMore
+	}
+
+	page.On("response", func(response playwright.Response) {
+		if response.Status() != 200 || !containsAny(response.URL(), apis) {
+			return
+		}
+		var data douyinAwemePage
+		if err := response.JSON(&data); err != nil {
+			utils.DebugWithFormat("[DouYinVideo] 解析接口响应失败 %s: %v", response.URL(), err)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		add(data.AwemeList...)
+		add(data.ItemList...)
+		add(data.AwemeDetail)
+		if data.AwemeList != nil {
+			hasMore = isTruthy(data.HasMore)
+		}
+	})
+
+	if _, err = page.Goto(pageURL, playwright.PageGotoOptions{
+		WaitUntil: playwright.WaitUntilStateNetworkidle,
+		Timeout:   playwright.Float(60000),
+	}); err != nil {
+		if p.ctx.Err() != nil {
+			return nil, p.ctx.Err()
+		}
+		return nil, fmt.Errorf("访问页面失败: %v", err)
+	}
+	// 分享页的作品详情直接渲染在页面中
+	if html, err := page.Content(); err == nil {
+		mu.Lock()
+		add(parseDouyinRouterData(html)...)
+		mu.Unlock()
+	}
+
+	for stalls := 0; stalls < douyinMaxStalls; {
+		n, more := status()
+		if !more || (limit > 0 && n >= limit) {
+			break
+		}
+		progressFunc(p.reporter).Message(utils.PhaseResolve, "正在获取作品列表: 已获取 %d 个", n)
+		if _, err = page.Evaluate(`window.scrollTo(0, document.body.scrollHeight);`); err != nil {
+			utils.DebugWithFormat("[DouYinVideo] 页面滚动失败: %v", err)
+			break
+		}
+		if err = p.wait(); err != nil {
+			return nil, err
+		}
+		_ = page.WaitForLoadState(playwright.PageWaitForLoadStateOptions{
+			State: playwright.LoadStateNetworkidle,
+		})
+		if m, _ := status(); m == n {
+			stalls++
+		} else {
+			stalls = 0
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if limit > 0 && len(awemes) > limit {
+		awemes = awemes[:limit]
+	}
+	return awemes, nil
+}
+
+// parseDouyinRouterData 解析分享页 window._ROUTER_DATA 中的作品
+func parseDouyinRouterData(html string) []*douyinAweme {
+	m := douyinRouterRe.FindStringSubmatch(html)
+	if m == nil {
+		return nil
+	}
+	var data struct {
+		LoaderData map[string]json.RawMessage `json:"loaderData"`
+	}
+	if err := json.Unmarshal([]byte(m[1]), &data); err != nil {
+		utils.DebugWithFormat("[DouYinVideo] 解析路由数据失败: %v", err)
+		return nil
+	}
+	awemes := make([]*douyinAweme, 0)
+	for _, raw := range data.LoaderData {
+		var pageData struct {
+			VideoInfoRes struct {
+				ItemList []*douyinAweme `json:"item_list"`
+			} `json:"videoInfoRes"`
+		}
+		if err := json.Unmarshal(raw, &pageData); err == nil {
+			awemes = append(awemes, pageData.VideoInfoRes.ItemList...)
+		}
+	}
+	return awemes
+}
+
+/* ------------------------ 作品下载 ------------------------ */
+
+// downloadAweme 下载单个作品: 视频作品保存视频与封面, 图文作品保存全部图片、实况照片与背景音乐
+func (p *DouYinProcessor) downloadAweme(a *douyinAweme) (*VideoInfo, error) {
+	title := strings.Join(strings.Fields(a.Desc), " ")
+	base := a.AwemeID
+	if title != "" {
+		base = fmt.Sprintf("%s [%s]", utils.TruncateString(title, 60), a.AwemeID)
+	}
+	base = utils.SanitizeFileName(base)
+
+	v := &VideoInfo{
+		VideoID: a.AwemeID,
+		Title:   title,
+		Author:  a.Author.Nickname,
+		Desc:    a.Desc,
+		Tidy:    processor.DetermineTidyType(p.cfg),
+	}
+	if a.CreateTime > 0 {
+		v.Time = datetime.FormatTimeToStr(time.Unix(a.CreateTime, 0), "yyyy-mm-dd hh:mm:ss")
+	}
+
+	if len(a.Images) == 0 {
+		return v, p.downloadAwemeVideo(a, v, base)
+	}
+	return v, p.downloadAwemeImages(a, v, base)
+}
+
+// downloadAwemeVideo 下载视频作品及封面
+func (p *DouYinProcessor) downloadAwemeVideo(a *douyinAweme, v *VideoInfo, base string) error {
+	if len(a.Video.PlayAddr.URLList) == 0 {
+		return errors.New("未找到视频地址")
+	}
+	v.DownloadUrl = strings.Replace(a.Video.PlayAddr.URLList[0], "playwm", "play", 1)
+	if a.Video.Width > 0 && a.Video.Height > 0 {
+		v.Ratio = fmt.Sprintf("%dx%d", a.Video.Width, a.Video.Height)
+	}
+	size, err := p._downloadResource(v.DownloadUrl, p.tempDir, base+".mp4")
+	if err != nil {
+		return fmt.Errorf("视频下载失败: %w", err)
+	}
+	v.Size = size
+	v.VideoPath = filepath.Join(p.tempDir, base+".mp4")
+	utils.InfoWithFormat("[download] 视频下载完成: %s", v.VideoPath)
+
+	if len(a.Video.Cover.URLList) > 0 {
+		v.CoverUrl = a.Video.Cover.URLList[0]
+		if _, err = p._downloadResource(v.CoverUrl, p.tempDir, base+".jpeg"); err != nil {
+			// 封面下载失败不影响整体流程
+			utils.WarnWithFormat("[download] 封面下载失败: %v", err)
+		} else {
+			v.CoverPath = filepath.Join(p.tempDir, base+".jpeg")
+		}
+	}
+	return nil
+}
+
+// downloadAwemeImages 下载图集: 第一张图片作为主文件, 其余图片、实况照片与背景音乐作为附属文件
+func (p *DouYinProcessor) downloadAwemeImages(a *douyinAweme, v *VideoInfo, base string) error {
+	files := make([]string, 0, len(a.Images)+1)
+	for i, img := range a.Images {
+		imgURL, ext := douyinImageURL(img.URLList)
+		if imgURL == "" {
+			continue
+		}
+		fn := fmt.Sprintf("%s_%02d%s", base, i+1, ext)
+		if _, err := p._downloadResource(imgURL, p.tempDir, fn); err != nil {
+			if p.ctx.Err() != nil {
+				return p.ctx.Err()
+			}
+			utils.WarnWithFormat("[download] 图片下载失败 %s: %v", fn, err)
+			continue
+		}
+		files = append(files, filepath.Join(p.tempDir, fn))
+		if v.CoverUrl == "" {
+			v.CoverUrl = imgURL
+		}
+
+		// 实况照片
+		if img.Video != nil && len(img.Video.PlayAddr.URLList) > 0 {
+			fn = fmt.Sprintf("%s_%02d.mp4", base, i+1)
+			if _, err := p._downloadResource(img.Video.PlayAddr.URLList[0], p.tempDir, fn); err != nil {
+				utils.WarnWithFormat("[download] 实况照片下载失败 %s: %v", fn, err)
+			} else {
+				files = append(files, filepath.Join(p.tempDir, fn))
+			}
+		}
+	}
+	if len(files) == 0 {
+		return errors.New("图集图片下载失败")
+	}
+
+	// 背景音乐
+	if a.Music != nil && len(a.Music.PlayURL.URLList) > 0 {
+		fn := base + "_bgm.mp3"
+		if _, err := p._downloadResource(a.Music.PlayURL.URLList[0], p.tempDir, fn); err != nil {
+			utils.WarnWithFormat("[download] 背景音乐下载失败 %s: %v", a.Music.Title, err)
+		} else {
+			files = append(files, filepath.Join(p.tempDir, fn))
+		}
+	}
+
+	var total int64
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil {
+			total += info.Size()
+		}
+	}
+	v.Size = utils.FormatBytes(total)
+	v.VideoPath = files[0]
+	v.Files = files[1:]
+	utils.InfoWithFormat("[download] 图集下载完成: %s（%d 个文件）", base, len(files))
+	return nil
+}
+
+// douyinImageURL 选取图片地址, 优先 jpeg 格式, 返回地址及对应后缀
+func douyinImageURL(urls []string) (string, string) {
+	for _, u := range urls {
+		if strings.Contains(u, ".jpeg") {
+			return u, ".jpeg"
+		}
+	}
+	if len(urls) == 0 {
+		return "", ""
+	}
+	if strings.Contains(urls[0], ".webp") {
+		return urls[0], ".webp"
+	}
+	return urls[0], ".jpeg"
+}
+
+// containsAny s 是否包含任一子串
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTruthy 兼容 bool/数字 形式的布尔字段
+func isTruthy(v any) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case float64:
+		return b != 0
+	default:
+		return false
+	}
+}
@@ -1,30 +1,45 @@
 package video
 
 import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/storage"
+	"github.com/nichuanfang/gymdl/utils"
 )
 
 /* ---------------------- 视频处理接口定义 ---------------------- */
 
 // ProgressReporter 进度报告器接口
 type ProgressReporter interface {
-	ReportProgress(progress string)
+	ReportProgress(progress utils.Progress)
 }
 
 type Processor interface {
 	processor.Processor
 	// 视频元信息列表
 	Videos() []*VideoInfo
-	// 下载视频
-	Download(url string, reporter ProgressReporter) error
-	// 整理视频
-	Tidy() error
+	// 下载视频(ctx 取消时中止下载并清理临时目录)
+	Download(ctx context.Context, url string, reporter ProgressReporter) error
+	// 整理视频(ctx 取消时中止上传)
+	Tidy(ctx context.Context) error
 }
 
 /* ---------------------- 视频结构体定义 ---------------------- */
 type VideoInfo struct {
+	VideoID     string // 平台视频ID
 	Title       string
 	Author      string
 	Ratio       string
@@ -36,6 +51,9 @@ type VideoInfo struct {
 	Tidy        string // 入库方式(默认/webdav)
 	VideoPath   string
 	CoverPath   string
+	Files       []string // 附属文件(图集图片/实况照片/背景音乐等)
+	Hash        string   // 文件哈希(曲库去重)
+	Stored      bool     // 全部文件已整理到存储后端(同名文件已存在而跳过也算), 整理成功的视频才入库
 }
 
 /* ---------------------- 常量 ---------------------- */
@@ -53,4 +71,206 @@ var XHSTempDir = filepath.Join(BaseTempDir, "XHS")
 // Youtube临时文件夹
 var YoutubeTempDir = filepath.Join(BaseTempDir, "Youtube")
 
+// Apple Music MV临时文件夹
+var AppleMusicVideoTempDir = filepath.Join(BaseTempDir, "AppleMusic")
+
+// videoSeqRe 图集文件名末尾的序号, 如 _01
+var videoSeqRe = regexp.MustCompile(`_\d{2}$`)
+
 /* ---------------------- 视频下载相关业务函数 ---------------------- */
+
+// SkipLibraryDuplicates 过滤曲库中已存在的视频(按平台ID或文件哈希),并删除对应的临时文件
+func SkipLibraryDuplicates(platform processor.LinkType, videos []*VideoInfo) []*VideoInfo {
+	kept := make([]*VideoInfo, 0, len(videos))
+	for _, v := range videos {
+		if v.Hash == "" && v.VideoPath != "" {
+			hash, err := core.HashFile(v.VideoPath)
+			if err != nil {
+				utils.WarnWithFormat("[Library] 计算文件哈希失败 %s: %v", v.VideoPath, err)
+			}
+			v.Hash = hash
+		}
+		if e, ok := core.GlobalLibrary.Contains(string(platform), v.VideoID, v.Hash); ok {
+			core.LogLibraryHit(string(platform), e)
+			_ = os.Remove(v.VideoPath)
+			if v.CoverPath != "" {
+				_ = os.Remove(v.CoverPath)
+			}
+			for _, f := range v.Files {
+				_ = os.Remove(f)
+			}
+			continue
+		}
+		kept = append(kept, v)
+	}
+	return kept
+}
+
+// TidyVideoDir 将临时目录中的视频及附属文件按路径模板整理到存储后端,完成后清除临时目录
+func TidyVideoDir(ctx context.Context, tag string, p Processor, tempDir string, cfg *config.Config) error {
+	files, err := os.ReadDir(tempDir)
+	if err != nil {
+		return fmt.Errorf("读取临时目录失败: %w", err)
+	}
+	if len(files) == 0 {
+		utils.WarnWithFormat("[%s] ⚠️ 未找到待整理的资源文件", tag)
+		return errors.New("未找到待整理的资源文件")
+	}
+	// 无论整理成功与否都清除临时目录
+	defer func() {
+		_ = processor.RemoveTempDir(tempDir)
+	}()
+
+	platform := string(p.Name())
+	handled := make(map[string]bool)
+	// 单个视频失败不影响其余视频, 失败原因汇总返回
+	var errs []error
+	for _, v := range p.Videos() {
+		paths, err := tidyVideo(ctx, tag, platform, v, cfg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cmp.Or(v.Title, v.VideoID), err))
+		}
+		for _, f := range paths {
+			handled[f] = true
+		}
+	}
+	// 未关联到视频的文件整理到平台目录下
+	for _, f := range files {
+		src := filepath.Join(tempDir, f.Name())
+		if f.IsDir() || handled[src] {
+			continue
+		}
+		if err := storage.Store(ctx, tag, src, path.Join(platform, utils.SanitizeFileName(f.Name()))); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tidyVideo 整理单个视频及其封面与附属文件: 附属文件与视频同名并保留各自的后缀, 返回已处理的文件;
+// 全部文件存入后标记 v.Stored
+func tidyVideo(ctx context.Context, tag, platform string, v *VideoInfo, cfg *config.Config) ([]string, error) {
+	paths := make([]string, 0, len(v.Files)+2)
+	for _, f := range append([]string{v.VideoPath, v.CoverPath}, v.Files...) {
+		if f != "" {
+			paths = append(paths, f)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	fileStem := videoFileStem(paths)
+	mainSuffix := videoFileSuffix(paths[0], fileStem)
+	stem := storage.RenderStem(storage.Layout(cfg, platform, true), videoFields(platform, v))
+	key, err := storage.Place(ctx, tag, paths[0], stem+mainSuffix, cfg.Tidy.Collision)
+	if err != nil {
+		return paths, err
+	}
+	// 同名文件已存在而跳过时附属文件一并跳过
+	if key != "" {
+		stem = strings.TrimSuffix(key, mainSuffix)
+		for _, f := range paths[1:] {
+			if err := storage.Store(ctx, tag, f, stem+videoFileSuffix(f, fileStem)); err != nil {
+				return paths, err
+			}
+		}
+	}
+	v.Stored = true
+	return paths, nil
+}
+
+// videoFileStem 视频各文件共同的文件名前缀; 图集各图片带 _01、_02 序号, 此时去掉序号
+func videoFileStem(paths []string) string {
+	main := filepath.Base(paths[0])
+	stem := strings.TrimSuffix(main, filepath.Ext(main))
+	for _, f := range paths[1:] {
+		if !strings.HasPrefix(filepath.Base(f), stem) {
+			return videoSeqRe.ReplaceAllString(stem, "")
+		}
+	}
+	return stem
+}
+
+// videoFileSuffix 文件名去掉共同前缀后的部分(如 .mp4、.zh.vtt、_02.jpeg)
+func videoFileSuffix(f, stem string) string {
+	base := filepath.Base(f)
+	if stem != "" && strings.HasPrefix(base, stem) {
+		return utils.SanitizeFileName(base[len(stem):])
+	}
+	return "_" + utils.SanitizeFileName(base)
+}
+
+// videoFields 视频路径模板字段
+func videoFields(platform string, v *VideoInfo) storage.Fields {
+	date := v.Time
+	if len(date) > 10 {
+		date = date[:10]
+	}
+	return storage.Fields{
+		"platform": platform,
+		"id":       v.VideoID,
+		"title":    cmp.Or(v.Title, v.VideoID),
+		"author":   cmp.Or(v.Author, "未知作者"),
+		"date":     date,
+	}
+}
+
+// resolveRedirect 还原短链(只跟随一次跳转),失败时返回原链接
+func resolveRedirect(ctx context.Context, link string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return link
+	}
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return link
+	}
+	defer resp.Body.Close()
+	if location := resp.Header.Get("Location"); location != "" {
+		utils.DebugWithFormat("[Video] 短链还原: %s -> %s", link, location)
+		return location
+	}
+	return link
+}
+
+// progressFunc 将 reporter 转换为进度回调, reporter 为空时不上报
+func progressFunc(reporter ProgressReporter) utils.ProgressFunc {
+	if reporter == nil {
+		return nil
+	}
+	return reporter.ReportProgress
+}
+
+// downloadResource 下载单个资源文件并回调进度,ctx 取消时停止下载,返回格式化后的文件大小
+func downloadResource(ctx context.Context, url, savePath, filename string, reporter ProgressReporter) (string, error) {
+	if err := os.MkdirAll(savePath, 0755); err != nil {
+		return "", fmt.Errorf("创建保存目录失败: %w", err)
+	}
+	dm, err := utils.NewDownloader(url, &utils.DownloadOptions{
+		SavePath:   savePath,
+		FileName:   filename,
+		Timeout:    1200 * time.Second,
+		IgnoreSSL:  true,
+		MaxRetries: 3,
+		ChunkSize:  10 * 1024 * 1024,
+		OnProgress: progressFunc(reporter),
+	})
+	if err != nil {
+		return "", err
+	}
+	// 任务取消时停止下载, 返回时下载协程已退出, 调用方可以安全清理临时目录
+	if err = dm.RunContext(ctx); err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("下载失败: %s, 错误: %w", url, err)
+	}
+	return dm.GetProgress().FormattedSize, nil
+}
@@ -0,0 +1,72 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/storage"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+func TestMain(m *testing.M) {
+	_ = utils.InitLogger(&config.LogConfig{Mode: 1, Level: 4})
+	os.Exit(m.Run())
+}
+
+// failingBackend 本地存储, key 包含 fail 时上传失败
+type failingBackend struct {
+	*storage.Local
+	fail string
+}
+
+func (b *failingBackend) Put(ctx context.Context, localPath, key string) error {
+	if strings.Contains(key, b.fail) {
+		return errors.New("上传失败")
+	}
+	return b.Local.Put(ctx, localPath, key)
+}
+
+// TestTidyVideoDirErrors 上传失败时返回错误, 只有全部文件已整理的视频标记为 Stored
+func TestTidyVideoDirErrors(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dist := t.TempDir()
+	backend := storage.GlobalBackend
+	storage.GlobalBackend = &failingBackend{Local: &storage.Local{Root: dist}, fail: ".zh.vtt"}
+	t.Cleanup(func() { storage.GlobalBackend = backend })
+
+	cfg := &config.Config{Tidy: &config.TidyConfig{VideoLayout: "{author}/{title}.{ext}", Collision: storage.CollisionSuffix}}
+	p := &BiliBiliProcessor{}
+	p.Init(cfg)
+	if err := os.MkdirAll(p.tempDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	file := func(name string) string {
+		f := filepath.Join(p.tempDir, name)
+		if err := os.WriteFile(f, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+	ok := &VideoInfo{VideoID: "BV1", Title: "成功", Author: "up", VideoPath: file("BV1.mp4"), CoverPath: file("BV1.jpg")}
+	// 字幕上传失败
+	failed := &VideoInfo{VideoID: "BV2", Title: "失败", Author: "up", VideoPath: file("BV2.mp4"), Files: []string{file("BV2.zh.vtt")}}
+	p.videos = []*VideoInfo{ok, failed}
+
+	err := p.Tidy(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "失败: 上传失败") {
+		t.Fatalf("Tidy() = %v, 期望返回上传错误", err)
+	}
+	if !ok.Stored || failed.Stored {
+		t.Errorf("Stored = %v/%v, 期望 true/false", ok.Stored, failed.Stored)
+	}
+	for _, key := range []string{"up/成功.mp4", "up/成功.jpg", "up/失败.mp4"} {
+		if _, err := os.Stat(filepath.Join(dist, key)); err != nil {
+			t.Errorf("%s 未整理: %v", key, err)
+		}
+	}
+}
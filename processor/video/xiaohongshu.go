@@ -0,0 +1,385 @@
+package video
+
+// 小红书笔记下载(视频/图集)
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+const (
+	xhsUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	// xhsImageHost 无水印图片地址
+	xhsImageHost = "https://sns-img-qc.xhscdn.com/"
+	// xhsVideoHost 无水印视频地址
+	xhsVideoHost = "https://sns-video-bd.xhscdn.com/"
+)
+
+var (
+	// xhsNoteIDRe 笔记ID
+	xhsNoteIDRe = regexp.MustCompile(`/(?:explore|discovery/item)/([0-9a-f]{24})`)
+	// xhsStateRe 页面中的初始化数据
+	xhsStateRe = regexp.MustCompile(`(?s)window\.__INITIAL_STATE__\s*=\s*(\{.*?\})\s*</script>`)
+	// xhsUndefinedRe 初始化数据中的 undefined 不是合法 JSON
+	xhsUndefinedRe = regexp.MustCompile(`([:\[,])\s*undefined\b`)
+)
+
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkXiaohongshu,
+		[]string{
+			"www.xiaohongshu.com",
+			"xiaohongshu.com",
+			"xhslink.com",
+		},
+		[]*regexp.Regexp{
+			// 笔记链接(网页/分享)
+			regexp.MustCompile(`^https?://(?:www\.)?xiaohongshu\.com/(?:explore|discovery/item)/[0-9a-f]{24}(?:\?.*)?$`),
+			// 短链接
+			regexp.MustCompile(`^https?://xhslink\.com/(?:[a-z]/)?[\w]+/?$`),
+		},
+		func() processor.Processor { return &XiaohongshuProcessor{} },
+	).WithIdentifier(identifyXiaohongshu)
+}
+
+// identifyXiaohongshu 识别小红书笔记链接, xhslink.com 短链需还原后识别
+func identifyXiaohongshu(link string) *processor.Resource {
+	m := xhsNoteIDRe.FindStringSubmatch(link)
+	if m == nil {
+		return nil
+	}
+	return processor.NewResource(processor.ResourceVideo, m[1], "https://www.xiaohongshu.com/explore/%s", m[1])
+}
+
+/* ---------------------- 结构体与构造方法 ---------------------- */
+
+type XiaohongshuProcessor struct {
+	cfg     *config.Config
+	tempDir string
+	videos  []*VideoInfo
+	client  *http.Client
+}
+
+// xhsNote 笔记详情
+type xhsNote struct {
+	NoteID string `json:"noteId"`
+	Type   string `json:"type"` // normal: 图集, video: 视频
+	Title  string `json:"title"`
+	Desc   string `json:"desc"`
+	Time   int64  `json:"time"`
+	User   struct {
+		Nickname string `json:"nickname"`
+		UserID   string `json:"userId"`
+	} `json:"user"`
+	ImageList []struct {
+		URLDefault string `json:"urlDefault"`
+		Width      int    `json:"width"`
+		Height     int    `json:"height"`
+		LivePhoto  bool   `json:"livePhoto"`
+		Stream     struct {
+			H264 []xhsStream `json:"h264"`
+		} `json:"stream"`
+	} `json:"imageList"`
+	Video struct {
+		Consumer struct {
+			OriginVideoKey string `json:"originVideoKey"`
+		} `json:"consumer"`
+		Media struct {
+			Stream struct {
+				H264 []xhsStream `json:"h264"`
+				H265 []xhsStream `json:"h265"`
+			} `json:"stream"`
+		} `json:"media"`
+	} `json:"video"`
+}
+
+type xhsStream struct {
+	MasterURL  string   `json:"masterUrl"`
+	BackupURLs []string `json:"backupUrls"`
+	Width      int      `json:"width"`
+	Height     int      `json:"height"`
+}
+
+// Init  初始化
+func (p *XiaohongshuProcessor) Init(cfg *config.Config) {
+	p.cfg = cfg
+	p.videos = make([]*VideoInfo, 0)
+	p.tempDir = processor.BuildOutputDir(XHSTempDir)
+	p.client = &http.Client{Timeout: 30 * time.Second}
+}
+
+/* ---------------------- 基础接口实现 ---------------------- */
+
+func (p *XiaohongshuProcessor) Name() processor.LinkType {
+	return processor.LinkXiaohongshu
+}
+
+func (p *XiaohongshuProcessor) Videos() []*VideoInfo {
+	return p.videos
+}
+
+/* ------------------------ 下载逻辑 ------------------------ */
+
+func (p *XiaohongshuProcessor) Download(ctx context.Context, link string, reporter ProgressReporter) error {
+	start := time.Now()
+	utils.InfoWithFormat("[XHS] 📕 开始下载: %s", link)
+
+	// xhslink.com 短链先还原(保留 xsec_token 参数)
+	if strings.Contains(link, "xhslink.com") {
+		link = resolveRedirect(ctx, link)
+	}
+	m := xhsNoteIDRe.FindStringSubmatch(link)
+	if m == nil {
+		return fmt.Errorf("无法识别笔记ID: %s", link)
+	}
+	noteID := m[1]
+	if e, ok := core.GlobalLibrary.Has(string(p.Name()), noteID); ok {
+		core.LogLibraryHit("XHS", e)
+		return core.ErrInLibrary
+	}
+
+	note, err := p.fetchNote(ctx, link, noteID)
+	if err != nil {
+		utils.ErrorWithFormat("[XHS] ❌ 获取笔记信息失败: %v", err)
+		return err
+	}
+
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[XHS] ❌ 创建临时目录失败: %v", err)
+		return err
+	}
+
+	info := &VideoInfo{
+		VideoID: note.NoteID,
+		Title:   note.Title,
+		Author:  note.User.Nickname,
+		Desc:    note.Desc,
+		Tidy:    processor.DetermineTidyType(p.cfg),
+	}
+	if info.Title == "" {
+		info.Title = utils.TruncateString(note.Desc, 30)
+	}
+	if note.Time > 0 {
+		info.Time = time.UnixMilli(note.Time).Format("2006-01-02 15:04:05")
+	}
+
+	if note.Type == "video" {
+		err = p.downloadVideoNote(ctx, note, info, reporter)
+	} else {
+		err = p.downloadImageNote(ctx, note, info, reporter)
+	}
+	if err != nil {
+		_ = processor.RemoveTempDir(p.tempDir)
+		if ctx.Err() != nil {
+			utils.InfoWithFormat("[XHS] 🚫 下载已取消: %s", link)
+			return ctx.Err()
+		}
+		utils.ErrorWithFormat("[XHS] ❌ 下载失败: %v", err)
+		return err
+	}
+	p.videos = append(p.videos, info)
+
+	utils.InfoWithFormat("[XHS] ✅ 下载完成: %s （耗时 %v）", info.Title, time.Since(start).Truncate(time.Millisecond))
+	progressFunc(reporter).Message(utils.PhaseDone, "下载完成: %s （耗时 %v）", info.Title, time.Since(start).Truncate(time.Millisecond))
+	return nil
+}
+
+func (p *XiaohongshuProcessor) Tidy(ctx context.Context) error {
+	// 跳过曲库中已存在的笔记
+	p.videos = SkipLibraryDuplicates(p.Name(), p.videos)
+	if len(p.videos) == 0 {
+		_ = processor.RemoveTempDir(p.tempDir)
+		return core.ErrInLibrary
+	}
+	return TidyVideoDir(ctx, "XHS", p, p.tempDir, p.cfg)
+}
+
+/* ------------------------ 拓展方法 ------------------------ */
+
+// fetchNote 请求笔记页面并解析初始化数据
+func (p *XiaohongshuProcessor) fetchNote(ctx context.Context, link, noteID string) (*xhsNote, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", xhsUserAgent)
+	req.Header.Set("Referer", "https://www.xiaohongshu.com/")
+	if cookie := p.cookieHeader(); cookie != "" {
+		req.Header.Set("Cookie", cookie)
+	} else {
+		utils.WarnWithFormat("[XHS] ⚠️ 未找到小红书登录 cookie，部分笔记可能无法访问")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求笔记页面失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求笔记页面失败: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	m := xhsStateRe.FindSubmatch(body)
+	if m == nil {
+		return nil, errors.New("未找到笔记数据(可能需要登录或链接缺少 xsec_token)")
+	}
+	var state struct {
+		Note struct {
+			NoteDetailMap map[string]struct {
+				Note *xhsNote `json:"note"`
+			} `json:"noteDetailMap"`
+		} `json:"note"`
+	}
+	if err := json.Unmarshal(xhsUndefinedRe.ReplaceAll(m[1], []byte("${1}null")), &state); err != nil {
+		return nil, fmt.Errorf("解析笔记数据失败: %w", err)
+	}
+	detail, ok := state.Note.NoteDetailMap[noteID]
+	if !ok || detail.Note == nil || detail.Note.NoteID == "" {
+		return nil, errors.New("笔记不存在或已被删除")
+	}
+	return detail.Note, nil
+}
+
+// downloadVideoNote 下载视频笔记(优先无水印原视频)
+func (p *XiaohongshuProcessor) downloadVideoNote(ctx context.Context, note *xhsNote, info *VideoInfo, reporter ProgressReporter) error {
+	urls := make([]string, 0, 4)
+	if key := note.Video.Consumer.OriginVideoKey; key != "" {
+		urls = append(urls, xhsVideoHost+key)
+	}
+	streams := make([]xhsStream, 0, len(note.Video.Media.Stream.H265)+len(note.Video.Media.Stream.H264))
+	streams = append(streams, note.Video.Media.Stream.H265...)
+	streams = append(streams, note.Video.Media.Stream.H264...)
+	for _, s := range streams {
+		if s.MasterURL != "" {
+			urls = append(urls, s.MasterURL)
+		}
+		if info.Ratio == "" && s.Width > 0 {
+			info.Ratio = fmt.Sprintf("%dx%d", s.Width, s.Height)
+		}
+	}
+	if len(note.ImageList) > 0 {
+		info.CoverUrl = note.ImageList[0].URLDefault
+	}
+
+	fileName := p.fileName(info, "", ".mp4")
+	size, err := p.downloadFirst(ctx, urls, fileName, reporter)
+	if err != nil {
+		return err
+	}
+	info.Size = size
+	info.VideoPath = filepath.Join(p.tempDir, fileName)
+	return nil
+}
+
+// downloadImageNote 下载图集笔记的全部图片(实况照片同时保存视频)
+func (p *XiaohongshuProcessor) downloadImageNote(ctx context.Context, note *xhsNote, info *VideoInfo, reporter ProgressReporter) error {
+	if len(note.ImageList) == 0 {
+		return errors.New("笔记中没有图片")
+	}
+	info.CoverUrl = note.ImageList[0].URLDefault
+	info.Ratio = fmt.Sprintf("图集 %d 张", len(note.ImageList))
+
+	for i, img := range note.ImageList {
+		suffix := fmt.Sprintf("_%02d", i+1)
+		urls := make([]string, 0, 2)
+		if token := p.imageToken(img.URLDefault); token != "" {
+			urls = append(urls, xhsImageHost+token+"?imageView2/2/w/0/format/png")
+		}
+		urls = append(urls, img.URLDefault)
+
+		ext := ".png"
+		if len(urls) == 1 {
+			ext = ".webp"
+		}
+		fileName := p.fileName(info, suffix, ext)
+		if _, err := p.downloadFirst(ctx, urls, fileName, reporter); err != nil {
+			return fmt.Errorf("第%d张图片下载失败: %w", i+1, err)
+		}
+		path := filepath.Join(p.tempDir, fileName)
+		if info.VideoPath == "" {
+			info.VideoPath = path
+		} else {
+			info.Files = append(info.Files, path)
+		}
+
+		if img.LivePhoto && len(img.Stream.H264) > 0 && img.Stream.H264[0].MasterURL != "" {
+			liveName := p.fileName(info, suffix, ".mp4")
+			if _, err := p.downloadFirst(ctx, []string{img.Stream.H264[0].MasterURL}, liveName, reporter); err != nil {
+				utils.WarnWithFormat("[XHS] ⚠️ 第%d张实况照片视频下载失败: %v", i+1, err)
+				continue
+			}
+			info.Files = append(info.Files, filepath.Join(p.tempDir, liveName))
+		}
+	}
+	return nil
+}
+
+// downloadFirst 依次尝试多个地址,返回第一个成功下载的文件大小
+func (p *XiaohongshuProcessor) downloadFirst(ctx context.Context, urls []string, fileName string, reporter ProgressReporter) (string, error) {
+	if len(urls) == 0 {
+		return "", errors.New("未找到下载地址")
+	}
+	var lastErr error
+	for _, u := range urls {
+		size, err := downloadResource(ctx, u, p.tempDir, fileName, reporter)
+		if err == nil {
+			return size, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		utils.DebugWithFormat("[XHS] 下载地址不可用 %s: %v", u, err)
+		lastErr = err
+	}
+	return "", lastErr
+}
+
+// imageToken 从图片地址中提取图片标识,用于拼接无水印地址
+// 如 http://sns-webpic-qc.xhscdn.com/202401011200/<签名>/1040g2sg30...!nd_dft_wlteh_webp_3
+func (p *XiaohongshuProcessor) imageToken(u string) string {
+	path := strings.SplitN(u, "!", 2)[0]
+	parts := strings.Split(path, "/")
+	if len(parts) <= 5 {
+		return ""
+	}
+	return strings.Join(parts[5:], "/")
+}
+
+// fileName 文件名: 作者 - 标题[后缀].ext
+func (p *XiaohongshuProcessor) fileName(info *VideoInfo, suffix, ext string) string {
+	title := info.Title
+	if title == "" {
+		title = info.VideoID
+	}
+	return utils.SanitizeFileName(fmt.Sprintf("%s - %s%s%s", info.Author, title, suffix, ext))
+}
+
+// cookieHeader CookieCloud 中的小红书 cookie
+func (p *XiaohongshuProcessor) cookieHeader() string {
+	cookiePath := filepath.Join(p.cfg.CookieCloud.CookieFilePath, p.cfg.CookieCloud.CookieFile)
+	cookies := utils.GetCookiesByDomain(cookiePath, "xiaohongshu.com")
+	pairs := make([]string, 0, len(cookies))
+	for name, value := range cookies {
+		pairs = append(pairs, name+"="+value)
+	}
+	return strings.Join(pairs, "; ")
+}
@@ -1,24 +1,70 @@
 package video
 
 import (
-	"errors"
-	"fmt"
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
 	"github.com/nichuanfang/gymdl/config"
 	"github.com/nichuanfang/gymdl/core"
 	"github.com/nichuanfang/gymdl/processor"
 	"github.com/nichuanfang/gymdl/utils"
-	"os"
-	"path/filepath"
 )
 
 // youtube下载
 
+// youtubeShortRe 短链/Shorts 中的视频ID
+var youtubeShortRe = regexp.MustCompile(`(?:youtu\.be/|/shorts/|/live/)([\w-]{11})`)
+
+/* ---------------------- 注册 ---------------------- */
+
+func init() {
+	processor.Register(processor.LinkYoutube,
+		[]string{
+			"youtube.com",
+			"www.youtube.com",
+			"m.youtube.com",
+			"youtu.be",
+		},
+		[]*regexp.Regexp{
+			// 普通 YouTube 视频
+			regexp.MustCompile(`^https?://(?:www\.|m\.)?youtube\.com/watch\?v=[\w-]+(?:&.*)?$`),
+
+			// 短链格式
+			regexp.MustCompile(`^https?://youtu\.be/[\w-]+(?:\?.*)?$`),
+
+			// Shorts / 直播回放
+			regexp.MustCompile(`^https?://(?:www\.|m\.)?youtube\.com/(?:shorts|live)/[\w-]+(?:\?.*)?$`),
+
+			// 播放列表
+			regexp.MustCompile(`^https?://(?:www\.|m\.)?youtube\.com/playlist\?list=[\w-]+(?:&.*)?$`),
+		},
+		func() processor.Processor { return &YoutubeProcessor{} },
+	).WithIdentifier(identifyYoutube)
+}
+
+// identifyYoutube 识别 YouTube 链接
+func identifyYoutube(link string) *processor.Resource {
+	p := &YoutubeProcessor{}
+	if p.isPlaylist(link) {
+		id := processor.QueryParam(link, "list")
+		return processor.NewResource(processor.ResourcePlaylist, id, "https://www.youtube.com/playlist?list=%s", id)
+	}
+	if id := p.videoID(link); id != "" {
+		return processor.NewResource(processor.ResourceVideo, id, "https://www.youtube.com/watch?v=%s", id)
+	}
+	return nil
+}
+
 /* ---------------------- 结构体与构造方法 ---------------------- */
 
 type YoutubeProcessor struct {
 	cfg     *config.Config
 	tempDir string
 	videos  []*VideoInfo
+	tracker *processor.YtDlpTracker
 }
 
 // Init  初始化
@@ -26,6 +72,7 @@ func (p *YoutubeProcessor) Init(cfg *config.Config) {
 	p.cfg = cfg
 	p.videos = make([]*VideoInfo, 0)
 	p.tempDir = processor.BuildOutputDir(YoutubeTempDir)
+	p.tracker = nil
 }
 
 /* ---------------------- 基础接口实现 ---------------------- */
@@ -40,85 +87,79 @@ func (p *YoutubeProcessor) Videos() []*VideoInfo {
 
 /* ------------------------ 下载逻辑 ------------------------ */
 
-func (p *YoutubeProcessor) Download(url string, callback func(progress string)) error {
-	utils.Debugf("开始下载视频:%s", url)
-	return nil
-}
-
-/* ------------------------ 拓展方法 ------------------------ */
+func (p *YoutubeProcessor) Download(ctx context.Context, link string, reporter ProgressReporter) error {
+	start := time.Now()
+	utils.InfoWithFormat("[Youtube] 📺 开始下载: %s", link)
 
-func (p *YoutubeProcessor) Tidy() error {
-	files, err := os.ReadDir(p.tempDir)
-	if err != nil {
-		return fmt.Errorf("读取临时目录失败: %w", err)
-	}
-	if len(files) == 0 {
-		utils.WarnWithFormat("[DouYinVideo] ⚠️ 未找到待整理的资源文件")
-		return errors.New("未找到待整理的资源文件")
+	if id := p.videoID(link); id != "" {
+		if e, ok := core.GlobalLibrary.Has(string(p.Name()), id); ok {
+			core.LogLibraryHit("Youtube", e)
+			return core.ErrInLibrary
+		}
 	}
 
-	switch p.cfg.Tidy.Mode {
-	case 1:
-		return p.tidyToLocal(files)
-	case 2:
-		return p.tidyToWebDAV(files, core.GlobalWebDAV)
-	default:
-		return fmt.Errorf("未知整理模式: %d", p.cfg.Tidy.Mode)
+	// 创建临时目录
+	if err := processor.CreateOutputDir(p.tempDir); err != nil {
+		utils.ErrorWithFormat("[Youtube] ❌ 创建临时目录失败: %v", err)
+		return err
 	}
-}
 
-// 整理到本地
-func (p *YoutubeProcessor) tidyToLocal(files []os.DirEntry) error {
-	dstDir := p.cfg.Tidy.DistDir
-	if dstDir == "" {
-		_ = processor.RemoveTempDir(p.tempDir)
-		return errors.New("未配置输出目录")
+	var subLangs []string
+	if p.cfg.Video.Subtitles {
+		subLangs = append(subLangs, p.cfg.Video.SubLangs)
 	}
-	if err := os.MkdirAll(dstDir, 0755); err != nil {
-		_ = processor.RemoveTempDir(p.tempDir)
-		return fmt.Errorf("创建输出目录失败: %w", err)
+	args := ytDlpVideoArgs(p.cfg, p.tempDir, subLangs)
+	if p.isPlaylist(link) {
+		args = append(args, "--yes-playlist")
+	} else {
+		args = append(args, "--no-playlist")
 	}
+	args = append(args, link)
 
-	for _, f := range files {
-		src := filepath.Join(p.tempDir, f.Name())
-		dst := filepath.Join(dstDir, utils.SanitizeFileName(f.Name()))
-		if err := os.Rename(src, dst); err != nil {
-			utils.WarnWithFormat("[DouYinVideo] ⚠️ 移动失败 %s → %s: %v", src, dst, err)
-			continue
-		}
-		utils.InfoWithFormat("[DouYinVideo] 📦 已整理: %s", dst)
+	tracker, err := runYtDlp(ctx, "Youtube", ytDlpCommand(ctx, args), p.tempDir, reporter)
+	if err != nil {
+		return err
 	}
-	//清除临时目录
-	err := processor.RemoveTempDir(p.tempDir)
+	p.tracker = tracker
+
+	p.videos, err = collectYtDlpVideos(p.tempDir, p.tracker, processor.DetermineTidyType(p.cfg))
 	if err != nil {
-		utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除临时目录失败: %s (%v)", p.tempDir, err)
+		_ = processor.RemoveTempDir(p.tempDir)
 		return err
 	}
-	utils.DebugWithFormat("[DouYinVideo] 🧹 已删除临时目录: %s", p.tempDir)
+	utils.InfoWithFormat("[Youtube] ✅ 下载完成: %d 个视频（耗时 %v）", len(p.videos), time.Since(start).Truncate(time.Millisecond))
+	progressFunc(reporter).Message(utils.PhaseDone, "下载完成: %d 个视频（耗时 %v）", len(p.videos), time.Since(start).Truncate(time.Millisecond))
 	return nil
 }
 
-// 整理到webdav
-func (p *YoutubeProcessor) tidyToWebDAV(files []os.DirEntry, webdav *core.WebDAV) error {
-	if webdav == nil {
+/* ------------------------ 拓展方法 ------------------------ */
+
+func (p *YoutubeProcessor) Tidy(ctx context.Context) error {
+	// 跳过曲库中已存在的视频
+	p.videos = SkipLibraryDuplicates(p.Name(), p.videos)
+	if len(p.videos) == 0 {
 		_ = processor.RemoveTempDir(p.tempDir)
-		return errors.New("WebDAV 未初始化")
+		return core.ErrInLibrary
 	}
+	return TidyVideoDir(ctx, "Youtube", p, p.tempDir, p.cfg)
+}
 
-	for _, f := range files {
-		filePath := filepath.Join(p.tempDir, f.Name())
-		if err := webdav.Upload(filePath); err != nil {
-			utils.WarnWithFormat("[DouYinVideo] ☁️ 上传失败 %s: %v", f.Name(), err)
-			continue
-		}
-		utils.InfoWithFormat("[DouYinVideo] ☁️ 已上传: %s", f.Name())
+// videoID 单个视频链接的视频ID
+func (p *YoutubeProcessor) videoID(link string) string {
+	if p.isPlaylist(link) {
+		return ""
+	}
+	if m := youtubeShortRe.FindStringSubmatch(link); m != nil {
+		return m[1]
 	}
-	//清除临时目录
-	err := processor.RemoveTempDir(p.tempDir)
+	u, err := url.Parse(link)
 	if err != nil {
-		utils.WarnWithFormat("[DouYinVideo] ⚠️ 删除临时目录失败: %s (%v)", p.tempDir, err)
-		return err
+		return ""
 	}
-	utils.DebugWithFormat("[DouYinVideo] 🧹 已删除临时目录: %s", p.tempDir)
-	return nil
+	return u.Query().Get("v")
+}
+
+// isPlaylist 是否为播放列表链接(watch?v=..&list=.. 仅下载当前视频)
+func (p *YoutubeProcessor) isPlaylist(link string) bool {
+	return strings.Contains(link, "/playlist?")
 }
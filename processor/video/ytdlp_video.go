@@ -0,0 +1,181 @@
+package video
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/processor"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// 基于 yt-dlp 的视频下载(YouTube/B站)
+
+// ytDlpCodecs 配置中的编码名称 -> yt-dlp 排序字段中的编码名称
+var ytDlpCodecs = map[string]string{
+	"avc":  "h264",
+	"h264": "h264",
+	"hevc": "h265",
+	"h265": "h265",
+	"av1":  "av01",
+	"vp9":  "vp9",
+}
+
+// ytDlpVideoExts 合并后的视频后缀
+var ytDlpVideoExts = []string{".mp4", ".mkv", ".webm", ".flv"}
+
+// ytDlpVideoMeta yt-dlp info.json 中用到的字段
+type ytDlpVideoMeta struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Uploader    string `json:"uploader"`
+	UploadDate  string `json:"upload_date"`
+	Thumbnail   string `json:"thumbnail"`
+	Description string `json:"description"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+// ytDlpVideoArgs 通用下载参数: 格式选择、音视频合并、字幕、元数据
+func ytDlpVideoArgs(cfg *config.Config, tempDir string, subLangs []string) []string {
+	vc := cfg.Video
+	args := []string{
+		// 最佳视频+最佳音频合并,无法合并时回退到单文件
+		"-f", "bv*+ba/b",
+		"--merge-output-format", "mp4/mkv",
+		"-o", filepath.Join(tempDir, "%(title).150B [%(id)s].%(ext)s"),
+		"--write-info-json",
+		"-o", "infojson:" + filepath.Join(tempDir, "%(id)s"),
+		"--embed-metadata",
+		"--embed-chapters",
+	}
+	// 分辨率与编码偏好
+	sorts := make([]string, 0, 2)
+	if vc.MaxHeight > 0 {
+		sorts = append(sorts, fmt.Sprintf("res:%d", vc.MaxHeight))
+	}
+	if codec, ok := ytDlpCodecs[strings.ToLower(vc.Codec)]; ok {
+		sorts = append(sorts, "vcodec:"+codec)
+	}
+	if len(sorts) > 0 {
+		args = append(args, "-S", strings.Join(sorts, ","))
+	}
+	if len(subLangs) > 0 {
+		args = append(args, "--write-subs", "--sub-langs", strings.Join(subLangs, ","))
+	}
+	// 会员/年龄限制/高画质需要登录 cookie
+	cookiePath := filepath.Join(cfg.CookieCloud.CookieFilePath, cfg.CookieCloud.CookieFile)
+	if _, err := os.Stat(cookiePath); err == nil {
+		args = append(args, "--cookies", cookiePath)
+	}
+	return append(args, processor.YtDlpOutputArgs()...)
+}
+
+// ytDlpCommand 构建 yt-dlp 命令
+func ytDlpCommand(ctx context.Context, args []string) *exec.Cmd {
+	return processor.Command(ctx, "yt-dlp", args...)
+}
+
+// runYtDlp 执行下载并将进度回调给 reporter,失败或取消时清理临时目录
+func runYtDlp(ctx context.Context, tag string, cmd *exec.Cmd, tempDir string, reporter ProgressReporter) (*processor.YtDlpTracker, error) {
+	utils.DebugWithFormat("[%s] 执行命令: %s", tag, strings.Join(cmd.Args, " "))
+	tracker := processor.NewYtDlpTracker(progressFunc(reporter))
+	logOut, err := processor.RunCommand(cmd, tracker.Feed)
+	if err != nil && ctx.Err() != nil {
+		_ = processor.RemoveTempDir(tempDir)
+		utils.InfoWithFormat("[%s] 🚫 下载已取消", tag)
+		return nil, ctx.Err()
+	}
+	// 列表中个别视频失败时 yt-dlp 也会返回非0,只要有视频下载成功就继续整理
+	if tracker.Downloaded() == 0 {
+		_ = processor.RemoveTempDir(tempDir)
+		utils.ErrorWithFormat("[%s] ❌ 下载失败: %v\n输出:\n%s", tag, err, logOut)
+		if len(tracker.Errors) > 0 {
+			return nil, fmt.Errorf("yt-dlp 下载失败: %s", tracker.Errors[0])
+		}
+		return nil, fmt.Errorf("yt-dlp 下载失败: %v", err)
+	}
+	if err != nil {
+		utils.WarnWithFormat("[%s] ⚠️ 部分视频下载失败: %s", tag, strings.Join(tracker.Errors, "; "))
+	}
+	return tracker, nil
+}
+
+// collectYtDlpVideos 读取临时目录中的视频及对应 info.json,读取后删除 info.json
+func collectYtDlpVideos(tempDir string, tracker *processor.YtDlpTracker, tidy string) ([]*VideoInfo, error) {
+	files, err := os.ReadDir(tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("读取临时目录失败: %w", err)
+	}
+	videos := make([]*VideoInfo, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !utils.Contains(ytDlpVideoExts, strings.ToLower(filepath.Ext(f.Name()))) {
+			continue
+		}
+		path := filepath.Join(tempDir, f.Name())
+		v := &VideoInfo{
+			VideoID:   tracker.IDOf(path),
+			Title:     strings.TrimSuffix(f.Name(), filepath.Ext(f.Name())),
+			Tidy:      tidy,
+			VideoPath: path,
+		}
+		if info, err := f.Info(); err == nil {
+			v.Size = utils.FormatBytes(info.Size())
+		}
+		// 字幕/弹幕等与视频同名的附属文件
+		stem := strings.TrimSuffix(path, filepath.Ext(path))
+		siblings, _ := filepath.Glob(globEscape(stem) + ".*")
+		for _, sib := range siblings {
+			if sib != path && !strings.HasSuffix(sib, ".info.json") {
+				v.Files = append(v.Files, sib)
+			}
+		}
+		if meta, err := readYtDlpMeta(tempDir, v.VideoID); err == nil {
+			v.Title = meta.Title
+			v.Author = meta.Uploader
+			v.CoverUrl = meta.Thumbnail
+			v.Desc = meta.Description
+			if meta.Width > 0 && meta.Height > 0 {
+				v.Ratio = fmt.Sprintf("%dx%d", meta.Width, meta.Height)
+			}
+			if t, err := time.Parse("20060102", meta.UploadDate); err == nil {
+				v.Time = t.Format("2006-01-02")
+			}
+		}
+		videos = append(videos, v)
+	}
+	// info.json 仅用于读取元信息,不参与整理
+	matches, _ := filepath.Glob(filepath.Join(tempDir, "*.info.json"))
+	for _, m := range matches {
+		_ = os.Remove(m)
+	}
+	return videos, nil
+}
+
+// readYtDlpMeta 读取视频对应的 info.json
+func readYtDlpMeta(tempDir, id string) (*ytDlpVideoMeta, error) {
+	if id == "" {
+		return nil, errors.New("未找到视频ID")
+	}
+	data, err := os.ReadFile(filepath.Join(tempDir, id+".info.json"))
+	if err != nil {
+		return nil, err
+	}
+	meta := &ytDlpVideoMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// globEscape 转义 glob 特殊字符(标题中常见 [ ])
+func globEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, "*", `\*`, "?", `\?`).Replace(s)
+}
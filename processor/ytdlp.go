@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// yt-dlp 通用参数与输出解析
+
+/* ---------------------- 常量 ---------------------- */
+
+const (
+	ytDlpItemPrefix     = "[item] "
+	ytDlpProgressPrefix = "[progress] "
+	ytDlpFilePrefix     = "[file] "
+)
+
+// YtDlpOutputArgs yt-dlp 结构化输出参数,配合 YtDlpTracker 解析下载进度与最终文件
+func YtDlpOutputArgs() []string {
+	return []string{
+		"--newline",
+		"--progress",
+		"--no-warnings",
+		"--progress-template", "download:" + ytDlpProgressPrefix + "%(progress.downloaded_bytes)s|%(progress.total_bytes,progress.total_bytes_estimate)s|%(progress.speed)s|%(progress.eta)s",
+		"--print", "before_dl:" + ytDlpItemPrefix + "%(playlist_index|1)s|%(n_entries|1)s|%(title)s",
+		"--print", "after_move:" + ytDlpFilePrefix + "%(id)s|%(filepath)s",
+	}
+}
+
+/* ---------------------- 结构体定义 ---------------------- */
+
+// YtDlpTracker yt-dlp 输出解析器
+type YtDlpTracker struct {
+	Index  int    // 当前条目序号
+	Count  int    // 条目总数
+	Title  string // 当前条目标题
+	Errors []string
+
+	mu       sync.Mutex
+	files    map[string]string // 文件名 -> 资源ID
+	report   utils.ProgressFunc
+	interval time.Duration
+	last     time.Time
+}
+
+// NewYtDlpTracker 创建解析器,report 为进度回调(同一条目内限频 1 秒)
+func NewYtDlpTracker(report utils.ProgressFunc) *YtDlpTracker {
+	return &YtDlpTracker{
+		files:    make(map[string]string),
+		report:   report,
+		interval: time.Second,
+	}
+}
+
+/* ---------------------- 解析 ---------------------- */
+
+// Feed 解析一行 yt-dlp 输出
+func (t *YtDlpTracker) Feed(line string) {
+	switch {
+	case strings.HasPrefix(line, ytDlpItemPrefix):
+		parts := strings.SplitN(strings.TrimPrefix(line, ytDlpItemPrefix), "|", 3)
+		if len(parts) < 3 {
+			return
+		}
+		t.Index, _ = strconv.Atoi(parts[0])
+		t.Count, _ = strconv.Atoi(parts[1])
+		t.Title = parts[2]
+		t.emit(t.event("开始下载"), true)
+	case strings.HasPrefix(line, ytDlpProgressPrefix):
+		parts := strings.SplitN(strings.TrimPrefix(line, ytDlpProgressPrefix), "|", 4)
+		if len(parts) < 4 {
+			return
+		}
+		e := t.event("正在下载")
+		e.Bytes = int64(ytDlpNumber(parts[0]))
+		e.TotalBytes = int64(ytDlpNumber(parts[1]))
+		e.Speed = ytDlpNumber(parts[2])
+		e.ETA = int(ytDlpNumber(parts[3]))
+		t.emit(e, false)
+	case strings.HasPrefix(line, ytDlpFilePrefix):
+		parts := strings.SplitN(strings.TrimPrefix(line, ytDlpFilePrefix), "|", 2)
+		if len(parts) < 2 {
+			return
+		}
+		t.mu.Lock()
+		t.files[filepath.Base(parts[1])] = parts[0]
+		t.mu.Unlock()
+		t.emit(t.event("下载完成"), true)
+	case strings.HasPrefix(line, "ERROR:"):
+		t.Errors = append(t.Errors, strings.TrimSpace(strings.TrimPrefix(line, "ERROR:")))
+	}
+}
+
+// IDOf 根据文件路径获取资源ID
+func (t *YtDlpTracker) IDOf(path string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.files[filepath.Base(path)]
+}
+
+// Downloaded 已下载完成的文件数
+func (t *YtDlpTracker) Downloaded() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.files)
+}
+
+// event 当前条目的进度事件
+func (t *YtDlpTracker) event(message string) utils.Progress {
+	return utils.Progress{
+		Phase:   utils.PhaseDownload,
+		Item:    t.Title,
+		Current: t.Index,
+		Total:   t.Count,
+		Message: message,
+	}
+}
+
+// emit 回调进度,force 为 true 时不限频
+func (t *YtDlpTracker) emit(e utils.Progress, force bool) {
+	if t.report == nil {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(t.last) < t.interval {
+		return
+	}
+	t.last = now
+	t.report(e)
+}
+
+// ytDlpNumber 解析进度模板中的数值, 缺失(NA)时为 0
+func ytDlpNumber(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// 资源库目录结构: 按路径模板生成整理后的 key, 重名时按配置的策略处理
+
+/* ---------------------- 常量 ---------------------- */
+
+// 重名处理策略
+const (
+	CollisionSkip       = "skip"                // 跳过, 保留已有文件
+	CollisionOverwrite  = "overwrite"           // 覆盖已有文件
+	CollisionSuffix     = "suffix"              // 追加序号, 如 title (1).flac
+	CollisionKeepHigher = "keep-higher-bitrate" // 保留码率更高的文件
+	CollisionKeepLarger = "keep-larger"         // 保留较大的文件
+)
+
+// maxSegmentBytes 单级目录/文件名的最大字节数(常见文件系统限制为 255)
+const maxSegmentBytes = 200
+
+// maxSuffix 追加序号的上限
+const maxSuffix = 99
+
+// extSuffix 模板末尾的后缀字段
+const extSuffix = ".{ext}"
+
+// fieldRe 模板字段, 如 {title}、{track:02}
+var fieldRe = regexp.MustCompile(`\{(\w+)(?::(0?\d+))?\}`)
+
+// Fields 模板字段值, 值为字符串或整数
+type Fields map[string]any
+
+/* ---------------------- 模板 ---------------------- */
+
+// Layout 平台对应的路径模板: 优先使用按平台覆盖的模板
+func Layout(cfg *config.Config, platform string, video bool) string {
+	if tmpl := cfg.Tidy.Layouts[platform]; tmpl != "" {
+		return tmpl
+	}
+	if video {
+		return cfg.Tidy.VideoLayout
+	}
+	return cfg.Tidy.MusicLayout
+}
+
+// Render 按模板生成 key: 模板中的 / 分隔目录, 字段值中的路径分隔符等非法字符会被替换,
+// 为空的目录层级会被省略
+func Render(tmpl string, fields Fields) string {
+	segments := strings.Split(strings.ReplaceAll(tmpl, "\\", "/"), "/")
+	parts := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		rendered := fieldRe.ReplaceAllStringFunc(seg, func(m string) string {
+			sub := fieldRe.FindStringSubmatch(m)
+			return formatField(fields[sub[1]], sub[2])
+		})
+		if rendered = sanitizeSegment(rendered); rendered != "" {
+			parts = append(parts, rendered)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// RenderStem 生成不含后缀的 key, 附属文件(歌词/字幕/封面)在其后拼接各自的后缀
+func RenderStem(tmpl string, fields Fields) string {
+	return Render(strings.TrimSuffix(tmpl, extSuffix), fields)
+}
+
+// formatField 格式化字段值, 整数支持宽度与补零(如 02)
+func formatField(v any, spec string) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case int:
+		return fmt.Sprintf("%"+spec+"d", val)
+	case string:
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// sanitizeSegment 清理单级目录/文件名: 替换非法字符与控制字符, 去除首尾空格、点与连字符, 限制长度
+func sanitizeSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, utils.SanitizeFileName(s))
+	// 字段为空时残留的分隔符(如 " - 专辑")一并去除
+	s = strings.Trim(strings.Join(strings.Fields(s), " "), " .-")
+	if len(s) <= maxSegmentBytes {
+		return s
+	}
+	// 超长时保留后缀, 截断主体
+	ext := path.Ext(s)
+	if len(ext) > 16 {
+		ext = ""
+	}
+	body := s[:maxSegmentBytes-len(ext)]
+	for !utf8.ValidString(body) {
+		body = body[:len(body)-1]
+	}
+	return strings.TrimRight(body, " .") + ext
+}
+
+/* ---------------------- 重名处理 ---------------------- */
+
+// Resolve 按重名策略确定存入的 key, 返回空字符串表示跳过; size、bitrate 为待存入文件的大小与码率(kbps, 未知为 0)
+func Resolve(b Backend, key, policy string, size int64, bitrate uint) (string, error) {
+	existing, err := b.Stat(key)
+	if errors.Is(err, ErrNotExist) {
+		return key, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	switch policy {
+	case CollisionOverwrite:
+		return key, nil
+	case CollisionSkip:
+		return "", nil
+	case CollisionKeepHigher:
+		if higherBitrate(b, existing, size, bitrate) {
+			return key, nil
+		}
+		return "", nil
+	case CollisionKeepLarger:
+		if size > existing.Size {
+			return key, nil
+		}
+		return "", nil
+	case CollisionSuffix:
+		ext := path.Ext(key)
+		stem := strings.TrimSuffix(key, ext)
+		for i := 1; i <= maxSuffix; i++ {
+			candidate := fmt.Sprintf("%s (%d)%s", stem, i, ext)
+			ok, err := b.Exists(candidate)
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				return candidate, nil
+			}
+		}
+		return "", fmt.Errorf("重名文件过多: %s", key)
+	default:
+		return "", fmt.Errorf("未知重名策略: %s", policy)
+	}
+}
+
+// higherBitrate 待存入文件的码率是否高于已有文件; 后端无法读取已有文件的码率时按文件大小比较
+// (同一 key 为同一曲目, 时长相同时文件越大平均码率越高)
+func higherBitrate(b Backend, existing *FileInfo, size int64, bitrate uint) bool {
+	if r, ok := b.(BitrateReader); ok && bitrate > 0 {
+		if old := r.Bitrate(existing.Key); old > 0 {
+			return bitrate > old
+		}
+	}
+	return size > existing.Size
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/* ---------------------- 模板 ---------------------- */
+
+func TestRender(t *testing.T) {
+	fields := Fields{
+		"albumartist": "Queen",
+		"album":       "A Night at the Opera",
+		"year":        1975,
+		"disc":        1,
+		"track":       11,
+		"title":       "Bohemian Rhapsody",
+		"ext":         "flac",
+	}
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{albumartist}/{year} - {album}/{disc:02}-{track:02} {title}.{ext}", "Queen/1975 - A Night at the Opera/01-11 Bohemian Rhapsody.flac"},
+		{"{track:03}.{ext}", "011.flac"},
+		{"{track:3}.{ext}", "11.flac"},
+		// Windows 分隔符按目录处理
+		{`{albumartist}\{title}.{ext}`, "Queen/Bohemian Rhapsody.flac"},
+		// 缺失字段: 残留的分隔符去除, 为空的目录层级省略
+		{"{albumartist}/{genre}/{title}.{ext}", "Queen/Bohemian Rhapsody.flac"},
+		{"{albumartist}/{date} {title}.{ext}", "Queen/Bohemian Rhapsody.flac"},
+		{"{genre} - {album}/{title}.{ext}", "A Night at the Opera/Bohemian Rhapsody.flac"},
+		{"{unknown}/{missing}", ""},
+	}
+	for _, tt := range tests {
+		if got := Render(tt.tmpl, fields); got != tt.want {
+			t.Errorf("Render(%q) = %q, 期望 %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestRenderMissingNumber(t *testing.T) {
+	// 缺失的数字字段不补零
+	got := Render("{disc:02}-{track:02} {title}.{ext}", Fields{"title": "Intro", "ext": "mp3"})
+	if got != "Intro.mp3" {
+		t.Errorf("Render() = %q, 期望 %q", got, "Intro.mp3")
+	}
+}
+
+func TestRenderFieldWithSeparator(t *testing.T) {
+	// 字段值中的路径分隔符不产生新的目录层级
+	got := Render("{albumartist}/{title}.{ext}", Fields{"albumartist": "AC/DC", "title": "../../etc/passwd", "ext": "mp3"})
+	if got != "AC_DC/_.._etc_passwd.mp3" {
+		t.Errorf("Render() = %q, 字段值逃逸出模板目录", got)
+	}
+	for _, seg := range strings.Split(got, "/") {
+		if seg == ".." || seg == "." {
+			t.Errorf("Render() = %q, 包含相对路径层级", got)
+		}
+	}
+}
+
+func TestRenderStem(t *testing.T) {
+	fields := Fields{"author": "up主", "title": "视频", "ext": "mp4"}
+	if got := RenderStem("{author}/{title}.{ext}", fields); got != "up主/视频" {
+		t.Errorf("RenderStem() = %q, 期望 %q", got, "up主/视频")
+	}
+	// 不以 .{ext} 结尾的模板原样渲染
+	if got := RenderStem("{author}/{title}", fields); got != "up主/视频" {
+		t.Errorf("RenderStem() = %q, 期望 %q", got, "up主/视频")
+	}
+}
+
+func TestSanitizeSegment(t *testing.T) {
+	tests := map[string]string{
+		"AC/DC":                   "AC_DC",
+		`a\b`:                     "a_b",
+		"..":                      "",
+		".":                       "",
+		"...hidden":               "hidden",
+		`What? <Live>: "1" | *`:   `What_ _Live__ _1_ _ _`,
+		"Mr. Jones...":            "Mr. Jones",
+		"Title. ":                 "Title",
+		"  多余   空格  ":             "多余 空格",
+		"- 专辑":                    "专辑",
+		"tab\there\nnewline\x00x": "tab here newline x",
+	}
+	for in, want := range tests {
+		if got := sanitizeSegment(in); got != want {
+			t.Errorf("sanitizeSegment(%q) = %q, 期望 %q", in, got, want)
+		}
+	}
+}
+
+func TestSanitizeSegmentLength(t *testing.T) {
+	long := strings.Repeat("歌", 100) + ".flac" // 305 字节
+	got := sanitizeSegment(long)
+	if len(got) > maxSegmentBytes {
+		t.Errorf("截断后 %d 字节, 超过上限 %d", len(got), maxSegmentBytes)
+	}
+	if !strings.HasSuffix(got, ".flac") || !strings.HasPrefix(got, "歌") {
+		t.Errorf("截断后应保留后缀: %q", got)
+	}
+	if !strings.HasPrefix(strings.TrimSuffix(got, ".flac"), strings.Repeat("歌", 65)) {
+		t.Errorf("截断位置错误: %q", got)
+	}
+}
+
+/* ---------------------- 重名处理 ---------------------- */
+
+// fakeBackend 内存存储后端, 仅记录文件大小
+type fakeBackend struct {
+	files map[string]int64
+}
+
+func (f *fakeBackend) Name() string { return "fake" }
+
+func (f *fakeBackend) Put(ctx context.Context, localPath, key string) error {
+	f.files[key] = 0
+	return nil
+}
+
+func (f *fakeBackend) Exists(key string) (bool, error) {
+	_, ok := f.files[key]
+	return ok, nil
+}
+
+func (f *fakeBackend) List(dir string) ([]FileInfo, error) { return nil, nil }
+
+func (f *fakeBackend) Delete(key string) error {
+	delete(f.files, key)
+	return nil
+}
+
+func (f *fakeBackend) Stat(key string) (*FileInfo, error) {
+	size, ok := f.files[key]
+	if !ok {
+		return nil, ErrNotExist
+	}
+	return &FileInfo{Key: key, Size: size}, nil
+}
+
+// bitrateBackend 可读取已有文件码率的内存存储后端
+type bitrateBackend struct {
+	*fakeBackend
+	bitrates map[string]uint
+}
+
+func (b *bitrateBackend) Bitrate(key string) uint { return b.bitrates[key] }
+
+func TestResolve(t *testing.T) {
+	b := &fakeBackend{files: map[string]int64{
+		"a/song.flac":     100,
+		"a/song (1).flac": 100,
+	}}
+	tests := []struct {
+		policy  string
+		key     string
+		size    int64
+		bitrate uint
+		want    string
+	}{
+		// 不存在时任何策略都直接存入
+		{CollisionSkip, "a/new.flac", 1, 0, "a/new.flac"},
+		{CollisionSuffix, "a/new.flac", 1, 0, "a/new.flac"},
+		{CollisionSkip, "a/song.flac", 200, 0, ""},
+		{CollisionOverwrite, "a/song.flac", 1, 0, "a/song.flac"},
+		{CollisionSuffix, "a/song.flac", 1, 0, "a/song (2).flac"},
+		{CollisionKeepLarger, "a/song.flac", 200, 0, "a/song.flac"},
+		{CollisionKeepLarger, "a/song.flac", 100, 0, ""},
+		{CollisionKeepLarger, "a/song.flac", 50, 0, ""},
+		// 后端无法读取已有文件的码率时按文件大小比较
+		{CollisionKeepHigher, "a/song.flac", 200, 320, "a/song.flac"},
+		{CollisionKeepHigher, "a/song.flac", 50, 320, ""},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(b, tt.key, tt.policy, tt.size, tt.bitrate)
+		if err != nil {
+			t.Errorf("Resolve(%s, %s) 错误: %v", tt.policy, tt.key, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Resolve(%s, %s, %d) = %q, 期望 %q", tt.policy, tt.key, tt.size, got, tt.want)
+		}
+	}
+
+	if _, err := Resolve(b, "a/song.flac", "keep-better", 1, 0); err == nil {
+		t.Error("未知重名策略应返回错误")
+	}
+}
+
+func TestResolveKeepHigherBitrate(t *testing.T) {
+	b := &bitrateBackend{
+		fakeBackend: &fakeBackend{files: map[string]int64{"hires.flac": 100, "mp3.mp3": 100, "unknown.m4a": 100}},
+		bitrates:    map[string]uint{"hires.flac": 2000, "mp3.mp3": 128},
+	}
+	tests := []struct {
+		key     string
+		size    int64
+		bitrate uint
+		want    string
+	}{
+		// 按码率比较, 与文件大小无关
+		{"hires.flac", 500, 900, ""},
+		{"hires.flac", 50, 2304, "hires.flac"},
+		{"mp3.mp3", 50, 320, "mp3.mp3"},
+		{"mp3.mp3", 500, 128, ""},
+		// 任一方码率未知时按文件大小比较
+		{"mp3.mp3", 500, 0, "mp3.mp3"},
+		{"unknown.m4a", 50, 256, ""},
+		{"unknown.m4a", 500, 256, "unknown.m4a"},
+	}
+	for _, tt := range tests {
+		got, err := Resolve(b, tt.key, CollisionKeepHigher, tt.size, tt.bitrate)
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%s, %d, %dkbps) = %q, %v, 期望 %q", tt.key, tt.size, tt.bitrate, got, err, tt.want)
+		}
+	}
+}
+
+// writeWAV 生成静音 PCM WAV 文件, 码率为 sampleRate*16/1000 kbps(单声道 16 位)
+func writeWAV(t *testing.T, path string, sampleRate, seconds int) {
+	t.Helper()
+	data := sampleRate * 2 * seconds
+	header := []any{
+		[4]byte{'R', 'I', 'F', 'F'}, uint32(36 + data), [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16), uint16(1), uint16(1), uint32(sampleRate), uint32(sampleRate * 2), uint16(2), uint16(16),
+		[4]byte{'d', 'a', 't', 'a'}, uint32(data),
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for _, v := range header {
+		if err := binary.Write(f, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.Write(make([]byte, data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPlaceKeepHigherBitrateLocal 本地后端读取已有文件的码率, 码率低但更大的文件不覆盖已有文件
+func TestPlaceKeepHigherBitrateLocal(t *testing.T) {
+	local := &Local{Root: t.TempDir()}
+	old := GlobalBackend
+	GlobalBackend = local
+	t.Cleanup(func() { GlobalBackend = old })
+
+	src := t.TempDir()
+	// 已有文件: 1 秒 705.6 kbps
+	writeWAV(t, filepath.Join(src, "hi.wav"), 44100, 1)
+	if _, err := Place(context.Background(), "Test", filepath.Join(src, "hi.wav"), "a/song.wav", CollisionKeepHigher); err != nil {
+		t.Fatal(err)
+	}
+	if got := local.Bitrate("a/song.wav"); got != 706 {
+		t.Fatalf("Bitrate() = %d, 期望 706", got)
+	}
+
+	// 10 秒 128 kbps: 文件更大但码率更低, 跳过
+	writeWAV(t, filepath.Join(src, "lo.wav"), 8000, 10)
+	if key, err := Place(context.Background(), "Test", filepath.Join(src, "lo.wav"), "a/song.wav", CollisionKeepHigher); err != nil || key != "" {
+		t.Errorf("Place(低码率) = %q, %v, 期望跳过", key, err)
+	}
+	// 码率更高时覆盖
+	writeWAV(t, filepath.Join(src, "higher.wav"), 48000, 1)
+	if key, err := Place(context.Background(), "Test", filepath.Join(src, "higher.wav"), "a/song.wav", CollisionKeepHigher); err != nil || key != "a/song.wav" {
+		t.Errorf("Place(高码率) = %q, %v, 期望覆盖", key, err)
+	}
+	if got := local.Bitrate("a/song.wav"); got != 768 {
+		t.Errorf("覆盖后 Bitrate() = %d, 期望 768", got)
+	}
+}
+
+func TestResolveSuffixExhausted(t *testing.T) {
+	b := &fakeBackend{files: map[string]int64{"x.mp3": 1}}
+	for i := 1; i <= maxSuffix; i++ {
+		b.files[fmt.Sprintf("x (%d).mp3", i)] = 1
+	}
+	if _, err := Resolve(b, "x.mp3", CollisionSuffix, 1, 0); err == nil {
+		t.Error("序号用尽时应返回错误")
+	}
+}
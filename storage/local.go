@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nichuanfang/gymdl/config"
+)
+
+// 本地存储: 整理到 tidy.dist_dir, 同盘移动、跨盘复制
+
+func init() {
+	Register("local", func(cfg *config.Config) (Backend, error) {
+		if cfg.Tidy.DistDir == "" {
+			return nil, errors.New("未配置输出目录")
+		}
+		return &Local{Root: cfg.Tidy.DistDir}, nil
+	})
+}
+
+// Local 本地目录存储
+type Local struct {
+	Root string // 根目录
+}
+
+func (l *Local) Name() string {
+	return "local"
+}
+
+func (l *Local) Put(ctx context.Context, localPath, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	dst := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("创建输出目录失败: %w", err)
+	}
+	return moveFile(localPath, dst)
+}
+
+func (l *Local) Exists(key string) (bool, error) {
+	_, err := os.Stat(l.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *Local) List(dir string) ([]FileInfo, error) {
+	entries, err := os.ReadDir(l.path(dir))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]FileInfo, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{
+			Key:     CleanKey(dir + "/" + e.Name()),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			IsDir:   e.IsDir(),
+		})
+	}
+	return files, nil
+}
+
+func (l *Local) Delete(key string) error {
+	return os.Remove(l.path(key))
+}
+
+func (l *Local) Stat(key string) (*FileInfo, error) {
+	info, err := os.Stat(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &FileInfo{Key: CleanKey(key), Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}, nil
+}
+
+func (l *Local) Bitrate(key string) uint {
+	return AudioBitrate(l.path(key))
+}
+
+// path key 对应的本地路径
+func (l *Local) path(key string) string {
+	return filepath.Join(l.Root, filepath.FromSlash(CleanKey(key)))
+}
+
+/* ---------------------- 文件移动 ---------------------- */
+
+// moveFile 同盘直接重命名, 跨盘复制后删除源文件
+func moveFile(src, dst string) error {
+	if sameDrive(src, dst) {
+		return os.Rename(src, dst)
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile 使用缓冲区复制文件，确保文件句柄关闭
+func copyFile(src, dst string) error {
+	input, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	output, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	// 使用 1MB 缓冲区
+	buf := make([]byte, 1024*1024)
+	_, err = io.CopyBuffer(output, input, buf)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// sameDrive 判断两个路径是否在同一个磁盘或 UNC 网络共享
+func sameDrive(path1, path2 string) bool {
+	abs1, err1 := filepath.Abs(path1)
+	abs2, err2 := filepath.Abs(path2)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	// 本地盘符比较 (C:, D:...)
+	if len(abs1) >= 2 && len(abs2) >= 2 && abs1[1] == ':' && abs2[1] == ':' {
+		return strings.EqualFold(abs1[:2], abs2[:2])
+	}
+
+	// UNC 网络路径比较 (\\NAS\share)
+	if strings.HasPrefix(abs1, `\\`) && strings.HasPrefix(abs2, `\\`) {
+		parts1 := strings.SplitN(abs1, `\`, 4)
+		parts2 := strings.SplitN(abs2, `\`, 4)
+		if len(parts1) >= 3 && len(parts2) >= 3 {
+			return strings.EqualFold(parts1[1], parts2[1]) && strings.EqualFold(parts1[2], parts2[2])
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+// S3 兼容对象存储: 上传到 s3.bucket 的 s3.prefix 下, 大文件分片上传
+
+func init() {
+	Register("s3", func(cfg *config.Config) (Backend, error) {
+		return NewS3(cfg.S3)
+	})
+}
+
+// s3CheckTimeout 连接检测超时
+const s3CheckTimeout = 10 * time.Second
+
+// S3 S3 兼容对象存储
+type S3 struct {
+	Config          *config.S3Config
+	Client          *minio.Client
+	lastCheck       time.Time
+	lastCheckResult bool
+	checkMutex      sync.Mutex
+}
+
+// NewS3 按配置的地址创建 S3 存储
+func NewS3(cfg *config.S3Config) (*S3, error) {
+	if cfg == nil || cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("未配置 S3 地址或存储桶")
+	}
+	endpoint := cfg.Endpoint
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("S3 地址无效: %s", cfg.Endpoint)
+	}
+
+	lookup := minio.BucketLookupAuto
+	if cfg.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure:       u.Scheme == "https",
+		Region:       cfg.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建 S3 客户端失败: %w", err)
+	}
+	return NewS3WithClient(cfg, client), nil
+}
+
+// NewS3WithClient 使用已创建的客户端(如自定义 Transport 或测试用的本地服务)创建 S3 存储
+func NewS3WithClient(cfg *config.S3Config, client *minio.Client) *S3 {
+	return &S3{Config: cfg, Client: client}
+}
+
+func (s *S3) Name() string {
+	return "s3"
+}
+
+// CheckConnection 检测存储桶是否可访问, 一分钟内复用上次结果
+func (s *S3) CheckConnection() bool {
+	s.checkMutex.Lock()
+	defer s.checkMutex.Unlock()
+
+	if time.Since(s.lastCheck) < time.Minute {
+		return s.lastCheckResult
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s3CheckTimeout)
+	defer cancel()
+	ok, err := s.Client.BucketExists(ctx, s.Config.Bucket)
+	if err == nil && !ok {
+		err = fmt.Errorf("存储桶不存在: %s", s.Config.Bucket)
+	}
+	s.lastCheck = time.Now()
+	s.lastCheckResult = err == nil
+
+	if err != nil {
+		utils.WarnWithFormat("[S3] ⚠️ 连接检测失败: %v", err)
+	}
+	return s.lastCheckResult
+}
+
+func (s *S3) Put(ctx context.Context, localPath, key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("读取本地文件信息失败: %w", err)
+	}
+
+	contentType := mime.TypeByExtension(strings.ToLower(path.Ext(key)))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// 超过分片大小时自动分片上传, ctx 取消时中止上传
+	_, err = s.Client.PutObject(ctx, s.Config.Bucket, s.object(key), file, info.Size(), minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    uint64(s.Config.PartSize) << 20,
+	})
+	if err != nil && ctx.Err() != nil {
+		// 客户端使用已取消的 ctx 放弃分片上传会失败, 另行清理已上传的分片
+		cleanupCtx, cancel := context.WithTimeout(context.Background(), s3CheckTimeout)
+		defer cancel()
+		if rmErr := s.Client.RemoveIncompleteUpload(cleanupCtx, s.Config.Bucket, s.object(key)); rmErr != nil {
+			utils.WarnWithFormat("[S3] ⚠️ 清理未完成的分片上传失败 %s: %v", key, rmErr)
+		}
+		return ctx.Err()
+	}
+	return err
+}
+
+func (s *S3) Exists(key string) (bool, error) {
+	_, err := s.Stat(key)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *S3) List(dir string) ([]FileInfo, error) {
+	prefix := s.object(dir)
+	if prefix != "" {
+		prefix += "/"
+	}
+	files := make([]FileInfo, 0)
+	for obj := range s.Client.ListObjects(context.Background(), s.Config.Bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		// 以 / 结尾的为公共前缀(子目录)
+		name := strings.TrimPrefix(obj.Key, prefix)
+		files = append(files, FileInfo{
+			Key:     CleanKey(dir + "/" + name),
+			Size:    obj.Size,
+			ModTime: obj.LastModified,
+			IsDir:   strings.HasSuffix(obj.Key, "/"),
+		})
+	}
+	return files, nil
+}
+
+func (s *S3) Delete(key string) error {
+	return s.Client.RemoveObject(context.Background(), s.Config.Bucket, s.object(key), minio.RemoveObjectOptions{})
+}
+
+func (s *S3) Stat(key string) (*FileInfo, error) {
+	obj, err := s.Client.StatObject(context.Background(), s.Config.Bucket, s.object(key), minio.StatObjectOptions{})
+	if err != nil {
+		switch minio.ToErrorResponse(err).Code {
+		case "NoSuchKey", "NotFound":
+			return nil, ErrNotExist
+		}
+		return nil, err
+	}
+	return &FileInfo{Key: CleanKey(key), Size: obj.Size, ModTime: obj.LastModified}, nil
+}
+
+// object key 对应的对象名(位于 prefix 下)
+func (s *S3) object(key string) string {
+	return strings.TrimPrefix(path.Join(strings.Trim(s.Config.Prefix, "/"), CleanKey(key)), "/")
+}
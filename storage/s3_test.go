@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+)
+
+func TestMain(m *testing.M) {
+	_ = utils.InitLogger(&config.LogConfig{Mode: 1, Level: 4})
+	os.Exit(m.Run())
+}
+
+const testBucket = "music"
+
+// fakeS3 本地 S3 服务(gofakes3), 记录分片上传请求
+type fakeS3 struct {
+	*S3
+	parts   atomic.Int32 // 已上传的分片数
+	onPart  func()       // 每个分片上传完成后回调
+	handler http.Handler
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.handler.ServeHTTP(w, r)
+	if r.Method == http.MethodPut && r.URL.Query().Has("partNumber") {
+		f.parts.Add(1)
+		if f.onPart != nil {
+			f.onPart()
+		}
+	}
+}
+
+// newFakeS3 启动本地 S3 服务并注入客户端; 使用 HTTPS, 避免明文时 minio 采用 aws-chunked 流式签名(gofakes3 不解析分片上传中的签名块)
+func newFakeS3(t *testing.T, prefix string, partSize int) *fakeS3 {
+	t.Helper()
+	f := &fakeS3{handler: gofakes3.New(s3mem.New()).Server()}
+	ts := httptest.NewTLSServer(f)
+	t.Cleanup(ts.Close)
+
+	u, _ := url.Parse(ts.URL)
+	client, err := minio.New(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4("key", "secret", ""),
+		Secure:       true,
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+		Transport:    ts.Client().Transport,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.MakeBucket(context.Background(), testBucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	f.S3 = NewS3WithClient(&config.S3Config{Bucket: testBucket, Prefix: prefix, PartSize: partSize}, client)
+	return f
+}
+
+// localFile 创建指定大小的本地文件
+func localFile(t *testing.T, name string, size int) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, bytes.Repeat([]byte("a"), size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNewS3(t *testing.T) {
+	for _, cfg := range []*config.S3Config{nil, {Bucket: "b"}, {Endpoint: "http://s3"}, {Endpoint: "http://", Bucket: "b"}} {
+		if _, err := NewS3(cfg); err == nil {
+			t.Errorf("NewS3(%+v) 应返回错误", cfg)
+		}
+	}
+	s, err := NewS3(&config.S3Config{Endpoint: "minio:9000", Bucket: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未指定协议时使用 https
+	if u := s.Client.EndpointURL(); u.Scheme != "https" || u.Host != "minio:9000" {
+		t.Errorf("地址 = %s, 期望 https://minio:9000", u)
+	}
+
+	// 按配置的地址连接本地服务
+	ts := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	defer ts.Close()
+	s, err = NewS3(&config.S3Config{Endpoint: ts.URL, Region: "us-east-1", Bucket: testBucket, AccessKey: "key", SecretKey: "secret", PathStyle: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Client.MakeBucket(context.Background(), testBucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.CheckConnection() {
+		t.Error("连接检测失败")
+	}
+	if err := s.Put(context.Background(), localFile(t, "f", 10), "a.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := s.Stat("a.mp3"); err != nil || info.Size != 10 {
+		t.Errorf("Stat() = %+v, %v", info, err)
+	}
+}
+
+func TestS3PutStat(t *testing.T) {
+	s := newFakeS3(t, "", 5)
+	ctx := context.Background()
+
+	tests := []struct {
+		key         string
+		contentType string
+	}{
+		{"Artist/Album/cover.jpg", "image/jpeg"},
+		{"Artist/Album/01 Song.gymdl", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		if err := s.Put(ctx, localFile(t, "f", 1024), tt.key); err != nil {
+			t.Fatalf("Put(%s): %v", tt.key, err)
+		}
+		info, err := s.Stat(tt.key)
+		if err != nil {
+			t.Fatalf("Stat(%s): %v", tt.key, err)
+		}
+		if info.Key != tt.key || info.Size != 1024 || info.IsDir {
+			t.Errorf("Stat(%s) = %+v", tt.key, info)
+		}
+		obj, err := s.Client.StatObject(ctx, testBucket, tt.key, minio.StatObjectOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if obj.ContentType != tt.contentType {
+			t.Errorf("%s Content-Type = %q, 期望 %q", tt.key, obj.ContentType, tt.contentType)
+		}
+	}
+	// 小文件不分片
+	if n := s.parts.Load(); n != 0 {
+		t.Errorf("小文件上传了 %d 个分片", n)
+	}
+
+	if _, err := s.Stat("Artist/Album/missing.jpg"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Stat 不存在的 key 应返回 ErrNotExist, 得到 %v", err)
+	}
+	if ok, err := s.Exists("Artist/Album/cover.jpg"); !ok || err != nil {
+		t.Errorf("Exists() = %v, %v, 期望 true", ok, err)
+	}
+	if ok, err := s.Exists("Artist/Album/missing.jpg"); ok || err != nil {
+		t.Errorf("Exists() = %v, %v, 期望 false", ok, err)
+	}
+
+	if err := s.Delete("Artist/Album/cover.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Exists("Artist/Album/cover.jpg"); ok {
+		t.Error("删除后仍然存在")
+	}
+}
+
+func TestS3List(t *testing.T) {
+	s := newFakeS3(t, "", 5)
+	for _, key := range []string{"a/x.mp3", "a/y.lrc", "a/b/z.mp3", "c/w.mp3"} {
+		if err := s.Put(context.Background(), localFile(t, "f", 10), key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := s.List("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Key < files[j].Key })
+	want := []FileInfo{{Key: "a/b", IsDir: true}, {Key: "a/x.mp3", Size: 10}, {Key: "a/y.lrc", Size: 10}}
+	if len(files) != len(want) {
+		t.Fatalf("List(a) = %+v, 期望 %d 项", files, len(want))
+	}
+	for i, w := range want {
+		if files[i].Key != w.Key || files[i].IsDir != w.IsDir || files[i].Size != w.Size {
+			t.Errorf("List(a)[%d] = %+v, 期望 %+v", i, files[i], w)
+		}
+	}
+
+	// 根目录
+	root, err := s.List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root) != 2 || !root[0].IsDir || !root[1].IsDir {
+		t.Errorf("List(\"\") = %+v, 期望 a、c 两个目录", root)
+	}
+}
+
+func TestS3Prefix(t *testing.T) {
+	s := newFakeS3(t, "/library/music/", 5)
+	ctx := context.Background()
+	if err := s.Put(ctx, localFile(t, "f", 10), "/Artist/../Artist/song.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	// 对象位于前缀下, key 规范化后不能逃逸出前缀
+	if _, err := s.Client.StatObject(ctx, testBucket, "library/music/Artist/song.mp3", minio.StatObjectOptions{}); err != nil {
+		t.Errorf("对象未存入前缀下: %v", err)
+	}
+	if err := s.Put(ctx, localFile(t, "f", 10), "../../escape.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Client.StatObject(ctx, testBucket, "library/music/escape.mp3", minio.StatObjectOptions{}); err != nil {
+		t.Errorf("key 逃逸出前缀: %v", err)
+	}
+
+	// 对外的 key 不包含前缀
+	info, err := s.Stat("Artist/song.mp3")
+	if err != nil || info.Key != "Artist/song.mp3" {
+		t.Errorf("Stat() = %+v, %v", info, err)
+	}
+	files, err := s.List("Artist")
+	if err != nil || len(files) != 1 || files[0].Key != "Artist/song.mp3" {
+		t.Errorf("List(Artist) = %+v, %v", files, err)
+	}
+	if err := s.Delete("Artist/song.mp3"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := s.Exists("Artist/song.mp3"); ok {
+		t.Error("删除后仍然存在")
+	}
+}
+
+func TestS3Multipart(t *testing.T) {
+	s := newFakeS3(t, "lib", 5)
+	const size = 11 << 20 // 分片大小 5MB, 共 3 个分片
+	if err := s.Put(context.Background(), localFile(t, "big.flac", size), "big.flac"); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.parts.Load(); n != 3 {
+		t.Errorf("上传了 %d 个分片, 期望 3 个", n)
+	}
+	info, err := s.Stat("big.flac")
+	if err != nil || info.Size != size {
+		t.Errorf("Stat() = %+v, %v", info, err)
+	}
+}
+
+func TestS3MultipartCancel(t *testing.T) {
+	s := newFakeS3(t, "lib", 5)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// 第一个分片上传后取消任务
+	s.onPart = cancel
+
+	err := s.Put(ctx, localFile(t, "big.flac", 11<<20), "big.flac")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Put() = %v, 期望 context.Canceled", err)
+	}
+	if ok, _ := s.Exists("big.flac"); ok {
+		t.Error("取消后对象不应存在")
+	}
+	// 未完成的分片上传已清理
+	uploads, err := minio.Core{Client: s.Client}.ListMultipartUploads(context.Background(), testBucket, "lib/", "", "", "", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uploads.Uploads) != 0 {
+		t.Errorf("残留 %d 个未完成的分片上传", len(uploads.Uploads))
+	}
+}
+
+func TestStorePlaceCancelled(t *testing.T) {
+	s := newFakeS3(t, "", 5)
+	old := GlobalBackend
+	GlobalBackend = s.S3
+	t.Cleanup(func() { GlobalBackend = old })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Place(ctx, "Test", localFile(t, "f", 10), "a.mp3", CollisionOverwrite); !errors.Is(err, context.Canceled) {
+		t.Errorf("Place() = %v, 期望 context.Canceled", err)
+	}
+	if ok, _ := s.Exists("a.mp3"); ok {
+		t.Error("取消后对象不应存在")
+	}
+	if _, err := Place(context.Background(), "Test", localFile(t, "f", 10), "a.mp3", CollisionSkip); err != nil {
+		t.Fatal(err)
+	}
+	key, err := Place(context.Background(), "Test", localFile(t, "f", 10), "a.mp3", CollisionSuffix)
+	if err != nil || key != "a (1).mp3" {
+		t.Errorf("Place() = %q, %v, 期望 a (1).mp3", key, err)
+	}
+}
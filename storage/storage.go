@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/utils"
+	"go.senan.xyz/taglib"
+)
+
+// 资源存储后端: 处理器与目录监控整理文件时统一调用, 新增后端只需实现 Backend 并注册
+
+/* ---------------------- 接口定义 ---------------------- */
+
+// Backend 存储后端, key 为相对于存储根目录的路径(以 / 分隔)
+type Backend interface {
+	// Name 后端名称, 与配置中的 tidy.backend 一致
+	Name() string
+	// Put 将本地文件存入 key, 成功后本地文件可能已被移动; ctx 取消时中止上传
+	Put(ctx context.Context, localPath, key string) error
+	// Exists key 是否存在
+	Exists(key string) (bool, error)
+	// List 列出目录下的文件与子目录
+	List(dir string) ([]FileInfo, error)
+	// Delete 删除 key
+	Delete(key string) error
+	// Stat 获取 key 的文件信息, 不存在时返回 ErrNotExist
+	Stat(key string) (*FileInfo, error)
+}
+
+// Checker 支持连接检测的后端(启动时检测)
+type Checker interface {
+	CheckConnection() bool
+}
+
+// BitrateReader 可读取已有文件码率的后端(重名策略 keep-higher-bitrate 使用)
+type BitrateReader interface {
+	// Bitrate key 对应音频的码率(kbps), 无法读取时为 0
+	Bitrate(key string) uint
+}
+
+// FileInfo 存储中的文件信息
+type FileInfo struct {
+	Key     string    `json:"key"`      // 相对路径
+	Size    int64     `json:"size"`     // 文件大小
+	ModTime time.Time `json:"mod_time"` // 修改时间
+	IsDir   bool      `json:"is_dir"`   // 是否为目录
+}
+
+// Factory 后端构造函数
+type Factory func(cfg *config.Config) (Backend, error)
+
+// ErrNotExist 文件不存在
+var ErrNotExist = errors.New("文件不存在")
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+
+	// GlobalBackend 当前使用的存储后端
+	GlobalBackend Backend
+)
+
+/* ---------------------- 注册与初始化 ---------------------- */
+
+// Register 注册存储后端, 同名后端重复注册时 panic
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("storage: %s 重复注册", name))
+	}
+	factories[name] = factory
+}
+
+// Names 已注册的后端名称
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 按名称创建存储后端
+func New(cfg *config.Config) (Backend, error) {
+	name := strings.ToLower(cfg.Tidy.Backend)
+	registryMu.RLock()
+	factory, ok := factories[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知存储后端: %s(可选: %s)", cfg.Tidy.Backend, strings.Join(Names(), ", "))
+	}
+	return factory(cfg)
+}
+
+// Init 初始化全局存储后端
+func Init(cfg *config.Config) error {
+	switch cfg.Tidy.Collision {
+	case CollisionSkip, CollisionOverwrite, CollisionSuffix, CollisionKeepHigher, CollisionKeepLarger:
+	default:
+		return fmt.Errorf("未知重名策略: %s", cfg.Tidy.Collision)
+	}
+	backend, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	GlobalBackend = backend
+	return nil
+}
+
+/* ---------------------- 整理 ---------------------- */
+
+// Store 将本地文件存入全局存储后端并记录日志, tag 为日志前缀
+func Store(ctx context.Context, tag, localPath, key string) error {
+	if GlobalBackend == nil {
+		return errors.New("存储后端未初始化")
+	}
+	key = CleanKey(key)
+	if err := GlobalBackend.Put(ctx, localPath, key); err != nil {
+		utils.WarnWithFormat("[%s] ⚠️ 整理失败 %s → %s:%s: %v", tag, localPath, GlobalBackend.Name(), key, err)
+		return err
+	}
+	utils.InfoWithFormat("[%s] 📦 已整理: %s:%s", tag, GlobalBackend.Name(), key)
+	return nil
+}
+
+// Place 按重名策略将本地文件存入 key, 返回实际存入的 key, 跳过时返回空字符串
+func Place(ctx context.Context, tag, localPath, key, policy string) (string, error) {
+	if GlobalBackend == nil {
+		return "", errors.New("存储后端未初始化")
+	}
+	key = CleanKey(key)
+	var size int64
+	if info, err := os.Stat(localPath); err == nil {
+		size = info.Size()
+	}
+	var bitrate uint
+	if policy == CollisionKeepHigher {
+		bitrate = AudioBitrate(localPath)
+	}
+	final, err := Resolve(GlobalBackend, key, policy, size, bitrate)
+	if err != nil {
+		return "", err
+	}
+	if final == "" {
+		utils.InfoWithFormat("[%s] ⏭️ 已存在同名文件, 跳过: %s:%s", tag, GlobalBackend.Name(), key)
+		return "", nil
+	}
+	return final, Store(ctx, tag, localPath, final)
+}
+
+// AudioBitrate 读取本地音频文件的码率(kbps), 无法读取(如视频、图片)时为 0
+func AudioBitrate(path string) uint {
+	props, err := taglib.ReadProperties(path)
+	if err != nil {
+		return 0
+	}
+	return props.Bitrate
+}
+
+// TidyType 整理类型, 记录在歌曲/视频信息与曲库中
+func TidyType(cfg *config.Config) string {
+	return strings.ToUpper(cfg.Tidy.Backend)
+}
+
+// CleanKey 规范化 key: 统一使用 /, 去除开头的 / 与 ..
+func CleanKey(key string) string {
+	key = path.Clean("/" + strings.ReplaceAll(key, "\\", "/"))
+	return strings.TrimPrefix(key, "/")
+}
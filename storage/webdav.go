@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/nichuanfang/gymdl/config"
+	"github.com/nichuanfang/gymdl/core"
+	"github.com/studio-b12/gowebdav"
+)
+
+// WebDAV 存储: 上传到 webdav.webdav_dir
+
+func init() {
+	Register("webdav", func(cfg *config.Config) (Backend, error) {
+		if cfg.WebDAV == nil || cfg.WebDAV.WebDAVUrl == "" {
+			return nil, errors.New("未配置 WebDAV 地址")
+		}
+		if core.GlobalWebDAV == nil {
+			core.InitWebDAV(cfg.WebDAV)
+		}
+		return &WebDAV{dav: core.GlobalWebDAV}, nil
+	})
+}
+
+// WebDAV WebDAV 存储
+type WebDAV struct {
+	dav *core.WebDAV
+}
+
+func (w *WebDAV) Name() string {
+	return "webdav"
+}
+
+func (w *WebDAV) CheckConnection() bool {
+	return w.dav.CheckConnection()
+}
+
+func (w *WebDAV) Put(ctx context.Context, localPath, key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return fmt.Errorf("打开本地文件失败: %w", err)
+	}
+	defer file.Close()
+
+	remote := w.path(key)
+	if err := w.dav.Client.MkdirAll(path.Dir(remote), 0755); err != nil {
+		return fmt.Errorf("创建远程目录失败: %w", err)
+	}
+	// gowebdav 不支持 context, 取消后读取报错以中断上传
+	body := &ctxReader{ctx: ctx, r: file}
+	if info, err := file.Stat(); err == nil {
+		err = w.dav.Client.WriteStreamWithLength(remote, body, info.Size(), 0644)
+	} else {
+		err = w.dav.Client.WriteStream(remote, body, 0644)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (w *WebDAV) Exists(key string) (bool, error) {
+	_, err := w.Stat(key)
+	if errors.Is(err, ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (w *WebDAV) List(dir string) ([]FileInfo, error) {
+	entries, err := w.dav.Client.ReadDir(w.path(dir))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]FileInfo, 0, len(entries))
+	for _, e := range entries {
+		files = append(files, FileInfo{
+			Key:     CleanKey(dir + "/" + e.Name()),
+			Size:    e.Size(),
+			ModTime: e.ModTime(),
+			IsDir:   e.IsDir(),
+		})
+	}
+	return files, nil
+}
+
+func (w *WebDAV) Delete(key string) error {
+	return w.dav.Client.Remove(w.path(key))
+}
+
+func (w *WebDAV) Stat(key string) (*FileInfo, error) {
+	info, err := w.dav.Client.Stat(w.path(key))
+	if gowebdav.IsErrNotFound(err) {
+		return nil, ErrNotExist
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &FileInfo{Key: CleanKey(key), Size: info.Size(), ModTime: info.ModTime(), IsDir: info.IsDir()}, nil
+}
+
+// path key 对应的远程路径(位于 webdav_dir 下)
+func (w *WebDAV) path(key string) string {
+	return path.Join("/", strings.Trim(w.dav.Config.WebDAVDir, "/"), CleanKey(key))
+}
+
+// ctxReader ctx 取消后读取返回 ctx.Err()
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
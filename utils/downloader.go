@@ -2,6 +2,7 @@ package utils
 
 import (
 	"bufio"
+	"context"
 	"crypto/md5"
 	"crypto/sha256"
 	"crypto/tls"
@@ -80,6 +81,7 @@ type DownloadOptions struct {
 	ChecksumType  string                  // 校验和类型: "md5" | "sha256" | ""(不校验)
 	ExpectedHash  string                  // 期望的校验和值
 	ProgressFunc  func(*DownloadProgress) // 进度回调函数
+	OnProgress    ProgressFunc            // 结构化进度回调(下载中限频 1 秒)
 	MaxRetries    int                     // 最大重试次数(默认3次)
 	RetryInterval time.Duration           // 重试间隔(默认5秒)
 	ChunkSize     int                     // 分块大小(默认4MB)
@@ -91,10 +93,13 @@ type DownloadManager struct {
 	progress   *DownloadProgress
 	options    *DownloadOptions
 	stopChan   chan struct{}
+	ctx        context.Context // 停止下载时取消,中断进行中的HTTP请求
+	cancel     context.CancelFunc
 	pauseChan  chan struct{}
 	resumeChan chan struct{}
 	mutex      sync.RWMutex
 	startTime  time.Time
+	lastEmit   time.Time // 上次上报结构化进度的时间
 	lastBytes  int64
 	chunkSize  int
 	retries    int
@@ -134,10 +139,13 @@ func NewDownloader(url string, options *DownloadOptions) (*DownloadManager, erro
 	client := createHTTPClient(options.Timeout, options.IgnoreSSL)
 
 	// 初始化下载管理器
+	ctx, cancel := context.WithCancel(context.Background())
 	manager := &DownloadManager{
 		client:     client,
 		options:    options,
 		stopChan:   make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
 		pauseChan:  make(chan struct{}),
 		resumeChan: make(chan struct{}, 1), // 使用缓冲通道避免阻塞
 		progress: &DownloadProgress{
@@ -157,9 +165,39 @@ func NewDownloader(url string, options *DownloadOptions) (*DownloadManager, erro
 
 // 开始下载
 func (dm *DownloadManager) Start() error {
+	if err := dm.begin(); err != nil {
+		return err
+	}
+	// 异步执行下载
+	go func() {
+		_ = dm.run()
+	}()
+	return nil
+}
+
+// 同步下载,ctx 取消时停止下载并返回 ctx 的错误; 返回时下载协程已退出,不会再写入文件
+func (dm *DownloadManager) RunContext(ctx context.Context) error {
+	if err := dm.begin(); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = dm.Stop()
+	})
+	defer stop()
+	if err := dm.run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// begin 初始化下载状态
+func (dm *DownloadManager) begin() error {
 	dm.mutex.Lock()
+	defer dm.mutex.Unlock()
 	if dm.progress.Status == StatusDownloading {
-		dm.mutex.Unlock()
 		return errors.New("下载已经在进行中")
 	}
 	dm.progress.Status = StatusDownloading
@@ -170,23 +208,23 @@ func (dm *DownloadManager) Start() error {
 	dm.progress.FormattedSize = FormatBytes(dm.progress.TotalBytes)
 	dm.progress.FormattedDownloaded = FormatBytes(dm.progress.Downloaded)
 	dm.progress.FormattedSpeed = FormatSpeed(dm.progress.Speed)
-	dm.mutex.Unlock()
+	return nil
+}
 
-	// 异步执行下载
-	go func() {
-		if err := dm.doDownload(); err != nil {
-			dm.mutex.Lock()
-			dm.progress.Status = StatusFailed
-			dm.progress.ErrorMessage = err.Error()
-			dm.mutex.Unlock()
+// run 执行下载,失败时记录错误并回调进度
+func (dm *DownloadManager) run() error {
+	err := dm.doDownload()
+	if err != nil {
+		dm.mutex.Lock()
+		dm.progress.Status = StatusFailed
+		dm.progress.ErrorMessage = err.Error()
+		dm.mutex.Unlock()
 
-			if dm.options.ProgressFunc != nil {
-				dm.options.ProgressFunc(dm.progress)
-			}
+		if dm.options.ProgressFunc != nil {
+			dm.options.ProgressFunc(dm.progress)
 		}
-	}()
-
-	return nil
+	}
+	return err
 }
 
 // 暂停下载
@@ -222,16 +260,7 @@ func (dm *DownloadManager) Resume() error {
 	case dm.resumeChan <- struct{}{}:
 		// 重新启动下载
 		go func() {
-			if err := dm.doDownload(); err != nil {
-				dm.mutex.Lock()
-				dm.progress.Status = StatusFailed
-				dm.progress.ErrorMessage = err.Error()
-				dm.mutex.Unlock()
-
-				if dm.options.ProgressFunc != nil {
-					dm.options.ProgressFunc(dm.progress)
-				}
-			}
+			_ = dm.run()
 		}()
 		return nil
 	default:
@@ -253,6 +282,7 @@ func (dm *DownloadManager) Stop() error {
 
 	// 关闭通道
 	close(dm.stopChan)
+	dm.cancel()
 
 	return nil
 }
@@ -271,6 +301,38 @@ func (dm *DownloadManager) GetProgress() *DownloadProgress {
 	return &progress
 }
 
+// Event 转换为结构化进度事件
+func (p *DownloadProgress) Event(item string) Progress {
+	e := Progress{
+		Phase:      PhaseDownload,
+		Item:       item,
+		Bytes:      p.Downloaded,
+		TotalBytes: p.TotalBytes,
+		Speed:      p.Speed * 1024, // KB/s -> 字节/秒
+	}
+	if e.Speed > 0 && p.TotalBytes > p.Downloaded {
+		e.ETA = int(float64(p.TotalBytes-p.Downloaded) / e.Speed)
+	}
+	return e
+}
+
+// emitProgress 上报结构化进度, force 为 false 时限频 1 秒
+func (dm *DownloadManager) emitProgress(force bool) {
+	if dm.options.OnProgress == nil {
+		return
+	}
+	dm.mutex.Lock()
+	now := time.Now()
+	if !force && now.Sub(dm.lastEmit) < time.Second {
+		dm.mutex.Unlock()
+		return
+	}
+	dm.lastEmit = now
+	e := dm.progress.Event(dm.options.FileName)
+	dm.mutex.Unlock()
+	dm.options.OnProgress(e)
+}
+
 // 执行实际下载
 func (dm *DownloadManager) doDownload() error {
 	// 获取文件信息，确定保存路径和文件名
@@ -294,6 +356,7 @@ func (dm *DownloadManager) doDownload() error {
 	if dm.options.ProgressFunc != nil {
 		go dm.options.ProgressFunc(dm.GetProgress())
 	}
+	dm.emitProgress(true)
 
 	for {
 		// 尝试下载
@@ -312,6 +375,7 @@ func (dm *DownloadManager) doDownload() error {
 			if dm.options.ProgressFunc != nil {
 				dm.options.ProgressFunc(dm.GetProgress())
 			}
+			dm.emitProgress(true)
 
 			return nil
 		}
@@ -360,7 +424,7 @@ func (dm *DownloadManager) downloadWithResume(savePath string, existingSize int6
 		FormatBytes(existingSize))
 
 	// 创建请求前，先检查URL是否有效（包括重定向处理）
-	req, err := http.NewRequest("GET", dm.progress.URL, nil)
+	req, err := http.NewRequestWithContext(dm.ctx, "GET", dm.progress.URL, nil)
 	if err != nil {
 		return &DownloadError{
 			Code:    ErrCodeNetworkError,
@@ -561,6 +625,7 @@ func (dm *DownloadManager) downloadWithResume(savePath string, existingSize int6
 		if dm.options.ProgressFunc != nil {
 			go dm.options.ProgressFunc(dm.GetProgress())
 		}
+		dm.emitProgress(false)
 	}
 
 	return nil
@@ -60,6 +60,31 @@ func FetchImage(url string) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// FetchImageOriginal 下载原始分辨率图片(不缩放,仅校验图片格式)
+func FetchImageOriginal(url string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	// 原图体积较大,放宽读取上限
+	const maxImageSize = 20 << 20
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err = image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
 // DownloadAndSaveImage 从指定 URL 下载图片，并保存为 JPEG 文件到 path
 func DownloadAndSaveImage(url, path string) error {
 	// 调用之前的 FetchImage 获取图片字节
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 结构化下载进度: 处理器与下载器上报事件, Telegram、Web 接口与日志各自渲染
+
+/* ---------------------- 常量 ---------------------- */
+
+// ProgressPhase 进度阶段
+type ProgressPhase string
+
+const (
+	PhaseResolve  ProgressPhase = "resolve"  // 解析链接/获取列表
+	PhaseDownload ProgressPhase = "download" // 下载中
+	PhaseDecrypt  ProgressPhase = "decrypt"  // 解密中
+	PhaseTidy     ProgressPhase = "tidy"     // 整理入库中
+	PhaseDone     ProgressPhase = "done"     // 下载完成(汇总)
+)
+
+/* ---------------------- 结构体定义 ---------------------- */
+
+// Progress 进度事件, 未知的字段保持零值
+type Progress struct {
+	JobID      uint64        `json:"job_id,omitempty"`      // 所属任务ID, 由任务队列填充
+	Phase      ProgressPhase `json:"phase"`                 // 阶段
+	Item       string        `json:"item,omitempty"`        // 当前条目(歌曲/视频标题或文件名)
+	Current    int           `json:"current,omitempty"`     // 当前条目序号(从1开始)
+	Total      int           `json:"total,omitempty"`       // 条目总数
+	Bytes      int64         `json:"bytes,omitempty"`       // 当前条目已下载字节数
+	TotalBytes int64         `json:"total_bytes,omitempty"` // 当前条目总字节数
+	Speed      float64       `json:"speed,omitempty"`       // 下载速度(字节/秒)
+	ETA        int           `json:"eta,omitempty"`         // 当前条目剩余时间(秒)
+	Message    string        `json:"message,omitempty"`     // 进度描述
+}
+
+// ProgressFunc 进度回调
+type ProgressFunc func(Progress)
+
+// Emit 上报进度, 回调为空时忽略
+func (f ProgressFunc) Emit(p Progress) {
+	if f != nil {
+		f(p)
+	}
+}
+
+// Message 上报仅含描述的进度
+func (f ProgressFunc) Message(phase ProgressPhase, format string, args ...any) {
+	f.Emit(Progress{Phase: phase, Message: fmt.Sprintf(format, args...)})
+}
+
+/* ---------------------- 渲染 ---------------------- */
+
+// Percent 当前条目进度百分比, 总字节数未知时返回 -1
+func (p Progress) Percent() float64 {
+	if p.TotalBytes <= 0 {
+		return -1
+	}
+	return min(float64(p.Bytes)/float64(p.TotalBytes)*100, 100)
+}
+
+// Fraction 整体进度(0-1): 已完成的条目加上当前条目的字节进度, 无法估算时返回 -1
+func (p Progress) Fraction() float64 {
+	item := p.Percent() / 100
+	if p.Total > 0 && p.Current > 0 {
+		if item < 0 {
+			item = 0
+		}
+		return min((float64(p.Current-1)+item)/float64(p.Total), 1)
+	}
+	return item
+}
+
+// String 单行文本描述, 用于日志
+func (p Progress) String() string {
+	parts := make([]string, 0, 5)
+	head := p.Message
+	switch {
+	case head == "":
+		head = p.Item
+	case p.Item != "":
+		head += ": " + p.Item
+	}
+	if p.Total > 1 && p.Current > 0 {
+		head = fmt.Sprintf("[%d/%d] %s", p.Current, p.Total, head)
+	}
+	parts = append(parts, head)
+	if pct := p.Percent(); pct >= 0 {
+		parts = append(parts, fmt.Sprintf("%.1f%% (%s/%s)", pct, FormatBytes(p.Bytes), FormatBytes(p.TotalBytes)))
+	} else if p.Bytes > 0 {
+		parts = append(parts, FormatBytes(p.Bytes))
+	}
+	if p.Speed > 0 {
+		parts = append(parts, FormatSpeed(p.Speed))
+	}
+	if p.ETA > 0 {
+		parts = append(parts, "剩余 "+FormatETA(p.ETA))
+	}
+	return strings.Join(parts, " | ")
+}
+
+// FormatETA 格式化剩余时间, 如 01:05、1:02:03
+func FormatETA(seconds int) string {
+	d := time.Duration(seconds) * time.Second
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}